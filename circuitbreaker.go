@@ -0,0 +1,171 @@
+package notificationhubs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenProbes   = 1
+)
+
+// circuitBreaker stops sending requests to an unavailable hub
+type circuitBreaker struct {
+	mu        sync.Mutex
+	opts      CircuitBreakerOptions
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int    // probes in flight
+	successes int    // successful probes
+	phase     uint64 // incremented on every state change, identifies the probes of a half-open phase
+	now       func() time.Time
+}
+
+// SetCircuitBreaker protects the hub with a circuit breaker, nil removes it.
+// Retryable errors, timeouts and network errors count as failures, other outcomes as successes.
+func (h *NotificationHub) SetCircuitBreaker(opts *CircuitBreakerOptions) {
+	if opts == nil {
+		h.circuitBreaker = nil
+		return
+	}
+	o := *opts
+	if o.FailureThreshold <= 0 {
+		o.FailureThreshold = defaultFailureThreshold
+	}
+	if o.OpenTimeout <= 0 {
+		o.OpenTimeout = defaultOpenTimeout
+	}
+	if o.HalfOpenProbes <= 0 {
+		o.HalfOpenProbes = defaultHalfOpenProbes
+	}
+	h.circuitBreaker = &circuitBreaker{opts: o, state: CircuitClosed, now: time.Now}
+}
+
+// CircuitState returns the state of the circuit breaker, closed without one
+func (h *NotificationHub) CircuitState() CircuitState {
+	if h.circuitBreaker == nil {
+		return CircuitClosed
+	}
+	cb := h.circuitBreaker
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.opts.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow checks whether a request may be sent.
+// Probes of a half-open circuit get the phase they belong to, other requests zero.
+func (cb *circuitBreaker) allow() (probe uint64, err error) {
+	if cb == nil {
+		return 0, nil
+	}
+	cb.mu.Lock()
+	var from CircuitState
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.opts.OpenTimeout {
+		from = cb.transition(CircuitHalfOpen)
+	}
+	switch {
+	case cb.state == CircuitClosed:
+	case cb.state == CircuitHalfOpen && cb.probes+cb.successes < cb.opts.HalfOpenProbes:
+		cb.probes++
+		probe = cb.phase
+	default:
+		err = NewError(ErrorCodeCircuitOpen, "Circuit breaker is open")
+	}
+	cb.mu.Unlock()
+
+	cb.notify(from, CircuitHalfOpen)
+	return probe, err
+}
+
+// record updates the circuit breaker with the outcome of a request
+func (cb *circuitBreaker) record(probe uint64, err error) {
+	if cb == nil {
+		return
+	}
+
+	var (
+		failed   = isFailure(err)
+		canceled = errors.Is(err, context.Canceled)
+		from, to CircuitState
+	)
+	cb.mu.Lock()
+	switch {
+	case probe != 0 && probe != cb.phase:
+		// probe of an earlier half-open phase
+	case probe != 0 && canceled:
+		cb.probes--
+	case probe != 0 && failed:
+		from, to = cb.transition(CircuitOpen), CircuitOpen
+	case probe != 0:
+		cb.probes--
+		cb.successes++
+		if cb.successes >= cb.opts.HalfOpenProbes {
+			from, to = cb.transition(CircuitClosed), CircuitClosed
+		}
+	case cb.state != CircuitClosed, canceled:
+		// outcome of a request sent before the circuit opened, or abandoned by the caller
+	case failed:
+		cb.failures++
+		if cb.failures >= cb.opts.FailureThreshold {
+			from, to = cb.transition(CircuitOpen), CircuitOpen
+		}
+	default:
+		cb.failures = 0
+	}
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+}
+
+// transition changes the state, returning the previous one. It must be called with the lock held.
+func (cb *circuitBreaker) transition(to CircuitState) (from CircuitState) {
+	from = cb.state
+	cb.state = to
+	cb.failures = 0
+	cb.probes = 0
+	cb.successes = 0
+	cb.phase++
+	if to == CircuitOpen {
+		cb.openedAt = cb.now()
+	}
+	return from
+}
+
+// notify reports a state change to the callback
+func (cb *circuitBreaker) notify(from, to CircuitState) {
+	if from != "" && from != to && cb.opts.OnStateChange != nil {
+		cb.opts.OnStateChange(from, to)
+	}
+}
+
+// isFailure returns true if an error indicates the hub is unavailable:
+// a server error, throttling, a timeout or a network error
+func isFailure(err error) bool {
+	var (
+		hubErr *NotificationHubError
+		netErr net.Error
+	)
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &hubErr) && hubErr.StatusCode != 0:
+		// rejected requests, whatever their error code, don't tell anything about the hub health
+		return hubErr.StatusCode >= http.StatusInternalServerError || hubErr.StatusCode == http.StatusTooManyRequests
+	case errors.As(err, &hubErr):
+		return hubErr.IsRetryable()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return true
+	}
+	return false
+}
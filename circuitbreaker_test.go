@@ -0,0 +1,143 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_CircuitBreaker(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		mu               sync.Mutex
+		changes          []CircuitState
+		calls            int
+		status           = http.StatusServiceUnavailable
+	)
+	nhub.SetCircuitBreaker(&CircuitBreakerOptions{
+		FailureThreshold: 3,
+		OpenTimeout:      20 * time.Millisecond,
+		OnStateChange: func(from, to CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, to)
+		},
+	})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		if status >= http.StatusMultipleChoices {
+			return nil, &http.Response{StatusCode: status}, errors.New("Got unexpected response status code")
+		}
+		return nil, &http.Response{StatusCode: status}, nil
+	}
+	uninstall := func() error { return nhub.Uninstall(context.Background(), "installation-1") }
+
+	// non retryable failures don't count
+	for _, status = range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone, http.StatusUnprocessableEntity} {
+		for i := 0; i < 5; i++ {
+			_ = uninstall()
+		}
+		if state := nhub.CircuitState(); state != CircuitClosed {
+			t.Fatalf(errfmt, "state after "+http.StatusText(status), CircuitClosed, state)
+		}
+	}
+
+	status = http.StatusServiceUnavailable
+	for i := 0; i < 3; i++ {
+		_ = uninstall()
+	}
+	if state := nhub.CircuitState(); state != CircuitOpen {
+		t.Fatalf(errfmt, "state", CircuitOpen, state)
+	}
+
+	calls = 0
+	err := uninstall()
+	var hubErr *NotificationHubError
+	if !errors.As(err, &hubErr) || !hubErr.IsCircuitOpen() || hubErr.IsRetryable() {
+		t.Errorf(errfmt, "error", ErrorCodeCircuitOpen, err)
+	}
+	if calls != 0 {
+		t.Errorf(errfmt, "calls", 0, calls)
+	}
+
+	// a failed probe opens the circuit again
+	time.Sleep(25 * time.Millisecond)
+	if state := nhub.CircuitState(); state != CircuitHalfOpen {
+		t.Fatalf(errfmt, "state", CircuitHalfOpen, state)
+	}
+	_ = uninstall()
+	if calls != 1 || nhub.CircuitState() != CircuitOpen {
+		t.Errorf(errfmt, "probe", "1 call, open circuit", nhub.CircuitState())
+	}
+
+	// a successful probe closes it
+	time.Sleep(25 * time.Millisecond)
+	status = http.StatusNoContent
+	if err := uninstall(); err != nil {
+		t.Errorf(errfmt, "probe error", nil, err)
+	}
+	if state := nhub.CircuitState(); state != CircuitClosed {
+		t.Errorf(errfmt, "state", CircuitClosed, state)
+	}
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf(errfmt, "state changes", want, changes)
+	}
+}
+
+func Test_CircuitBreakerHalfOpenProbes(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		release          = make(chan struct{})
+		started          = make(chan struct{}, 4)
+		failing          = true
+	)
+	nhub.SetCircuitBreaker(&CircuitBreakerOptions{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond, HalfOpenProbes: 2})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if failing {
+			return nil, &http.Response{StatusCode: http.StatusInternalServerError}, errors.New("Got unexpected response status code: 500")
+		}
+		started <- struct{}{}
+		<-release
+		return nil, &http.Response{StatusCode: http.StatusNoContent}, nil
+	}
+
+	_ = nhub.Uninstall(context.Background(), "installation-1")
+	time.Sleep(15 * time.Millisecond)
+	failing = false
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = nhub.Uninstall(context.Background(), "installation-1")
+		}()
+	}
+	<-started
+	<-started
+
+	// both probes are in flight, other requests fail fast
+	if err := nhub.Uninstall(context.Background(), "installation-1"); !errors.Is(err, NewError(ErrorCodeCircuitOpen, "")) {
+		t.Errorf(errfmt, "error", ErrorCodeCircuitOpen, err)
+	}
+	close(release)
+	wg.Wait()
+	if state := nhub.CircuitState(); state != CircuitClosed {
+		t.Errorf(errfmt, "state", CircuitClosed, state)
+	}
+
+	nhub.SetCircuitBreaker(nil)
+	if state := nhub.CircuitState(); state != CircuitClosed {
+		t.Errorf(errfmt, "state", CircuitClosed, state)
+	}
+}
@@ -34,6 +34,72 @@ const (
 	InstallationChangeRemove  InstallationChangeOp = "remove"
 	InstallationChangeReplace InstallationChangeOp = "replace"
 
+	ExportRegistrationsJob       NotificationHubJobType = "ExportRegistrations"
+	ImportCreateRegistrationsJob NotificationHubJobType = "ImportCreateRegistrations"
+	ImportUpdateRegistrationsJob NotificationHubJobType = "ImportUpdateRegistrations"
+	ImportDeleteRegistrationsJob NotificationHubJobType = "ImportDeleteRegistrations"
+	ImportUpsertRegistrationsJob NotificationHubJobType = "ImportUpsertRegistrations"
+
+	JobStarted   NotificationHubJobStatus = "Started"
+	JobRunning   NotificationHubJobStatus = "Running"
+	JobCompleted NotificationHubJobStatus = "Completed"
+	JobFailed    NotificationHubJobStatus = "Failed"
+
+	// OutputFilePathProperty is the job output property pointing at the output file
+	OutputFilePathProperty = "OutputFilePath"
+	// FailedFilePathProperty is the job output property pointing at the file listing failed lines
+	FailedFilePathProperty = "FailedFilePath"
+
+	OperationSend                Operation = "Send"
+	OperationSendDirect          Operation = "SendDirect"
+	OperationSendDirectBatch     Operation = "SendDirectBatch"
+	OperationSchedule            Operation = "Schedule"
+	OperationCancelScheduled     Operation = "CancelScheduled"
+	OperationNotificationDetails Operation = "NotificationDetails"
+	OperationRegistration        Operation = "Registration"
+	OperationRegistrations       Operation = "Registrations"
+	OperationRegister            Operation = "Register"
+	OperationUnregister          Operation = "Unregister"
+	OperationInstallation        Operation = "Installation"
+	OperationInstall             Operation = "Install"
+	OperationUpdateInstallation  Operation = "UpdateInstallation"
+	OperationUninstall           Operation = "Uninstall"
+	OperationSubmitJob           Operation = "SubmitJob"
+	OperationJob                 Operation = "Job"
+	OperationJobs                Operation = "Jobs"
+	OperationCreateHub           Operation = "CreateHub"
+	OperationHub                 Operation = "Hub"
+	OperationHubs                Operation = "Hubs"
+	OperationUpdateHub           Operation = "UpdateHub"
+	OperationDeleteHub           Operation = "DeleteHub"
+	OperationUnknown             Operation = "Unknown"
+
+	// ApnsProductionEndpoint is the APNS endpoint of apps distributed through the App Store
+	ApnsProductionEndpoint = "https://api.push.apple.com:443/3/device"
+	// ApnsSandboxEndpoint is the APNS endpoint of development builds
+	ApnsSandboxEndpoint = "https://api.development.push.apple.com:443/3/device"
+
+	AccessRightListen AccessRight = "Listen"
+	AccessRightSend   AccessRight = "Send"
+	AccessRightManage AccessRight = "Manage"
+
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+
+	// OperationClassSend groups the operations sending notifications
+	OperationClassSend OperationClass = "send"
+	// OperationClassRegistration groups the registration, installation and job operations
+	OperationClassRegistration OperationClass = "registration"
+	// OperationClassTelemetry groups the operations reading notification telemetry
+	OperationClassTelemetry OperationClass = "telemetry"
+
+	PruneDeleteRegistration PruneActionKind = "deleteRegistration"
+	PruneDeleteInstallation PruneActionKind = "deleteInstallation"
+	PruneMarkRegistration   PruneActionKind = "markRegistration"
+	PruneMarkInstallation   PruneActionKind = "markInstallation"
+	PruneSkip               PruneActionKind = "skip"
+
 	// Abandoned: Message processing has been abandoned.
 	// It will happen when the message could not be processed within the acceptable time window.
 	// By default, it's 30 minutes.
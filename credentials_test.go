@@ -0,0 +1,210 @@
+package notificationhubs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// pemKey generates and PEM encodes a PKCS #8 private key
+func pemKey(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf(errfmt, "key error", nil, err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// invalidFields returns the sorted fields of the validation errors of err
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	var (
+		fields []string
+		multi  *MultiError
+	)
+	if errors.As(err, &multi) {
+		for _, e := range multi.Errors {
+			var validationErr *ValidationError
+			if !errors.As(e, &validationErr) {
+				t.Fatalf(errfmt, "error type", "*ValidationError", e)
+			}
+			fields = append(fields, validationErr.Field)
+		}
+	} else if err != nil {
+		t.Fatalf(errfmt, "error type", "*MultiError", err)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func Test_PnsCredentialsValidate(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var (
+		p8     = pemKey(t, ecKey)
+		lines  = strings.Split(strings.TrimSpace(p8), "\n")
+		p8Body = strings.Join(lines[1:len(lines)-1], "")
+		rsaPEM = pemKey(t, rsaKey)
+		apns   = func(token string) *ApnsCredential {
+			return &ApnsCredential{Endpoint: ApnsSandboxEndpoint, KeyID: "ABC123DEFG", AppID: "TEAM123456", AppName: "com.example.app", Token: token}
+		}
+	)
+
+	tests := []struct {
+		name        string
+		credentials PnsCredentials
+		fields      []string
+	}{
+		{"apns token", PnsCredentials{Apns: apns(p8)}, nil},
+		{"apns token without armor", PnsCredentials{Apns: apns(p8Body)}, nil},
+		{"apns token rsa key", PnsCredentials{Apns: apns(rsaPEM)}, []string{"ApnsCredential.Token"}},
+		{"apns token garbage", PnsCredentials{Apns: apns("not a key")}, []string{"ApnsCredential.Token"}},
+		{"apns token identifiers", PnsCredentials{Apns: &ApnsCredential{Endpoint: ApnsProductionEndpoint, KeyID: "abc", AppName: "com.example.app", Token: p8}},
+			[]string{"ApnsCredential.AppId", "ApnsCredential.KeyId"}},
+		{"apns certificate", PnsCredentials{Apns: &ApnsCredential{Endpoint: ApnsProductionEndpoint, Certificate: "MIIKcQIBAzCCCjcGCSqG", CertificateKey: "secret"}}, nil},
+		{"apns certificate not base64", PnsCredentials{Apns: &ApnsCredential{Endpoint: ApnsProductionEndpoint, Certificate: "not base64!"}}, []string{"ApnsCredential.ApnsCertificate"}},
+		{"apns token and certificate", PnsCredentials{Apns: &ApnsCredential{Endpoint: ApnsProductionEndpoint, KeyID: "ABC123DEFG", Certificate: "MIIK"}}, []string{"ApnsCredential"}},
+		{"apns empty", PnsCredentials{Apns: &ApnsCredential{}}, []string{"ApnsCredential", "ApnsCredential.Endpoint"}},
+		{"fcm v1", PnsCredentials{FcmV1: &FcmV1Credential{ClientEmail: "push@project.iam.gserviceaccount.com", PrivateKey: rsaPEM, ProjectID: "project"}}, nil},
+		{"fcm v1 ec key", PnsCredentials{FcmV1: &FcmV1Credential{ClientEmail: "push@project.iam.gserviceaccount.com", PrivateKey: p8, ProjectID: "project"}},
+			[]string{"FcmV1Credential.PrivateKey"}},
+		{"fcm v1 missing fields", PnsCredentials{FcmV1: &FcmV1Credential{ClientEmail: "push"}},
+			[]string{"FcmV1Credential.ClientEmail", "FcmV1Credential.PrivateKey", "FcmV1Credential.ProjectId"}},
+		{"wns", PnsCredentials{Wns: &WnsCredential{PackageSID: "ms-app://s-1-15-2-1", SecretKey: "secret"}}, nil},
+		{"wns invalid", PnsCredentials{Wns: &WnsCredential{PackageSID: "s-1-15-2-1", WindowsLiveEndpoint: "login"}},
+			[]string{"WnsCredential.PackageSid", "WnsCredential.SecretKey", "WnsCredential.WindowsLiveEndpoint"}},
+		{"adm and baidu", PnsCredentials{Adm: &AdmCredential{ClientID: "id", ClientSecret: "secret"}, Baidu: &BaiduCredential{APIKey: "key", SecretKey: "secret"}}, nil},
+		{"adm and baidu empty", PnsCredentials{Adm: &AdmCredential{}, Baidu: &BaiduCredential{}},
+			[]string{"AdmCredential.ClientId", "AdmCredential.ClientSecret", "BaiduCredential.BaiduApiKey", "BaiduCredential.BaiduSecretKey"}},
+		{"none", PnsCredentials{}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if fields := invalidFields(t, test.credentials.Validate()); !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf(errfmt, "invalid fields", test.fields, fields)
+			}
+		})
+	}
+}
+
+func Test_NewFcmV1Credential(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	account, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "project",
+		"private_key":  pemKey(t, rsaKey),
+		"client_email": "push@project.iam.gserviceaccount.com",
+	})
+
+	credential, err := NewFcmV1Credential(account)
+	if err != nil {
+		t.Fatalf(errfmt, "credential error", nil, err)
+	}
+	if credential.ProjectID != "project" || credential.ClientEmail != "push@project.iam.gserviceaccount.com" {
+		t.Errorf(errfmt, "credential", "project", credential)
+	}
+
+	if _, err := NewFcmV1Credential([]byte(`{"type":"authorized_user","client_email":"me@example.com"}`)); err == nil {
+		t.Errorf(errfmt, "error", "must be service_account", err)
+	}
+	_, err = NewFcmV1Credential([]byte(`{"type":"service_account","project_id":"project"}`))
+	if fields := invalidFields(t, errors.Unwrap(err)); !reflect.DeepEqual(fields, []string{"FcmV1Credential.ClientEmail", "FcmV1Credential.PrivateKey"}) {
+		t.Errorf(errfmt, "invalid fields", "ClientEmail and PrivateKey", err)
+	}
+}
+
+func Test_PnsCredentialsRedaction(t *testing.T) {
+	hub := &NotificationHubDescription{
+		Name: "orders",
+		PnsCredentials: PnsCredentials{
+			Apns:  &ApnsCredential{Endpoint: ApnsSandboxEndpoint, KeyID: "ABC123DEFG", Token: "secret-token"},
+			FcmV1: &FcmV1Credential{ClientEmail: "push@project.iam.gserviceaccount.com", PrivateKey: "secret-key", ProjectID: "project"},
+			Wns:   &WnsCredential{PackageSID: "ms-app://sid", SecretKey: "secret-wns"},
+			Adm:   &AdmCredential{ClientID: "id", ClientSecret: "secret-adm"},
+			Baidu: &BaiduCredential{APIKey: "secret-api", SecretKey: "secret-baidu"},
+		},
+		AuthorizationRules: []AuthorizationRule{{KeyName: "listen", PrimaryKey: "secret-primary", SecondaryKey: "secret-secondary", Rights: []AccessRight{AccessRightListen}}},
+	}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logs, nil)).Info("hub", "apns", hub.Apns, "fcm", *hub.FcmV1, "rule", hub.AuthorizationRules[0])
+	outputs := []string{
+		fmt.Sprintf("%v", hub),
+		fmt.Sprintf("%+v", *hub),
+		fmt.Sprintf("%#v", hub.PnsCredentials),
+		fmt.Sprint(*hub.Wns, hub.Adm, hub.Baidu),
+		fmt.Sprintf("%#v", hub.AuthorizationRules),
+		logs.String(),
+	}
+	for _, output := range outputs {
+		if strings.Contains(output, "secret") {
+			t.Errorf(errfmt, "redacted output", "no secret", output)
+		}
+	}
+	if !strings.Contains(outputs[0], "KeyId: ABC123DEFG") || !strings.Contains(outputs[0], "KeyName: listen") ||
+		!strings.Contains(logs.String(), `"ProjectId":"project"`) || !strings.Contains(logs.String(), `"Rights":"Listen"`) {
+		t.Errorf(errfmt, "output", "non secret fields", outputs[0]+logs.String())
+	}
+}
+
+func Test_CreateHubValidatesCredentials(t *testing.T) {
+	manager, _ := NewNamespaceManager(connectionString)
+	calls := 0
+	manager.SetHTTPClient(&mockHubHTTPClient{execFunc: func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		return nil, &http.Response{StatusCode: http.StatusCreated}, nil
+	}})
+
+	_, err := manager.CreateHub(context.Background(), &NotificationHubDescription{
+		Name:           "orders",
+		PnsCredentials: PnsCredentials{Wns: &WnsCredential{PackageSID: "sid"}},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || calls != 0 {
+		t.Errorf(errfmt, "local validation error without request", "*ValidationError", err)
+	}
+}
+
+func Test_SetPnsCredentialsIsConditional(t *testing.T) {
+	var (
+		manager, _ = NewNamespaceManager(connectionString)
+		requests   []string
+	)
+	manager.SetHTTPClient(&mockHubHTTPClient{execFunc: func(req *http.Request) ([]byte, *http.Response, error) {
+		etag := fmt.Sprintf(`W/"%d"`, len(requests))
+		requests = append(requests, req.Method+" "+req.Header.Get("If-Match"))
+		if req.Method == putMethod && len(requests) == 2 {
+			return nil, &http.Response{StatusCode: http.StatusPreconditionFailed, Header: http.Header{}}, errors.New("precondition failed")
+		}
+		data, err := ioutil.ReadFile("./fixtures/hubDescriptionResult.xml")
+		return data, &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": {etag}}}, err
+	}})
+
+	adm := &AdmCredential{ClientID: "id", ClientSecret: "secret"}
+	if _, err := manager.SetPnsCredentials(context.Background(), hubPath, &PnsCredentials{Adm: adm}); err != nil {
+		t.Fatalf(errfmt, "set credentials error", nil, err)
+	}
+	expected := []string{"GET ", `PUT W/"0"`, "GET ", `PUT W/"2"`}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf(errfmt, "requests", expected, requests)
+	}
+}
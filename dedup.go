@@ -0,0 +1,152 @@
+package notificationhubs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultDedupTTL is the default deduplication window
+const defaultDedupTTL = 24 * time.Hour
+
+type (
+	// MemoryDedupStore keeps the idempotency keys in memory, expired keys are dropped as new ones are stored
+	MemoryDedupStore struct {
+		mu      sync.Mutex
+		entries map[string]dedupEntry
+		now     func() time.Time
+	}
+
+	// dedupEntry is the telemetry of a notification sent with an idempotency key
+	dedupEntry struct {
+		telemetry *NotificationTelemetry
+		expires   time.Time
+	}
+
+	// deduplicator suppresses repeated sends of notifications with the same idempotency key
+	deduplicator struct {
+		opts     DedupOptions
+		mu       sync.Mutex
+		inflight map[string]chan struct{}
+	}
+)
+
+// NewMemoryDedupStore creates an empty in-memory store
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{entries: make(map[string]dedupEntry), now: time.Now}
+}
+
+// Load returns the telemetry stored for key, unless it expired
+func (s *MemoryDedupStore) Load(_ context.Context, key string) (*NotificationTelemetry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.telemetry, true, nil
+}
+
+// Store remembers the telemetry of key for ttl
+func (s *MemoryDedupStore) Store(_ context.Context, key string, telemetry *NotificationTelemetry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for k, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = dedupEntry{telemetry: telemetry, expires: now.Add(ttl)}
+	return nil
+}
+
+// SetDeduplication configures the deduplication of notifications with an idempotency key,
+// nil restores the default in-memory store and window.
+// A send repeating a successful send with the same key within the window isn't sent again,
+// it returns the telemetry of the original send and no raw response. Failed sends don't use the key.
+func (h *NotificationHub) SetDeduplication(opts *DedupOptions) {
+	h.dedup = newDeduplicator(opts)
+}
+
+// newDeduplicator creates a deduplicator, nil options selecting the defaults
+func newDeduplicator(opts *DedupOptions) *deduplicator {
+	var o DedupOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Store == nil {
+		o.Store = NewMemoryDedupStore()
+	}
+	if o.TTL <= 0 {
+		o.TTL = defaultDedupTTL
+	}
+	return &deduplicator{opts: o, inflight: make(map[string]chan struct{})}
+}
+
+// sendNotification sends a notification with send, after validating it when payload validation is on,
+// unless a notification with the same idempotency key was sent. Concurrent sends with the same key wait for each other.
+func (h *NotificationHub) sendNotification(ctx context.Context, n *Notification, send func() ([]byte, *NotificationTelemetry, error)) ([]byte, *NotificationTelemetry, error) {
+	if n != nil && h.validatePayloads {
+		if err := n.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if n == nil || n.IdempotencyKey == "" {
+		return send()
+	}
+	var (
+		d   = h.dedup
+		key = n.IdempotencyKey
+	)
+	if err := d.acquire(ctx, key); err != nil {
+		return nil, nil, err
+	}
+	defer d.release(key)
+
+	telemetry, found, err := d.opts.Store.Load(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("idempotency key %q: %w", key, err)
+	}
+	if found {
+		return nil, telemetry, nil
+	}
+
+	raw, telemetry, err := send()
+	if err != nil {
+		return raw, telemetry, err
+	}
+	if err := d.opts.Store.Store(ctx, key, telemetry, d.opts.TTL); err != nil {
+		return raw, telemetry, fmt.Errorf("notification sent, but idempotency key %q wasn't stored: %w", key, err)
+	}
+	return raw, telemetry, nil
+}
+
+// acquire waits until no send with key is in progress and claims it
+func (d *deduplicator) acquire(ctx context.Context, key string) error {
+	for {
+		d.mu.Lock()
+		wait, busy := d.inflight[key]
+		if !busy {
+			d.inflight[key] = make(chan struct{})
+			d.mu.Unlock()
+			return nil
+		}
+		d.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release ends the send with key in progress
+func (d *deduplicator) release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.inflight[key])
+	delete(d.inflight, key)
+}
@@ -0,0 +1,148 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// sendResponder answers sends with a new notification ID each, failing the first failures ones
+func sendResponder(calls *atomic.Int32, failures int32) func(req *http.Request) ([]byte, *http.Response, error) {
+	return func(req *http.Request) ([]byte, *http.Response, error) {
+		call := calls.Add(1)
+		if call <= failures {
+			return nil, &http.Response{StatusCode: http.StatusInternalServerError}, errors.New("Got unexpected response status code: 500")
+		}
+		location := fmt.Sprintf("https://testhub-ns.servicebus.windows.net/testhub/messages/message-%d?api-version=2016-07", call)
+		return []byte("raw"), &http.Response{StatusCode: http.StatusCreated, Header: http.Header{"Location": []string{location}}}, nil
+	}
+}
+
+func Test_IdempotentSend(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            atomic.Int32
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+		ctx              = context.Background()
+	)
+	mockClient.execFunc = sendResponder(&calls, 1)
+	notification.IdempotencyKey = "order-42-shipped"
+
+	// failed sends don't use the key
+	if _, _, err := nhub.Send(ctx, notification, nil); err == nil {
+		t.Fatalf(errfmt, "Send error", "error", nil)
+	}
+	raw, first, err := nhub.Send(ctx, notification, nil)
+	if err != nil || string(raw) != "raw" {
+		t.Fatalf(errfmt, "Send", "raw", err)
+	}
+
+	repeats := map[string]func() (*NotificationTelemetry, error){
+		"Send": func() (*NotificationTelemetry, error) {
+			_, telemetry, err := nhub.Send(ctx, notification, nil)
+			return telemetry, err
+		},
+		"SendDirect": func() (*NotificationTelemetry, error) {
+			_, telemetry, err := nhub.SendDirect(ctx, notification, "handle")
+			return telemetry, err
+		},
+		"SendDirectBatch": func() (*NotificationTelemetry, error) {
+			_, telemetry, err := nhub.SendDirectBatch(ctx, notification, "handle-1", "handle-2")
+			return telemetry, err
+		},
+		"Schedule": func() (*NotificationTelemetry, error) {
+			_, telemetry, err := nhub.Schedule(ctx, notification, nil, time.Now().Add(time.Hour))
+			return telemetry, err
+		},
+	}
+	for name, repeat := range repeats {
+		telemetry, err := repeat()
+		if err != nil || *telemetry != *first {
+			t.Errorf(errfmt, name+" telemetry", first, telemetry)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf(errfmt, "calls", 2, got)
+	}
+
+	notification.IdempotencyKey = ""
+	_, _, _ = nhub.Send(ctx, notification, nil)
+	_, _, _ = nhub.Send(ctx, notification, nil)
+	if got := calls.Load(); got != 4 {
+		t.Errorf(errfmt, "calls without key", 4, got)
+	}
+}
+
+func Test_IdempotentSendConcurrent(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            atomic.Int32
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+		wg               sync.WaitGroup
+		ids              sync.Map
+	)
+	mockClient.execFunc = sendResponder(&calls, 0)
+	notification.IdempotencyKey = "campaign-7"
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, telemetry, err := nhub.SendDirect(context.Background(), notification, "handle"); err == nil {
+				ids.Store(telemetry.NotificationMessageID, true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	ids.Range(func(_, _ any) bool { count++; return true })
+	if calls.Load() != 1 || count != 1 {
+		t.Errorf(errfmt, "calls and telemetry IDs", "1 and 1", fmt.Sprint(calls.Load(), " and ", count))
+	}
+}
+
+// failingDedupStore is a DedupStore failing every call
+type failingDedupStore struct{}
+
+func (failingDedupStore) Load(context.Context, string) (*NotificationTelemetry, bool, error) {
+	return nil, false, errors.New("store unavailable")
+}
+
+func (failingDedupStore) Store(context.Context, string, *NotificationTelemetry, time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func Test_SetDeduplication(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            atomic.Int32
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+	)
+	mockClient.execFunc = sendResponder(&calls, 0)
+	notification.IdempotencyKey = "reminder"
+
+	nhub.SetDeduplication(&DedupOptions{Store: NewMemoryDedupStore(), TTL: 20 * time.Millisecond})
+	_, _, _ = nhub.Send(context.Background(), notification, nil)
+	_, _, _ = nhub.Send(context.Background(), notification, nil)
+	time.Sleep(30 * time.Millisecond)
+	_, _, _ = nhub.Send(context.Background(), notification, nil)
+	if got := calls.Load(); got != 2 {
+		t.Errorf(errfmt, "calls", 2, got)
+	}
+
+	nhub.SetDeduplication(&DedupOptions{Store: failingDedupStore{}})
+	if _, _, err := nhub.Send(context.Background(), notification, nil); err == nil {
+		t.Errorf(errfmt, "Send error", "store unavailable", nil)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf(errfmt, "calls", 2, got)
+	}
+}
@@ -0,0 +1,152 @@
+package notificationhubs
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// DiffInstallations returns the changes that turn current into desired, for use with Update.
+// Tags are added and removed individually, templates are patched field by field where possible.
+// The platform, the expiration time and secondary tiles that are added or removed as a whole can't be patched:
+// when they differ needsReplace is true and the changes are incomplete, use Install with desired instead.
+// A nil desired expiration time keeps the current one.
+func DiffInstallations(current, desired Installation) (changes []InstallationChange, needsReplace bool) {
+	needsReplace = current.Platform != desired.Platform ||
+		desired.ExpirationTime != nil && !sameExpiry(current.ExpirationTime, desired.ExpirationTime) ||
+		!sameKeys(current.SecondaryTiles, desired.SecondaryTiles)
+
+	if current.PushChannel != desired.PushChannel {
+		changes = append(changes, SetPushChannel(desired.PushChannel))
+	}
+
+	switch {
+	case current.UserID == desired.UserID:
+	case desired.UserID == "":
+		changes = append(changes, RemoveUserID())
+	case current.UserID == "":
+		changes = append(changes, AddUserID(desired.UserID))
+	default:
+		changes = append(changes, SetUserID(desired.UserID))
+	}
+
+	removed, added := diffTags(current.Tags, desired.Tags)
+	for _, tag := range removed {
+		changes = append(changes, RemoveTag(tag))
+	}
+	for _, tag := range added {
+		changes = append(changes, AddTag(tag))
+	}
+
+	for _, name := range sortedKeys(current.Templates) {
+		if _, ok := desired.Templates[name]; !ok {
+			changes = append(changes, RemoveTemplate(name))
+		}
+	}
+	for _, name := range sortedKeys(desired.Templates) {
+		changes = append(changes, diffTemplate(name, current.Templates, desired.Templates[name])...)
+	}
+
+	for _, name := range sortedKeys(desired.SecondaryTiles) {
+		if tile, ok := current.SecondaryTiles[name]; ok {
+			changes = append(changes, diffSecondaryTile(name, tile, desired.SecondaryTiles[name])...)
+		}
+	}
+	return changes, needsReplace
+}
+
+// diffTemplate compares an installation template
+func diffTemplate(name string, current map[string]InstallationTemplate, desired InstallationTemplate) []InstallationChange {
+	template, ok := current[name]
+	if !ok || !sameExpiry(template.Expiry, desired.Expiry) {
+		return []InstallationChange{AddTemplate(name, desired)}
+	}
+
+	var changes []InstallationChange
+	if template.Body != desired.Body {
+		changes = append(changes, SetTemplateBody(name, desired.Body))
+	}
+	if !maps.Equal(template.Headers, desired.Headers) {
+		changes = append(changes, SetTemplateHeaders(name, desired.Headers))
+	}
+	removed, added := diffTags(template.Tags, desired.Tags)
+	for _, tag := range removed {
+		changes = append(changes, RemoveTemplateTag(name, tag))
+	}
+	for _, tag := range added {
+		changes = append(changes, AddTemplateTag(name, tag))
+	}
+	return changes
+}
+
+// diffSecondaryTile compares a secondary tile present in both installations
+func diffSecondaryTile(name string, current, desired InstallationSecondaryTile) []InstallationChange {
+	var changes []InstallationChange
+
+	if current.PushChannel != desired.PushChannel {
+		changes = append(changes, SetSecondaryTilePushChannel(name, desired.PushChannel))
+	}
+	removed, added := diffTags(current.Tags, desired.Tags)
+	for _, tag := range removed {
+		changes = append(changes, RemoveSecondaryTileTag(name, tag))
+	}
+	for _, tag := range added {
+		changes = append(changes, AddSecondaryTileTag(name, tag))
+	}
+
+	for _, templateName := range sortedKeys(current.Templates) {
+		if _, ok := desired.Templates[templateName]; !ok {
+			changes = append(changes, RemoveSecondaryTileTemplate(name, templateName))
+		}
+	}
+	for _, templateName := range sortedKeys(desired.Templates) {
+		template, want := current.Templates[templateName], desired.Templates[templateName]
+		if _, ok := current.Templates[templateName]; !ok || !sameExpiry(template.Expiry, want.Expiry) {
+			changes = append(changes, AddSecondaryTileTemplate(name, templateName, want))
+			continue
+		}
+		if template.Body != want.Body {
+			changes = append(changes, SetSecondaryTileTemplateBody(name, templateName, want.Body))
+		}
+		if !maps.Equal(template.Headers, want.Headers) {
+			changes = append(changes, SetSecondaryTileTemplateHeaders(name, templateName, want.Headers))
+		}
+		if removed, added := diffTags(template.Tags, want.Tags); len(removed) > 0 || len(added) > 0 {
+			changes = append(changes, SetSecondaryTileTemplateTags(name, templateName, want.Tags...))
+		}
+	}
+	return changes
+}
+
+// diffTags returns the tags removed from and added to a tag list, ignoring order and duplicates
+func diffTags(current, desired []string) (removed, added []string) {
+	for _, tag := range current {
+		if !slices.Contains(desired, tag) && !slices.Contains(removed, tag) {
+			removed = append(removed, tag)
+		}
+	}
+	for _, tag := range desired {
+		if !slices.Contains(current, tag) && !slices.Contains(added, tag) {
+			added = append(added, tag)
+		}
+	}
+	return
+}
+
+// sameExpiry compares optional expiry times
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// sameKeys reports whether two maps have the same keys
+func sameKeys[V any](a, b map[string]V) bool {
+	return slices.Equal(sortedKeys(a), sortedKeys(b))
+}
+
+// sortedKeys returns map keys in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
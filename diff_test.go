@@ -0,0 +1,162 @@
+package notificationhubs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_DiffInstallations(t *testing.T) {
+	var (
+		current = Installation{
+			InstallationID: "installationID",
+			Platform:       WNSPlatform,
+			PushChannel:    "old",
+			UserID:         "alice",
+			Tags:           []string{"tag1", "tag2"},
+			Templates: map[string]InstallationTemplate{
+				"unchanged": {Body: "body"},
+				"changed":   {Body: "old", Tags: []string{"a"}},
+				"removed":   {Body: "removed"},
+			},
+			SecondaryTiles: map[string]InstallationSecondaryTile{
+				"tile": {
+					PushChannel: "tileChannel",
+					Tags:        []string{"x"},
+					Templates:   map[string]InstallationTemplate{"t": {Body: "old"}},
+				},
+			},
+		}
+		desired = Installation{
+			InstallationID: "installationID",
+			Platform:       WNSPlatform,
+			PushChannel:    "new",
+			UserID:         "bob",
+			Tags:           []string{"tag2", "tag3"},
+			Templates: map[string]InstallationTemplate{
+				"unchanged": {Body: "body"},
+				"changed":   {Body: "new", Headers: map[string]string{"k": "v"}, Tags: []string{"a", "b"}},
+				"added":     {Body: "added"},
+			},
+			SecondaryTiles: map[string]InstallationSecondaryTile{
+				"tile": {
+					PushChannel: "newTileChannel",
+					Tags:        []string{"x"},
+					Templates:   map[string]InstallationTemplate{"t": {Body: "new", Tags: []string{"y"}}},
+				},
+			},
+		}
+		expected = []InstallationChange{
+			SetPushChannel("new"),
+			SetUserID("bob"),
+			RemoveTag("tag1"),
+			AddTag("tag3"),
+			RemoveTemplate("removed"),
+			AddTemplate("added", InstallationTemplate{Body: "added"}),
+			SetTemplateBody("changed", "new"),
+			SetTemplateHeaders("changed", map[string]string{"k": "v"}),
+			AddTemplateTag("changed", "b"),
+			SetSecondaryTilePushChannel("tile", "newTileChannel"),
+			SetSecondaryTileTemplateBody("tile", "t", "new"),
+			SetSecondaryTileTemplateTags("tile", "t", "y"),
+		}
+	)
+
+	changes, needsReplace := DiffInstallations(current, desired)
+	if needsReplace {
+		t.Errorf(errfmt, "needsReplace", false, needsReplace)
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf(errfmt, "changes", expected, changes)
+	}
+	if err := ValidateChanges(changes...); err != nil {
+		t.Errorf(errfmt, "ValidateChanges error", nil, err)
+	}
+
+	result, err := ApplyChanges(current, changes...)
+	if err != nil {
+		t.Fatalf(errfmt, "ApplyChanges error", nil, err)
+	}
+	if !reflect.DeepEqual(result, desired) {
+		t.Errorf(errfmt, "patched installation", desired, result)
+	}
+
+	if changes, needsReplace := DiffInstallations(desired, desired); len(changes) != 0 || needsReplace {
+		t.Errorf(errfmt, "changes for identical installations", nil, changes)
+	}
+}
+
+func Test_DiffInstallationsUserID(t *testing.T) {
+	tests := []struct {
+		current, desired string
+		expected         []InstallationChange
+	}{
+		{"", "alice", []InstallationChange{AddUserID("alice")}},
+		{"alice", "bob", []InstallationChange{SetUserID("bob")}},
+		{"alice", "", []InstallationChange{RemoveUserID()}},
+		{"alice", "alice", nil},
+	}
+	for _, test := range tests {
+		var (
+			current = Installation{InstallationID: "installationID", Platform: WNSPlatform, PushChannel: "channel", UserID: test.current}
+			desired = current
+		)
+		desired.UserID = test.desired
+		changes, _ := DiffInstallations(current, desired)
+		if !reflect.DeepEqual(changes, test.expected) {
+			t.Errorf(errfmt, "changes from '"+test.current+"'", test.expected, changes)
+		}
+		if result, err := ApplyChanges(current, changes...); err != nil || result.UserID != test.desired {
+			t.Errorf(errfmt, "patched user ID", test.desired, result.UserID)
+		}
+	}
+
+	// replace and remove require an existing user ID, like in JSON Patch
+	for _, change := range []InstallationChange{SetUserID("alice"), RemoveUserID()} {
+		if _, err := ApplyChanges(Installation{InstallationID: "installationID"}, change); err == nil {
+			t.Errorf(errfmt, "error for "+string(change.Op), "user ID doesn't exist", nil)
+		}
+	}
+}
+
+func Test_DiffInstallationsNeedsReplace(t *testing.T) {
+	var (
+		expiry  = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		later   = expiry.Add(time.Hour)
+		current = Installation{
+			InstallationID: "installationID",
+			Platform:       WNSPlatform,
+			PushChannel:    "channel",
+			ExpirationTime: &expiry,
+			SecondaryTiles: map[string]InstallationSecondaryTile{"tile": {PushChannel: "tileChannel"}},
+		}
+	)
+
+	tests := []struct {
+		name         string
+		modify       func(*Installation)
+		needsReplace bool
+	}{
+		{"unchanged", func(*Installation) {}, false},
+		{"user ID", func(i *Installation) { i.UserID = "alice" }, false},
+		{"expiration left out", func(i *Installation) { i.ExpirationTime = nil }, false},
+		{"same expiration in another zone", func(i *Installation) { local := expiry.In(time.FixedZone("CET", 3600)); i.ExpirationTime = &local }, false},
+		{"platform", func(i *Installation) { i.Platform = APNSPlatform }, true},
+		{"expiration", func(i *Installation) { i.ExpirationTime = &later }, true},
+		{"tile added", func(i *Installation) {
+			i.SecondaryTiles = map[string]InstallationSecondaryTile{"tile": {PushChannel: "tileChannel"}, "other": {PushChannel: "otherChannel"}}
+		}, true},
+		{"tile removed", func(i *Installation) { i.SecondaryTiles = nil }, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desired := current
+			test.modify(&desired)
+			if _, needsReplace := DiffInstallations(current, desired); needsReplace != test.needsReplace {
+				t.Errorf(errfmt, "needsReplace", test.needsReplace, needsReplace)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package notificationhubs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Dispatcher defaults
+const (
+	defaultDispatchQueueSize   = 1000
+	defaultDispatchWorkers     = 4
+	defaultDispatchAttempts    = 5
+	defaultDispatchBackoff     = time.Second
+	defaultDispatchMaxBackoff  = time.Minute
+	dispatchNotificationIDSize = 16
+)
+
+// Dispatcher delivers notifications asynchronously. Accepted notifications are persisted in a store
+// until they are delivered or given up, so a new dispatcher on the same store resumes the pending ones.
+// Delivery is at least once: a notification may be sent again when the process stops during its delivery.
+// The delivery attempts of DispatcherOptions.Retry stack on top of the request retries of the hub set with
+// NotificationHub.SetRetry, each delivery attempt can make up to the MaxAttempts of the hub in requests.
+type Dispatcher struct {
+	hub   *NotificationHub
+	opts  DispatcherOptions
+	retry RetryOptions
+
+	mu      sync.Mutex
+	pending int
+	closed  bool
+	timers  map[string]*time.Timer
+	ready   chan QueuedNotification
+
+	stop    chan struct{}
+	sendCtx context.Context
+	abort   context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher delivering through hub and starts its workers.
+// The notifications left in the store by a previous dispatcher are resumed.
+func NewDispatcher(hub *NotificationHub, opts *DispatcherOptions) (*Dispatcher, error) {
+	var o DispatcherOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Store == nil {
+		o.Store = NewMemoryDispatchStore()
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultDispatchQueueSize
+	}
+	if o.Workers <= 0 {
+		o.Workers = defaultDispatchWorkers
+	}
+	retry := RetryOptions{MaxAttempts: defaultDispatchAttempts, InitialBackoff: defaultDispatchBackoff, MaxBackoff: defaultDispatchMaxBackoff}
+	if o.Retry != nil {
+		if o.Retry.MaxAttempts > 0 {
+			retry.MaxAttempts = o.Retry.MaxAttempts
+		}
+		if o.Retry.InitialBackoff > 0 {
+			retry.InitialBackoff = o.Retry.InitialBackoff
+		}
+		if o.Retry.MaxBackoff > 0 {
+			retry.MaxBackoff = o.Retry.MaxBackoff
+		}
+	}
+
+	stored, err := o.Store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.NewDispatcher: %w", err)
+	}
+
+	d := &Dispatcher{
+		hub:     hub,
+		opts:    o,
+		retry:   retry,
+		pending: len(stored),
+		timers:  make(map[string]*time.Timer),
+		ready:   make(chan QueuedNotification, max(o.QueueSize, len(stored))),
+		stop:    make(chan struct{}),
+	}
+	d.sendCtx, d.abort = context.WithCancel(context.Background())
+	for _, n := range stored {
+		d.schedule(n)
+	}
+	for i := 0; i < o.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d, nil
+}
+
+// Enqueue accepts a notification for the devices matching tags, nil for all devices, and returns its ID.
+// It fails with ErrorCodeQueueFull when the queue has no room left.
+func (d *Dispatcher) Enqueue(n *Notification, tags *string) (string, error) {
+	if n == nil {
+		return "", errors.New("notificationhubs.Dispatcher.Enqueue: notification cannot be nil")
+	}
+	id, err := d.enqueue(QueuedNotification{Notification: *n, Tags: tags})
+	if err != nil {
+		return "", fmt.Errorf("notificationhubs.Dispatcher.Enqueue: %w", err)
+	}
+	return id, nil
+}
+
+// EnqueueDirect accepts a notification for a single device and returns its ID.
+// It fails with ErrorCodeQueueFull when the queue has no room left.
+func (d *Dispatcher) EnqueueDirect(n *Notification, deviceHandle string) (string, error) {
+	if n == nil {
+		return "", errors.New("notificationhubs.Dispatcher.EnqueueDirect: notification cannot be nil")
+	}
+	if deviceHandle == "" {
+		return "", errors.New("notificationhubs.Dispatcher.EnqueueDirect: device handle cannot be empty")
+	}
+	id, err := d.enqueue(QueuedNotification{Notification: *n, DeviceHandle: deviceHandle})
+	if err != nil {
+		return "", fmt.Errorf("notificationhubs.Dispatcher.EnqueueDirect: %w", err)
+	}
+	return id, nil
+}
+
+// Len returns the number of notifications waiting for delivery or for a retry
+func (d *Dispatcher) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pending
+}
+
+// Close stops accepting notifications and waits for the deliveries in progress, or aborts them when ctx is done.
+// Pending notifications stay in the store for the next dispatcher.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	for id, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, id)
+	}
+	close(d.stop)
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.abort()
+		return nil
+	case <-ctx.Done():
+		d.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// enqueue persists a new notification and queues it
+func (d *Dispatcher) enqueue(n QueuedNotification) (string, error) {
+	if !n.Notification.Format.IsValid() {
+		return "", fmt.Errorf("unknown format '%s'", n.Notification.Format)
+	}
+	id, err := newDispatchID()
+	if err != nil {
+		return "", err
+	}
+	n.ID = id
+	n.EnqueuedAt = time.Now().UTC()
+
+	d.mu.Lock()
+	switch {
+	case d.closed:
+		d.mu.Unlock()
+		return "", errors.New("dispatcher is closed")
+	case d.pending >= d.opts.QueueSize:
+		d.mu.Unlock()
+		return "", NewError(ErrorCodeQueueFull, "Dispatcher queue is full")
+	}
+	d.pending++
+	d.mu.Unlock()
+
+	if err := d.opts.Store.Save(n); err != nil {
+		d.done()
+		return "", err
+	}
+	d.ready <- n
+	return n.ID, nil
+}
+
+// schedule queues a notification once its next attempt is due
+func (d *Dispatcher) schedule(n QueuedNotification) {
+	delay := time.Until(n.NextAttempt)
+	if delay <= 0 {
+		d.ready <- n
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.timers[n.ID] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		delete(d.timers, n.ID)
+		closed := d.closed
+		d.mu.Unlock()
+		if !closed {
+			d.ready <- n
+		}
+	})
+}
+
+// work delivers queued notifications until the dispatcher is closed
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case n := <-d.ready:
+			d.deliver(n)
+		}
+	}
+}
+
+// deliver sends a notification and records the outcome
+func (d *Dispatcher) deliver(n QueuedNotification) {
+	var (
+		telemetry *NotificationTelemetry
+		err       error
+	)
+	n.Attempts++
+	_, telemetry, err = d.hub.sendNotification(d.sendCtx, &n.Notification, func() ([]byte, *NotificationTelemetry, error) {
+		if n.DeviceHandle != "" {
+			return d.hub.sendDirect(d.sendCtx, &n.Notification, n.DeviceHandle)
+		}
+		return d.hub.send(d.sendCtx, &n.Notification, n.Tags, nil)
+	})
+
+	switch {
+	case err == nil:
+		d.forget(n)
+		if d.opts.OnDelivered != nil {
+			d.opts.OnDelivered(n, telemetry)
+		}
+	case d.sendCtx.Err() != nil:
+		// aborted by Close, the notification stays in the store
+	case isFailure(err) && n.Attempts < d.retry.MaxAttempts:
+		n.LastError = err.Error()
+		n.NextAttempt = time.Now().Add(backoff(d.retry, n.Attempts, err)).UTC()
+		if saveErr := d.opts.Store.Save(n); saveErr != nil {
+			d.hub.logStoreError(saveErr)
+		}
+		d.schedule(n)
+	default:
+		n.LastError = err.Error()
+		d.forget(n)
+		if d.opts.OnFailed != nil {
+			d.opts.OnFailed(n, err)
+		}
+	}
+}
+
+// forget removes a notification that won't be delivered again
+func (d *Dispatcher) forget(n QueuedNotification) {
+	if err := d.opts.Store.Delete(n.ID); err != nil {
+		d.hub.logStoreError(err)
+	}
+	d.done()
+}
+
+// done releases the queue slot of a notification
+func (d *Dispatcher) done() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending--
+}
+
+// newDispatchID generates a random notification ID
+func newDispatchID() (string, error) {
+	b := make([]byte, dispatchNotificationIDSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
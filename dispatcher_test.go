@@ -0,0 +1,216 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// dispatchOutcomes collects the outcomes reported by a Dispatcher
+type dispatchOutcomes struct {
+	delivered chan QueuedNotification
+	failed    chan QueuedNotification
+}
+
+func newDispatchOutcomes(opts *DispatcherOptions) *dispatchOutcomes {
+	outcomes := &dispatchOutcomes{delivered: make(chan QueuedNotification, 10), failed: make(chan QueuedNotification, 10)}
+	opts.OnDelivered = func(n QueuedNotification, _ *NotificationTelemetry) { outcomes.delivered <- n }
+	opts.OnFailed = func(n QueuedNotification, _ error) { outcomes.failed <- n }
+	return outcomes
+}
+
+func receive(t *testing.T, c chan QueuedNotification) QueuedNotification {
+	t.Helper()
+	select {
+	case n := <-c:
+		return n
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a dispatch outcome")
+		return QueuedNotification{}
+	}
+}
+
+func Test_Dispatcher(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+		tags             = "sports"
+		mu               sync.Mutex
+		statuses         = map[string][]int{"handle-retried": {http.StatusServiceUnavailable}, "handle-rejected": {http.StatusBadRequest}}
+		opts             = &DispatcherOptions{Workers: 2, Retry: &RetryOptions{InitialBackoff: time.Millisecond}}
+		outcomes         = newDispatchOutcomes(opts)
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handle := req.Header.Get("ServiceBusNotification-DeviceHandle")
+		if remaining := statuses[handle]; len(remaining) > 0 {
+			statuses[handle] = remaining[1:]
+			return nil, &http.Response{StatusCode: remaining[0]}, errors.New("Got unexpected response status code")
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+
+	dispatcher, err := NewDispatcher(nhub, opts)
+	if err != nil {
+		t.Fatalf(errfmt, "NewDispatcher error", nil, err)
+	}
+	defer dispatcher.Close(context.Background())
+
+	sentID, _ := dispatcher.Enqueue(notification, &tags)
+	if n := receive(t, outcomes.delivered); n.ID != sentID || *n.Tags != tags || n.Attempts != 1 {
+		t.Errorf(errfmt, "delivered notification", sentID, n)
+	}
+
+	retriedID, _ := dispatcher.EnqueueDirect(notification, "handle-retried")
+	if n := receive(t, outcomes.delivered); n.ID != retriedID || n.Attempts != 2 || n.LastError == "" {
+		t.Errorf(errfmt, "retried notification", "2 attempts", n)
+	}
+
+	rejectedID, _ := dispatcher.EnqueueDirect(notification, "handle-rejected")
+	if n := receive(t, outcomes.failed); n.ID != rejectedID || n.Attempts != 1 {
+		t.Errorf(errfmt, "rejected notification", "1 attempt", n)
+	}
+	if pending := dispatcher.Len(); pending != 0 {
+		t.Errorf(errfmt, "pending", 0, pending)
+	}
+}
+
+func Test_DispatcherQueueFull(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+		release          = make(chan struct{})
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		<-release
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+	dispatcher, _ := NewDispatcher(nhub, &DispatcherOptions{QueueSize: 2, Workers: 1})
+	defer dispatcher.Close(context.Background())
+	defer close(release)
+
+	for i := 0; i < 2; i++ {
+		if _, err := dispatcher.Enqueue(notification, nil); err != nil {
+			t.Fatalf(errfmt, "Enqueue error", nil, err)
+		}
+	}
+	if _, err := dispatcher.Enqueue(notification, nil); !errors.Is(err, NewError(ErrorCodeQueueFull, "")) {
+		t.Errorf(errfmt, "Enqueue error", ErrorCodeQueueFull, err)
+	}
+}
+
+func Test_DispatcherNilNotification(t *testing.T) {
+	nhub, _ := initTestItems()
+	dispatcher, _ := NewDispatcher(nhub, nil)
+	defer dispatcher.Close(context.Background())
+
+	if _, err := dispatcher.Enqueue(nil, nil); err == nil {
+		t.Errorf(errfmt, "Enqueue error", "nil notification", err)
+	}
+	if _, err := dispatcher.EnqueueDirect(nil, "handle"); err == nil {
+		t.Errorf(errfmt, "EnqueueDirect error", "nil notification", err)
+	}
+	if got := dispatcher.Len(); got != 0 {
+		t.Errorf(errfmt, "pending", 0, got)
+	}
+}
+
+func Test_DispatcherRetryAfter(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+		mu               sync.Mutex
+		sentAt           []time.Time
+		opts             = &DispatcherOptions{Retry: &RetryOptions{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Second}}
+		outcomes         = newDispatchOutcomes(opts)
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sentAt = append(sentAt, time.Now())
+		if len(sentAt) == 1 {
+			return nil, &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"1"}}}, errors.New("Got unexpected response status code")
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+
+	dispatcher, err := NewDispatcher(nhub, opts)
+	if err != nil {
+		t.Fatalf(errfmt, "NewDispatcher error", nil, err)
+	}
+	defer dispatcher.Close(context.Background())
+
+	_, _ = dispatcher.EnqueueDirect(notification, "handle-throttled")
+	if n := receive(t, outcomes.delivered); n.Attempts != 2 {
+		t.Errorf(errfmt, "attempts", 2, n.Attempts)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if delay := sentAt[1].Sub(sentAt[0]); delay < time.Second {
+		t.Errorf(errfmt, "delay", "the Retry-After of 1s", delay)
+	}
+}
+
+func Test_DispatcherRestart(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		notification, _  = NewNotification(AppleFormat, []byte(`{"aps":{"alert":"hello"}}`))
+		store, err       = NewFileDispatchStore(t.TempDir())
+		available        = false
+		mu               sync.Mutex
+		attempted        = make(chan struct{}, 10)
+	)
+	if err != nil {
+		t.Fatalf(errfmt, "NewFileDispatchStore error", nil, err)
+	}
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() { attempted <- struct{}{} }()
+		if !available {
+			return nil, &http.Response{StatusCode: http.StatusServiceUnavailable}, errors.New("Got unexpected response status code: 503")
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+
+	first, _ := NewDispatcher(nhub, &DispatcherOptions{Store: store, Retry: &RetryOptions{InitialBackoff: time.Hour, MaxBackoff: time.Hour}})
+	id, _ := first.EnqueueDirect(notification, "handle")
+	<-attempted
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if stored, _ := store.Load(); len(stored) == 1 && stored[0].Attempts == 1 {
+			break // the failed attempt was saved
+		}
+	}
+	if err := first.Close(context.Background()); err != nil {
+		t.Fatalf(errfmt, "Close error", nil, err)
+	}
+
+	stored, _ := store.Load()
+	if len(stored) != 1 || stored[0].ID != id || stored[0].Attempts != 1 || stored[0].NextAttempt.IsZero() {
+		t.Fatalf(errfmt, "stored notifications", id, stored)
+	}
+
+	// the next attempt is due
+	stored[0].NextAttempt = time.Now()
+	_ = store.Save(stored[0])
+	mu.Lock()
+	available = true
+	mu.Unlock()
+
+	opts := &DispatcherOptions{Store: store}
+	outcomes := newDispatchOutcomes(opts)
+	second, _ := NewDispatcher(nhub, opts)
+	defer second.Close(context.Background())
+	if n := receive(t, outcomes.delivered); n.ID != id || n.Attempts != 2 || n.Notification.Format != AppleFormat {
+		t.Errorf(errfmt, "resumed notification", id, n)
+	}
+	if stored, _ := store.Load(); len(stored) != 0 {
+		t.Errorf(errfmt, "stored notifications", 0, len(stored))
+	}
+}
@@ -0,0 +1,137 @@
+package notificationhubs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	// MemoryDispatchStore keeps the notifications of a Dispatcher in memory, they don't survive restarts
+	MemoryDispatchStore struct {
+		mu            sync.Mutex
+		notifications map[string]QueuedNotification
+	}
+
+	// FileDispatchStore keeps the notifications of a Dispatcher in a directory, one JSON file each
+	FileDispatchStore struct {
+		dir string
+	}
+)
+
+// fileDispatchStoreExt is the extension of the files of a FileDispatchStore
+const fileDispatchStoreExt = ".json"
+
+// NewMemoryDispatchStore creates an empty in-memory store
+func NewMemoryDispatchStore() *MemoryDispatchStore {
+	return &MemoryDispatchStore{notifications: make(map[string]QueuedNotification)}
+}
+
+// Save stores a notification
+func (s *MemoryDispatchStore) Save(n QueuedNotification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications[n.ID] = n
+	return nil
+}
+
+// Delete removes a notification
+func (s *MemoryDispatchStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.notifications, id)
+	return nil
+}
+
+// Load returns the stored notifications, oldest first
+func (s *MemoryDispatchStore) Load() ([]QueuedNotification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortQueued(slices.Collect(maps.Values(s.notifications))), nil
+}
+
+// NewFileDispatchStore creates a store in dir, creating the directory when missing
+func NewFileDispatchStore(dir string) (*FileDispatchStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("notificationhubs.NewFileDispatchStore: %w", err)
+	}
+	return &FileDispatchStore{dir: dir}, nil
+}
+
+// Save writes a notification, atomically replacing its previous version
+func (s *FileDispatchStore) Save(n QueuedNotification) error {
+	raw, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("notificationhubs.FileDispatchStore.Save: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("notificationhubs.FileDispatchStore.Save: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(raw); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(n.ID))
+	}
+	if err != nil {
+		return fmt.Errorf("notificationhubs.FileDispatchStore.Save: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the file of a notification
+func (s *FileDispatchStore) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("notificationhubs.FileDispatchStore.Delete: %w", err)
+	}
+	return nil
+}
+
+// Load reads the stored notifications, oldest first
+func (s *FileDispatchStore) Load() ([]QueuedNotification, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.FileDispatchStore.Load: %w", err)
+	}
+	var notifications []QueuedNotification
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileDispatchStoreExt) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("notificationhubs.FileDispatchStore.Load: %w", err)
+		}
+		var n QueuedNotification
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, fmt.Errorf("notificationhubs.FileDispatchStore.Load: %s: %w", entry.Name(), err)
+		}
+		notifications = append(notifications, n)
+	}
+	return sortQueued(notifications), nil
+}
+
+// path returns the file of a notification
+func (s *FileDispatchStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+fileDispatchStoreExt)
+}
+
+// sortQueued orders notifications by enqueue time
+func sortQueued(notifications []QueuedNotification) []QueuedNotification {
+	slices.SortStableFunc(notifications, func(a, b QueuedNotification) int {
+		return a.EnqueuedAt.Compare(b.EnqueuedAt)
+	})
+	return notifications
+}
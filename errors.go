@@ -3,6 +3,8 @@ package notificationhubs
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // ErrorCode represents specific error types that can occur
@@ -46,6 +48,18 @@ const (
 	ErrorCodeInstallationNotFound ErrorCode = "INSTALLATION_NOT_FOUND"
 	// ErrorCodeInvalidInstallation indicates invalid installation
 	ErrorCodeInvalidInstallation ErrorCode = "INVALID_INSTALLATION"
+
+	// ErrorCodePreconditionFailed indicates the resource changed since its ETag was read,
+	// or already exists when creation was requested
+	ErrorCodePreconditionFailed ErrorCode = "PRECONDITION_FAILED"
+	// ErrorCodeConflict indicates the resource already exists
+	ErrorCodeConflict ErrorCode = "CONFLICT"
+
+	// ErrorCodeCircuitOpen indicates the request wasn't sent because the circuit breaker is open
+	ErrorCodeCircuitOpen ErrorCode = "CIRCUIT_OPEN"
+
+	// ErrorCodeQueueFull indicates a Dispatcher queue has no room for more notifications
+	ErrorCodeQueueFull ErrorCode = "QUEUE_FULL"
 )
 
 // NotificationHubError represents an error from the notification hub service
@@ -55,6 +69,7 @@ type NotificationHubError struct {
 	Details    string
 	StatusCode int
 	RequestID  string
+	RetryAfter time.Duration // delay the hub asked for with a Retry-After header, zero without one
 	Cause      error
 }
 
@@ -91,6 +106,21 @@ func (e *NotificationHubError) IsRetryable() bool {
 	}
 }
 
+// IsNotFound returns true if the service reported that the resource doesn't exist
+func (e *NotificationHubError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// IsPreconditionFailed returns true if a conditional request failed because the resource ETag didn't match
+func (e *NotificationHubError) IsPreconditionFailed() bool {
+	return e.Code == ErrorCodePreconditionFailed
+}
+
+// IsCircuitOpen returns true if the request failed fast because the circuit breaker is open
+func (e *NotificationHubError) IsCircuitOpen() bool {
+	return e.Code == ErrorCodeCircuitOpen
+}
+
 // IsAuthenticationError returns true if the error is related to authentication
 func (e *NotificationHubError) IsAuthenticationError() bool {
 	switch e.Code {
@@ -118,12 +148,24 @@ func NewErrorWithCause(code ErrorCode, message string, cause error) *Notificatio
 	}
 }
 
+// newErrorFromFailedRequest creates the error of a request the hub answered with a non-2xx status.
+// The error of the HTTP client, which carries the response body, becomes the details and the cause.
+func newErrorFromFailedRequest(resp *http.Response, err error) *NotificationHubError {
+	hubErr := NewErrorFromHTTPResponse(resp, nil)
+	hubErr.Details = err.Error()
+	hubErr.Cause = err
+	return hubErr
+}
+
 // NewErrorFromHTTPResponse creates an error from an HTTP response
 func NewErrorFromHTTPResponse(resp *http.Response, body []byte) *NotificationHubError {
 	err := &NotificationHubError{
 		StatusCode: resp.StatusCode,
 		RequestID:  resp.Header.Get("x-ms-request-id"),
 	}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
 
 	switch resp.StatusCode {
 	case http.StatusBadRequest:
@@ -138,6 +180,12 @@ func NewErrorFromHTTPResponse(resp *http.Response, body []byte) *NotificationHub
 	case http.StatusNotFound:
 		err.Code = ErrorCodeRegistrationNotFound
 		err.Message = "Resource not found"
+	case http.StatusConflict:
+		err.Code = ErrorCodeConflict
+		err.Message = "Conflict"
+	case http.StatusPreconditionFailed:
+		err.Code = ErrorCodePreconditionFailed
+		err.Message = "Precondition failed"
 	case http.StatusRequestEntityTooLarge:
 		err.Code = ErrorCodePayloadTooLarge
 		err.Message = "Payload too large"
@@ -154,7 +202,11 @@ func NewErrorFromHTTPResponse(resp *http.Response, body []byte) *NotificationHub
 		err.Code = ErrorCodeTimeout
 		err.Message = "Gateway timeout"
 	default:
-		err.Code = ErrorCodeServerError
+		// only server errors are retryable, other unknown statuses are rejected requests
+		err.Code = ErrorCodeInvalidRequest
+		if resp.StatusCode >= http.StatusInternalServerError {
+			err.Code = ErrorCodeServerError
+		}
 		err.Message = fmt.Sprintf("HTTP %d", resp.StatusCode)
 	}
 
@@ -199,6 +251,11 @@ func (e *MultiError) Error() string {
 	return fmt.Sprintf("multiple errors occurred (%d errors)", len(e.Errors))
 }
 
+// Unwrap returns the collected errors, so errors.Is and errors.As can inspect them
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
 // Add adds an error to the multi-error
 func (e *MultiError) Add(err error) {
 	if err != nil {
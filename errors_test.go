@@ -179,6 +179,22 @@ func Test_NewErrorFromHTTPResponse(t *testing.T) {
 			expectedMsg:     "Resource not found",
 			expectedDetails: "Resource not found",
 		},
+		{
+			name:            "Conflict",
+			statusCode:      http.StatusConflict,
+			body:            []byte("Entity already exists"),
+			expectedCode:    ErrorCodeConflict,
+			expectedMsg:     "Conflict",
+			expectedDetails: "Entity already exists",
+		},
+		{
+			name:            "Precondition failed",
+			statusCode:      http.StatusPreconditionFailed,
+			body:            []byte("ETag mismatch"),
+			expectedCode:    ErrorCodePreconditionFailed,
+			expectedMsg:     "Precondition failed",
+			expectedDetails: "ETag mismatch",
+		},
 		{
 			name:            "Payload too large",
 			statusCode:      http.StatusRequestEntityTooLarge,
@@ -223,10 +239,18 @@ func Test_NewErrorFromHTTPResponse(t *testing.T) {
 			name:            "Unknown status code",
 			statusCode:      418,
 			body:            []byte("I'm a teapot"),
-			expectedCode:    ErrorCodeServerError,
+			expectedCode:    ErrorCodeInvalidRequest,
 			expectedMsg:     "HTTP 418",
 			expectedDetails: "I'm a teapot",
 		},
+		{
+			name:            "Unknown server error status code",
+			statusCode:      http.StatusBadGateway,
+			body:            []byte("Bad gateway"),
+			expectedCode:    ErrorCodeServerError,
+			expectedMsg:     "HTTP 502",
+			expectedDetails: "Bad gateway",
+		},
 	}
 
 	for _, tt := range tests {
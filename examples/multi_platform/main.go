@@ -111,4 +111,28 @@ func main() {
 
 	fmt.Printf("\nWNS notification sent successfully!\n")
 	fmt.Printf("Message ID: %s\n", wnsTelemetry.NotificationMessageID)
+
+	// Example 4: Send one logical message to all platforms at once
+	badge := 1
+	result, err := hub.SendMultiPlatform(ctx, &notificationhubs.MultiPlatformMessage{
+		Title:    "Multi-platform Notification",
+		Body:     "This is a notification rendered for every platform",
+		Badge:    &badge,
+		DeepLink: "myapp://inbox",
+		Overrides: map[notificationhubs.NotificationFormat]notificationhubs.PlatformOverride{
+			notificationhubs.FcmV1Format: {Title: "Android Notification"},
+		},
+	}, nil)
+	if err != nil {
+		log.Printf("Some platforms failed: %v", err)
+	}
+
+	fmt.Printf("\nMulti-platform notification sent!\n")
+	for format, platformResult := range result.Results {
+		if platformResult.Err != nil {
+			fmt.Printf("%s: failed: %v\n", format, platformResult.Err)
+			continue
+		}
+		fmt.Printf("%s: Message ID: %s\n", format, platformResult.Telemetry.NotificationMessageID)
+	}
 }
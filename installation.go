@@ -4,33 +4,69 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path"
 )
 
-// Installation reads one specific installation
+// Installation reads one specific installation, including the ETag used by the conditional variants
 func (h *NotificationHub) Installation(ctx context.Context, installationID string) (raw []byte, installation *Installation, err error) {
 	var (
 		instURL = h.generateAPIURL(path.Join("installations", installationID))
 	)
 
-	raw, _, err = h.exec(ctx, getMethod, instURL, Headers{}, nil)
+	raw, resp, err := h.exec(ctx, getMethod, instURL, Headers{}, nil)
 	if err != nil {
 		return
 	}
 
-	err = json.Unmarshal(raw, &installation)
+	if err = json.Unmarshal(raw, &installation); err != nil {
+		return
+	}
+	if resp != nil && installation != nil {
+		installation.ETag = resp.Header.Get("ETag")
+	}
 	return
 }
 
+// InstallationExists checks whether an installation exists
+func (h *NotificationHub) InstallationExists(ctx context.Context, installationID string) (bool, error) {
+	_, _, err := h.Installation(ctx, installationID)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("notificationhubs.InstallationExists: %w", err)
+	}
+	return true, nil
+}
+
 // Install sends a device installation to the Azure hub
 func (h *NotificationHub) Install(ctx context.Context, installation Installation) (err error) {
+	return h.install(ctx, installation, Headers{})
+}
+
+// InstallIfMatch replaces a device installation only if its ETag still matches the one read,
+// otherwise a NotificationHubError with ErrorCodePreconditionFailed is returned
+func (h *NotificationHub) InstallIfMatch(ctx context.Context, installation Installation, etag string) (err error) {
+	if etag == "" {
+		return errors.New("ETag cannot be empty")
+	}
+	return h.install(ctx, installation, Headers{"If-Match": etag})
+}
+
+// InstallIfNotExists creates a device installation only if it doesn't exist yet,
+// otherwise a NotificationHubError with ErrorCodePreconditionFailed is returned
+func (h *NotificationHub) InstallIfNotExists(ctx context.Context, installation Installation) (err error) {
+	return h.install(ctx, installation, Headers{"If-None-Match": "*"})
+}
+
+// install sends a device installation with extra headers
+func (h *NotificationHub) install(ctx context.Context, installation Installation, headers Headers) (err error) {
 	var (
 		instURL = h.generateAPIURL(path.Join("installations", installation.InstallationID))
-		headers = map[string]string{
-			"Content-Type": "application/json",
-		}
 	)
+	headers["Content-Type"] = "application/json"
 
 	raw, err := json.Marshal(installation)
 	if err != nil {
@@ -41,14 +77,31 @@ func (h *NotificationHub) Install(ctx context.Context, installation Installation
 	return
 }
 
-// Update sends a collection of installation changes to the Azure hub
+// Update sends a collection of installation changes to the Azure hub.
+// Changes are validated with ValidateChanges before anything is sent.
 func (h *NotificationHub) Update(ctx context.Context, installationID string, changes ...InstallationChange) (err error) {
+	return h.update(ctx, "Update", installationID, Headers{}, changes)
+}
+
+// UpdateIfMatch sends installation changes only if the installation ETag still matches the one read,
+// otherwise a NotificationHubError with ErrorCodePreconditionFailed is returned
+func (h *NotificationHub) UpdateIfMatch(ctx context.Context, installationID, etag string, changes ...InstallationChange) (err error) {
+	if etag == "" {
+		return errors.New("ETag cannot be empty")
+	}
+	return h.update(ctx, "UpdateIfMatch", installationID, Headers{"If-Match": etag}, changes)
+}
+
+// update sends installation changes with extra headers, errors are prefixed with the name of the calling method
+func (h *NotificationHub) update(ctx context.Context, caller, installationID string, headers Headers, changes []InstallationChange) (err error) {
 	var (
 		instURL = h.generateAPIURL(path.Join("installations", installationID))
-		headers = map[string]string{
-			"Content-Type": "application/json-patch+json",
-		}
 	)
+	headers["Content-Type"] = "application/json-patch+json"
+
+	if err = ValidateChanges(changes...); err != nil {
+		return fmt.Errorf("notificationhubs.%s: %w", caller, err)
+	}
 
 	raw, err := json.Marshal(changes)
 	if err != nil {
@@ -64,10 +117,24 @@ func SetPushChannel(pushChannel string) InstallationChange {
 	return InstallationChange{Op: InstallationChangeReplace, Path: "/pushChannel", Value: pushChannel}
 }
 
+// AddUserID adds a user ID to an installation without one, the service tags the installation with UserIDTag
+func AddUserID(userID string) InstallationChange {
+	return InstallationChange{Op: InstallationChangeAdd, Path: "/userId", Value: userID}
+}
+
+// SetUserID replaces the user ID of an installation, the service tags the installation with UserIDTag
+func SetUserID(userID string) InstallationChange {
+	return InstallationChange{Op: InstallationChangeReplace, Path: "/userId", Value: userID}
+}
+
+// RemoveUserID removes the installation user ID
+func RemoveUserID() InstallationChange {
+	return InstallationChange{Op: InstallationChangeRemove, Path: "/userId"}
+}
+
 // SetTags sets the installation tags
 func SetTags(tags ...string) InstallationChange {
-	raw, _ := json.Marshal(tags)
-	return InstallationChange{Op: InstallationChangeReplace, Path: "/tags", Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: "/tags", Value: tags}
 }
 
 // AddTag adds a tag to the installation
@@ -83,14 +150,12 @@ func RemoveTag(tag string) InstallationChange {
 // SetTemplates sets the installation templates
 // Deprecated: doesn't appear to be supported
 func SetTemplates(templates map[string]InstallationTemplate) InstallationChange {
-	raw, _ := json.Marshal(templates)
-	return InstallationChange{Op: InstallationChangeReplace, Path: "/templates", Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: "/templates", Value: templates}
 }
 
 // AddTemplate adds a template to the installation
 func AddTemplate(name string, template InstallationTemplate) InstallationChange {
-	raw, _ := json.Marshal(template)
-	return InstallationChange{Op: InstallationChangeAdd, Path: "/templates/" + name, Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeAdd, Path: "/templates/" + name, Value: template}
 }
 
 // SetTemplateBody sets the body on a template in the installation
@@ -100,14 +165,12 @@ func SetTemplateBody(name, body string) InstallationChange {
 
 // SetTemplateHeaders sets the headers on a template in the installation
 func SetTemplateHeaders(name string, headers map[string]string) InstallationChange {
-	raw, _ := json.Marshal(headers)
-	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/templates/%s/headers", name), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/templates/%s/headers", name), Value: headers}
 }
 
 // SetTemplateTags sets the tags on a template in the installation
 func SetTemplateTags(name string, tags ...string) InstallationChange {
-	raw, _ := json.Marshal(tags)
-	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/templates/%s/tags", name), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/templates/%s/tags", name), Value: tags}
 }
 
 // AddTemplateTag adds a tag to a template in the installation
@@ -128,15 +191,13 @@ func RemoveTemplate(name string) InstallationChange {
 // SetSecondaryTiles sets the installation secondary tiles
 // Deprecated: doesn't appear to be supported
 func SetSecondaryTiles(secondaryTiles map[string]InstallationSecondaryTile) InstallationChange {
-	raw, _ := json.Marshal(secondaryTiles)
-	return InstallationChange{Op: InstallationChangeReplace, Path: "/secondaryTiles", Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: "/secondaryTiles", Value: secondaryTiles}
 }
 
 // AddSecondaryTile adds a secondary tile to the installation
 // Deprecated: doesn't appear to be supported
 func AddSecondaryTile(name string, secondaryTile InstallationSecondaryTile) InstallationChange {
-	raw, _ := json.Marshal(secondaryTile)
-	return InstallationChange{Op: InstallationChangeAdd, Path: "/secondaryTiles/" + name, Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeAdd, Path: "/secondaryTiles/" + name, Value: secondaryTile}
 }
 
 // SetSecondaryTilePushChannel sets the push channel on a secondary tile in the installation
@@ -146,8 +207,7 @@ func SetSecondaryTilePushChannel(name, pushChannel string) InstallationChange {
 
 // SetSecondaryTileTags sets the tags on a secondary tile in the installation
 func SetSecondaryTileTags(name string, tags ...string) InstallationChange {
-	raw, _ := json.Marshal(tags)
-	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/tags", name), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/tags", name), Value: tags}
 }
 
 // AddSecondaryTileTag adds a tag to a secondary tile in the installation
@@ -162,14 +222,12 @@ func RemoveSecondaryTileTag(name, tag string) InstallationChange {
 
 // SetSecondaryTileTemplates sets the installation templates
 func SetSecondaryTileTemplates(name string, templates map[string]InstallationTemplate) InstallationChange {
-	raw, _ := json.Marshal(templates)
-	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/templates", name), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/templates", name), Value: templates}
 }
 
 // AddSecondaryTileTemplate adds a template to the installation
 func AddSecondaryTileTemplate(name, templateName string, template InstallationTemplate) InstallationChange {
-	raw, _ := json.Marshal(template)
-	return InstallationChange{Op: InstallationChangeAdd, Path: fmt.Sprintf("/secondaryTiles/%s/templates/%s", name, templateName), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeAdd, Path: fmt.Sprintf("/secondaryTiles/%s/templates/%s", name, templateName), Value: template}
 }
 
 // SetSecondaryTileTemplateBody sets the body on a template in the installation
@@ -179,14 +237,12 @@ func SetSecondaryTileTemplateBody(name, template, body string) InstallationChang
 
 // SetSecondaryTileTemplateHeaders sets the headers on a template in the installation
 func SetSecondaryTileTemplateHeaders(name, template string, headers map[string]string) InstallationChange {
-	raw, _ := json.Marshal(headers)
-	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/templates/%s/headers", name, template), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/templates/%s/headers", name, template), Value: headers}
 }
 
 // SetSecondaryTileTemplateTags sets the tags on a template in the installation
 func SetSecondaryTileTemplateTags(name, template string, tags ...string) InstallationChange {
-	raw, _ := json.Marshal(tags)
-	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/templates/%s/tags", name, template), Value: string(raw)}
+	return InstallationChange{Op: InstallationChangeReplace, Path: fmt.Sprintf("/secondaryTiles/%s/templates/%s/tags", name, template), Value: tags}
 }
 
 // RemoveSecondaryTileTemplate removes a template from the installation
@@ -202,12 +258,24 @@ func RemoveSecondaryTile(name string) InstallationChange {
 
 // Uninstall sends a device installation delete to the Azure hub
 func (h *NotificationHub) Uninstall(ctx context.Context, installationID string) (err error) {
+	return h.uninstall(ctx, installationID, Headers{})
+}
+
+// UninstallIfMatch deletes a device installation only if its ETag still matches the one read,
+// otherwise a NotificationHubError with ErrorCodePreconditionFailed is returned
+func (h *NotificationHub) UninstallIfMatch(ctx context.Context, installationID, etag string) (err error) {
+	if etag == "" {
+		return errors.New("ETag cannot be empty")
+	}
+	return h.uninstall(ctx, installationID, Headers{"If-Match": etag})
+}
+
+// uninstall sends a device installation delete with extra headers
+func (h *NotificationHub) uninstall(ctx context.Context, installationID string, headers Headers) (err error) {
 	var (
 		instURL = h.generateAPIURL(path.Join("installations", installationID))
-		headers = map[string]string{
-			"Content-Type": "application/json",
-		}
 	)
+	headers["Content-Type"] = "application/json"
 
 	_, _, err = h.exec(ctx, deleteMethod, instURL, headers, nil)
 	return
@@ -2,10 +2,12 @@ package notificationhubs_test
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	. "github.com/koreset/azure-notifications-sdk-go"
@@ -167,3 +169,77 @@ func Test_Uninstall(t *testing.T) {
 		t.Errorf(errfmt, "error", nil, err)
 	}
 }
+
+func Test_InstallationExists(t *testing.T) {
+	nhub, mockClient := initTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/missing") {
+			return nil, &http.Response{StatusCode: http.StatusNotFound}, errors.New("Got unexpected response status code: 404")
+		}
+		data, e := ioutil.ReadFile("./fixtures/fcmv1InstallationResult.json")
+		return data, &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": {"3"}}}, e
+	}
+
+	for id, expected := range map[string]bool{"fcmv1-installation-sample-id": true, "missing": false} {
+		exists, err := nhub.InstallationExists(context.Background(), id)
+		if err != nil {
+			t.Errorf(errfmt, "error", nil, err)
+		}
+		if exists != expected {
+			t.Errorf(errfmt, "exists "+id, expected, exists)
+		}
+	}
+
+	_, installation, err := nhub.Installation(context.Background(), "fcmv1-installation-sample-id")
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if installation.ETag != "3" {
+		t.Errorf(errfmt, "ETag", "3", installation.ETag)
+	}
+}
+
+func Test_ConditionalInstallation(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		installation     = Installation{InstallationID: "id", PushChannel: "channel", Platform: FCMV1Platform}
+		gotHeaders       []http.Header
+	)
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		gotHeaders = append(gotHeaders, req.Header)
+		return nil, nil, nil
+	}
+
+	ctx := context.Background()
+	for _, err := range []error{
+		nhub.InstallIfMatch(ctx, installation, "1"),
+		nhub.InstallIfNotExists(ctx, installation),
+		nhub.UpdateIfMatch(ctx, "id", "2", AddTag("tag")),
+		nhub.UninstallIfMatch(ctx, "id", "3"),
+	} {
+		if err != nil {
+			t.Errorf(errfmt, "error", nil, err)
+		}
+	}
+
+	expected := []struct{ header, value string }{{"If-Match", "1"}, {"If-None-Match", "*"}, {"If-Match", "2"}, {"If-Match", "3"}}
+	for i, e := range expected {
+		if got := gotHeaders[i].Get(e.header); got != e.value {
+			t.Errorf(errfmt, e.header, e.value, got)
+		}
+	}
+
+	if err := nhub.InstallIfMatch(ctx, installation, ""); err == nil {
+		t.Errorf(errfmt, "error", "empty ETag", nil)
+	}
+
+	invalid := InstallationChange{Op: InstallationChangeAdd, Path: "/unknown", Value: "value"}
+	if err := nhub.UpdateIfMatch(ctx, "id", "2", invalid); err == nil || !strings.HasPrefix(err.Error(), "notificationhubs.UpdateIfMatch: ") {
+		t.Errorf(errfmt, "error", "notificationhubs.UpdateIfMatch: validation error", err)
+	}
+	if err := nhub.Update(ctx, "id", invalid); err == nil || !strings.HasPrefix(err.Error(), "notificationhubs.Update: ") {
+		t.Errorf(errfmt, "error", "notificationhubs.Update: validation error", err)
+	}
+}
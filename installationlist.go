@@ -0,0 +1,289 @@
+package notificationhubs
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"iter"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type (
+	// registrationFeedEntry is a registration description of any platform, reduced to ID, tags and handle
+	registrationFeedEntry struct {
+		Description struct {
+			RegistrationID      string `xml:"RegistrationId"`
+			Tags                string `xml:"Tags"`
+			DeviceToken         string `xml:"DeviceToken"`
+			FcmV1RegistrationID string `xml:"FcmV1RegistrationId"`
+			ChannelURI          string `xml:"ChannelUri"`
+			AdmRegistrationID   string `xml:"AdmRegistrationId"`
+			BaiduChannelID      string `xml:"BaiduChannelId"`
+		} `xml:",any"`
+	}
+
+	// registrationFeed is an atom feed of registrations of any platform
+	registrationFeed struct {
+		Entries []struct {
+			Content registrationFeedEntry `xml:"content"`
+		} `xml:"entry"`
+	}
+)
+
+// InstallationsPage reads one page of installations.
+// The service can't list installations directly, so registrations are listed instead and
+// the installations they belong to are read one by one. A page may hold fewer installations than Top.
+func (h *NotificationHub) InstallationsPage(ctx context.Context, query *InstallationQuery) (*InstallationPage, error) {
+	if query == nil {
+		query = &InstallationQuery{}
+	}
+
+	var (
+		regURL = h.generateAPIURL("registrations")
+		params = regURL.Query()
+	)
+	if query.Tag != "" {
+		regURL = h.generateAPIURL(path.Join("tags", query.Tag, "registrations"))
+	}
+	if query.Top > 0 {
+		params.Set("$top", strconv.Itoa(query.Top))
+	}
+	if query.ContinuationToken != "" {
+		params.Set("ContinuationToken", query.ContinuationToken)
+	}
+	regURL.RawQuery = params.Encode()
+
+	raw, resp, err := h.exec(ctx, getMethod, regURL, Headers{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.InstallationsPage: %w", err)
+	}
+	var feed registrationFeed
+	if err = xml.Unmarshal(raw, &feed); err != nil {
+		return nil, fmt.Errorf("notificationhubs.InstallationsPage: %w", err)
+	}
+
+	page := &InstallationPage{}
+	if resp != nil {
+		page.ContinuationToken = resp.Header.Get("X-MS-ContinuationToken")
+	}
+	var entries []registrationFeedEntry
+	for _, entry := range feed.Entries {
+		entries = append(entries, entry.Content)
+	}
+	page.Installations, err = h.readInstallations(ctx, query.PushChannel, entries, nil)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.InstallationsPage: %w", err)
+	}
+	return page, nil
+}
+
+// Installations iterates over all installations matching the query, following continuation tokens.
+// Iteration stops at the first error.
+func (h *NotificationHub) Installations(ctx context.Context, query *InstallationQuery) iter.Seq2[*Installation, error] {
+	return func(yield func(*Installation, error) bool) {
+		q := InstallationQuery{}
+		if query != nil {
+			q = *query
+		}
+		seen := map[string]bool{}
+		for {
+			page, err := h.InstallationsPage(ctx, &q)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, installation := range page.Installations {
+				if seen[installation.InstallationID] {
+					continue
+				}
+				seen[installation.InstallationID] = true
+				if !yield(installation, nil) {
+					return
+				}
+			}
+			if page.ContinuationToken == "" {
+				return
+			}
+			q.ContinuationToken = page.ContinuationToken
+		}
+	}
+}
+
+// InstallationsByUserID iterates over the installations of a user,
+// identified by the tag the service adds to installations with a user ID
+func (h *NotificationHub) InstallationsByUserID(ctx context.Context, userID string) iter.Seq2[*Installation, error] {
+	if err := checkTagValue("userID", userID); err != nil {
+		return func(yield func(*Installation, error) bool) {
+			yield(nil, fmt.Errorf("notificationhubs.InstallationsByUserID: %w", err))
+		}
+	}
+	return h.Installations(ctx, &InstallationQuery{Tag: UserIDTag(userID)})
+}
+
+// ExportInstallations lists installations through a registration export job, for hubs too large to page through.
+// The job output is read with the hub blob reader, installations matching the query are read one by one.
+// Only Tag and PushChannel of the query are used. Iteration stops at the first error.
+func (h *NotificationHub) ExportInstallations(ctx context.Context, outputContainerURI string, query *InstallationQuery, opts *JobWaitOptions) iter.Seq2[*Installation, error] {
+	return func(yield func(*Installation, error) bool) {
+		if query == nil {
+			query = &InstallationQuery{}
+		}
+
+		_, job, err := h.SubmitJob(ctx, NotificationHubJob{Type: ExportRegistrationsJob, OutputContainerURI: outputContainerURI})
+		if err != nil {
+			yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: %w", err))
+			return
+		}
+		if job, err = h.WaitForJob(ctx, job.ID, opts); err != nil {
+			yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: %w", err))
+			return
+		}
+		if job.Status != JobCompleted {
+			yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: job %s %s: %s", job.ID, job.Status, job.Failure))
+			return
+		}
+
+		blob, err := h.blobReader.ReadBlob(ctx, jobFileURI(outputContainerURI, job.OutputProperty(OutputFilePathProperty)))
+		if err != nil {
+			yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: %w", err))
+			return
+		}
+		defer blob.Close()
+
+		var (
+			scanner = bufio.NewScanner(blob)
+			seen    = map[string]bool{}
+		)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxJobFileLineSize)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var entry registrationFeedEntry
+			if err := xml.Unmarshal([]byte("<content>"+line+"</content>"), &entry); err != nil {
+				yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: %w", err))
+				return
+			}
+			if query.Tag != "" && !entry.hasTag(query.Tag) {
+				continue
+			}
+			installations, err := h.readInstallations(ctx, query.PushChannel, []registrationFeedEntry{entry}, seen)
+			if err != nil {
+				yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: %w", err))
+				return
+			}
+			for _, installation := range installations {
+				if !yield(installation, nil) {
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, fmt.Errorf("notificationhubs.ExportInstallations: %w", err))
+		}
+	}
+}
+
+// jobFileURI resolves a job output file path, relative to the storage account, against the output container SAS URI
+func jobFileURI(containerURI, filePath string) string {
+	u, err := url.Parse(containerURI)
+	if err != nil || strings.Contains(filePath, "://") {
+		return filePath
+	}
+	u.Path = "/" + strings.TrimPrefix(filePath, "/")
+	return u.String()
+}
+
+// InstallationIDTag returns the tag the service adds to every registration of an installation
+func InstallationIDTag(installationID string) string {
+	return installationIDTagPrefix + "{" + installationID + "}"
+}
+
+// UserIDTag returns the tag the service adds to installations with a user ID
+func UserIDTag(userID string) string {
+	return userIDTagPrefix + "{" + userID + "}"
+}
+
+// checkTagValue verifies a value can be embedded in a tag, tags only allow
+// alphanumeric characters and '_', '@', '#', '.', ':' and '-'
+func checkTagValue(field, value string) error {
+	if value == "" {
+		return NewValidationError(field, "cannot be empty", value)
+	}
+	for _, r := range value {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("_@#.:-", r)) {
+			return NewValidationError(field, fmt.Sprintf("character %q isn't allowed in tags", r), value)
+		}
+	}
+	return nil
+}
+
+// readInstallations reads the installations the registrations belong to, skipping those already seen.
+// Installations deleted in the meantime are skipped.
+func (h *NotificationHub) readInstallations(ctx context.Context, pushChannel string, entries []registrationFeedEntry, seen map[string]bool) ([]*Installation, error) {
+	if seen == nil {
+		seen = map[string]bool{}
+	}
+	var installations []*Installation
+	for _, entry := range entries {
+		id := entry.installationID()
+		if id == "" || seen[id] || (pushChannel != "" && entry.handle() != pushChannel) {
+			continue
+		}
+		seen[id] = true
+
+		_, installation, err := h.Installation(ctx, id)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		installations = append(installations, installation)
+	}
+	return installations, nil
+}
+
+// tags returns the registration tags
+func (e registrationFeedEntry) tags() []string {
+	if e.Description.Tags == "" {
+		return nil
+	}
+	return strings.Split(e.Description.Tags, ",")
+}
+
+// hasTag identifies whether the registration has a tag
+func (e registrationFeedEntry) hasTag(tag string) bool {
+	for _, t := range e.tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// installationID returns the ID of the installation the registration belongs to, if any
+func (e registrationFeedEntry) installationID() string {
+	for _, tag := range e.tags() {
+		if strings.HasPrefix(tag, installationIDTagPrefix+"{") && strings.HasSuffix(tag, "}") {
+			return tag[len(installationIDTagPrefix)+1 : len(tag)-1]
+		}
+	}
+	return ""
+}
+
+// handle returns the registration PNS handle
+func (e registrationFeedEntry) handle() string {
+	d := e.Description
+	for _, handle := range []string{d.DeviceToken, d.FcmV1RegistrationID, d.ChannelURI, d.AdmRegistrationID, d.BaiduChannelID} {
+		if handle != "" {
+			return handle
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+	"github.com/koreset/azure-notifications-sdk-go/utils"
+)
+
+const exportContainerURI = "https://testhubstorage.blob.core.windows.net/export?sv=2015-07-08&sig=testsig"
+
+func Test_ExportInstallations(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		gotURI           string
+		exported         = strings.Join([]string{
+			`<FcmV1RegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>1</RegistrationId><Tags>tag1,$InstallationId:{fcmv1-installation-sample-id}</Tags><FcmV1RegistrationId>fcmv1_token_sample_here</FcmV1RegistrationId></FcmV1RegistrationDescription>`,
+			`<WindowsRegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>2</RegistrationId><Tags>tag1,$InstallationId:{wns-installation}</Tags><ChannelUri>https://wns</ChannelUri></WindowsRegistrationDescription>`,
+			`<AppleRegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>3</RegistrationId><Tags>tag1</Tags><DeviceToken>apple</DeviceToken></AppleRegistrationDescription>`,
+			`<AppleRegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>4</RegistrationId><Tags>tag2,$InstallationId:{other}</Tags><DeviceToken>other</DeviceToken></AppleRegistrationDescription>`,
+		}, "\n")
+		requested []string
+	)
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		requested = append(requested, req.Method+" "+req.URL.Path)
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/jobs"), strings.HasSuffix(req.URL.Path, "/jobs/1"):
+			data, e := ioutil.ReadFile("./fixtures/jobResult.xml")
+			return data, nil, e
+		case strings.HasSuffix(req.URL.Path, "/installations/fcmv1-installation-sample-id"):
+			data, e := ioutil.ReadFile("./fixtures/fcmv1InstallationResult.json")
+			return data, nil, e
+		}
+		return nil, &http.Response{StatusCode: http.StatusNotFound}, errors.New("Got unexpected response status code: 404")
+	}
+	nhub.SetBlobReader(utils.BlobReaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		gotURI = uri
+		return io.NopCloser(strings.NewReader(exported)), nil
+	}))
+
+	var ids []string
+	for installation, err := range nhub.ExportInstallations(context.Background(), exportContainerURI, &InstallationQuery{Tag: "tag1"}, &JobWaitOptions{InitialInterval: time.Millisecond}) {
+		if err != nil {
+			t.Fatalf(errfmt, "error", nil, err)
+		}
+		ids = append(ids, installation.InstallationID)
+	}
+
+	if !reflect.DeepEqual(ids, []string{"fcmv1-installation-sample-id"}) {
+		t.Errorf(errfmt, "installations", []string{"fcmv1-installation-sample-id"}, ids)
+	}
+	wantURI := "https://testhubstorage.blob.core.windows.net/export/1/Output.txt?sv=2015-07-08&sig=testsig"
+	if gotURI != wantURI {
+		t.Errorf(errfmt, "output file URI", wantURI, gotURI)
+	}
+	for _, r := range requested {
+		if strings.Contains(r, "/installations/other") {
+			t.Errorf(errfmt, "requests", "no request for installations without the tag", requested)
+		}
+	}
+}
@@ -16,6 +16,26 @@ const (
 	telemetryAPIVersionValue = "2016-07"
 
 	directParam = "direct"
+
+	// implicit tags the service adds to installations
+	installationIDTagPrefix = "$InstallationId:"
+	userIDTagPrefix         = "$UserId:"
+
+	// read-modify-write attempts of ModifyRegistration
+	maxModifyAttempts = 5
+
+	// custom data key holding the deep link of a MultiPlatformMessage
+	deepLinkKey = "deepLink"
+
+	// default tag prefix of devices using a locale
+	localeTagPrefix = "locale:"
+
+	// payload size limits of the push services, in bytes
+	maxApplePayloadSize   = 4 * 1024
+	maxFcmV1PayloadSize   = 4 * 1024
+	maxWindowsPayloadSize = 5 * 1024
+	maxKindlePayloadSize  = 6 * 1024
+	maxBaiduPayloadSize   = 4 * 1024
 )
 
 // API version helpers
@@ -103,4 +123,16 @@ const (
     </FcmV1TemplateRegistrationDescription>
   </content>
 </entry>`
+
+	// jobXMLString is the XML string for submitting an import or export job
+	// Replace {{Type}}, {{OutputContainerUri}} and {{ImportFileUri}} with the correct, XML escaped, values
+	jobXMLString string = `<?xml version="1.0" encoding="utf-8"?>
+<entry xmlns="http://www.w3.org/2005/Atom">
+  <content type="application/xml">
+    <NotificationHubJob xmlns:i="http://www.w3.org/2001/XMLSchema-instance" xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">
+      <Type>{{Type}}</Type>
+      <OutputContainerUri>{{OutputContainerUri}}</OutputContainerUri>{{ImportFileUri}}
+    </NotificationHubJob>
+  </content>
+</entry>`
 )
@@ -0,0 +1,130 @@
+package notificationhubs
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+)
+
+// maxJobFileLineSize is the longest registration line accepted by the JobFileReader
+const maxJobFileLineSize = 1024 * 1024
+
+type (
+	// JobFileWriter writes registrations in the line based format used by import jobs,
+	// one registration description per line. Like the registration methods of the hub it only supports
+	// Apple and FCM v1 registrations, with or without template; other targets are rejected by Write.
+	JobFileWriter struct {
+		w *bufio.Writer
+	}
+
+	// JobFileReader reads registrations in the line based format used by
+	// import job inputs and export job outputs
+	JobFileReader struct {
+		scanner *bufio.Scanner
+		line    int
+	}
+)
+
+// NewJobFileWriter initializes and returns a JobFileWriter pointer
+func NewJobFileWriter(w io.Writer) *JobFileWriter {
+	return &JobFileWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes one registration. Only Apple and FCM v1 registrations, with or without template, are supported.
+func (w *JobFileWriter) Write(r RegistrationContent) error {
+	if r.RegisteredDevice == nil {
+		return fmt.Errorf("registration has no device")
+	}
+
+	var element, handleElement string
+	switch r.Target {
+	case ApplePlatform:
+		element, handleElement = "AppleRegistrationDescription", "DeviceToken"
+	case AppleTemplatePlatform:
+		element, handleElement = "AppleTemplateRegistrationDescription", "DeviceToken"
+	case FcmV1Platform:
+		element, handleElement = "FcmV1RegistrationDescription", "FcmV1RegistrationId"
+	case FcmV1TemplatePlatform:
+		element, handleElement = "FcmV1TemplateRegistrationDescription", "FcmV1RegistrationId"
+	default:
+		return fmt.Errorf("registration target '%s' not implemented", r.Target)
+	}
+
+	var (
+		d    = r.RegisteredDevice
+		line strings.Builder
+	)
+	line.WriteString("<" + element + ` xmlns:i="http://www.w3.org/2001/XMLSchema-instance" xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">`)
+	if d.ExpirationTime != nil {
+		line.WriteString("<ExpirationTime>" + d.ExpirationTime.UTC().Format("2006-01-02T15:04:05.000Z") + "</ExpirationTime>")
+	}
+	if d.RegistrationID != "" {
+		line.WriteString("<RegistrationId>" + escapeXML(d.RegistrationID) + "</RegistrationId>")
+	}
+	if len(d.Tags) > 0 {
+		line.WriteString("<Tags>" + escapeXML(strings.Join(d.Tags, ",")) + "</Tags>")
+	}
+	line.WriteString("<" + handleElement + ">" + escapeXML(d.DeviceID) + "</" + handleElement + ">")
+	if r.Target == AppleTemplatePlatform || r.Target == FcmV1TemplatePlatform {
+		line.WriteString("<BodyTemplate>" + escapeXML(d.Template) + "</BodyTemplate>")
+	}
+	line.WriteString("</" + element + ">\n")
+
+	_, err := w.w.WriteString(line.String())
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer
+func (w *JobFileWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// NewJobFileReader initializes and returns a JobFileReader pointer
+func NewJobFileReader(r io.Reader) *JobFileReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJobFileLineSize)
+	return &JobFileReader{scanner: scanner}
+}
+
+// Read reads the next registration, returning io.EOF when the input is exhausted
+func (r *JobFileReader) Read() (*RegistrationContent, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var content RegistrationContent
+		if err := xml.Unmarshal([]byte("<content>"+line+"</content>"), &content); err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		content.normalize()
+		if content.RegisteredDevice == nil {
+			return nil, fmt.Errorf("line %d: unsupported registration", r.line)
+		}
+		return &content, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// All iterates over the remaining registrations. Iteration stops at the first error.
+func (r *JobFileReader) All() iter.Seq2[*RegistrationContent, error] {
+	return func(yield func(*RegistrationContent, error) bool) {
+		for {
+			content, err := r.Read()
+			if err == io.EOF {
+				return
+			}
+			if !yield(content, err) || err != nil {
+				return
+			}
+		}
+	}
+}
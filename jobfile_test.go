@@ -0,0 +1,82 @@
+package notificationhubs_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_JobFileRoundTrip(t *testing.T) {
+	var (
+		buf        = &bytes.Buffer{}
+		writer     = NewJobFileWriter(buf)
+		expiration = time.Date(2029, 4, 23, 9, 12, 50, 0, time.UTC)
+		contents   = []RegistrationContent{
+			{
+				Format: AppleFormat,
+				Target: ApplePlatform,
+				RegisteredDevice: &RegisteredDevice{
+					DeviceID:       "ABCDEF",
+					ExpirationTime: &expiration,
+					RegistrationID: "1",
+					Tags:           []string{"tag1", "tag&2"},
+				},
+			},
+			{
+				Format: Template,
+				Target: FcmV1TemplatePlatform,
+				RegisteredDevice: &RegisteredDevice{
+					DeviceID: "fcmv1_token",
+					Template: `{"message":{"notification":{"body":"$(message)"}}}`,
+				},
+			},
+		}
+	)
+
+	for _, content := range contents {
+		if err := writer.Write(content); err != nil {
+			t.Fatalf(errfmt, "write error", nil, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf(errfmt, "flush error", nil, err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf(errfmt, "lines", 2, lines)
+	}
+
+	var got []RegistrationContent
+	for content, err := range NewJobFileReader(buf).All() {
+		if err != nil {
+			t.Fatalf(errfmt, "read error", nil, err)
+		}
+		got = append(got, *content)
+	}
+	if !reflect.DeepEqual(got, contents) {
+		t.Errorf(errfmt, "registrations", contents, got)
+	}
+}
+
+func Test_JobFileWriterUnsupportedTarget(t *testing.T) {
+	writer := NewJobFileWriter(io.Discard)
+	err := writer.Write(RegistrationContent{Target: WindowsPlatform, RegisteredDevice: &RegisteredDevice{}})
+	if err == nil {
+		t.Errorf(errfmt, "error", "unsupported target", err)
+	}
+}
+
+func Test_JobFileReaderError(t *testing.T) {
+	reader := NewJobFileReader(strings.NewReader("\n<AppleRegistrationDescription><DeviceToken>A</DeviceToken></AppleRegistrationDescription>\n<broken\n"))
+
+	if content, err := reader.Read(); err != nil || content.RegisteredDevice.DeviceID != "A" {
+		t.Errorf(errfmt, "first registration", "A", content)
+	}
+	if _, err := reader.Read(); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf(errfmt, "error", "line 3", err)
+	}
+}
@@ -0,0 +1,193 @@
+package notificationhubs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+type (
+	// jobResult is the atom entry wrapping a job
+	jobResult struct {
+		Content struct {
+			Job *NotificationHubJob `xml:"NotificationHubJob"`
+		} `xml:"content"`
+	}
+
+	// jobsResult is the atom feed listing jobs
+	jobsResult struct {
+		Entries []jobResult `xml:"entry"`
+	}
+)
+
+// SubmitJob submits an import or export job to the Azure hub.
+// OutputContainerURI must be a SAS URI with write access to a blob container,
+// import jobs also require ImportFileURI pointing at a file written by a JobFileWriter.
+func (h *NotificationHub) SubmitJob(ctx context.Context, job NotificationHubJob) (raw []byte, result *NotificationHubJob, err error) {
+	var (
+		jobURL  = h.generateAPIURL("jobs")
+		headers = map[string]string{
+			"Content-Type": "application/atom+xml;type=entry;charset=utf-8",
+		}
+		importFile = ""
+	)
+
+	if !job.Type.IsValid() {
+		return nil, nil, fmt.Errorf("unknown job type '%s'", job.Type)
+	}
+	if job.OutputContainerURI == "" {
+		return nil, nil, errors.New("job output container URI cannot be empty")
+	}
+	if job.Type != ExportRegistrationsJob {
+		if job.ImportFileURI == "" {
+			return nil, nil, errors.New("job import file URI cannot be empty")
+		}
+		importFile = "\n      <ImportFileUri>" + escapeXML(job.ImportFileURI) + "</ImportFileUri>"
+	}
+
+	payload := strings.Replace(jobXMLString, "{{Type}}", string(job.Type), 1)
+	payload = strings.Replace(payload, "{{OutputContainerUri}}", escapeXML(job.OutputContainerURI), 1)
+	payload = strings.Replace(payload, "{{ImportFileUri}}", importFile, 1)
+
+	raw, _, err = h.exec(ctx, postMethod, jobURL, headers, bytes.NewBufferString(payload))
+	if err != nil {
+		return
+	}
+	result, err = parseJob(raw)
+	return
+}
+
+// Job reads one specific job
+func (h *NotificationHub) Job(ctx context.Context, jobID string) (raw []byte, job *NotificationHubJob, err error) {
+	raw, _, err = h.exec(ctx, getMethod, h.generateAPIURL(path.Join("jobs", jobID)), Headers{}, nil)
+	if err != nil {
+		return
+	}
+	job, err = parseJob(raw)
+	return
+}
+
+// Jobs reads all jobs
+func (h *NotificationHub) Jobs(ctx context.Context) (raw []byte, jobs []NotificationHubJob, err error) {
+	raw, _, err = h.exec(ctx, getMethod, h.generateAPIURL("jobs"), Headers{}, nil)
+	if err != nil {
+		return
+	}
+	var feed jobsResult
+	if err = xml.Unmarshal(raw, &feed); err != nil {
+		return
+	}
+	for _, entry := range feed.Entries {
+		if entry.Content.Job != nil {
+			jobs = append(jobs, *entry.Content.Job)
+		}
+	}
+	return
+}
+
+// WaitForJob polls the job until it completed or failed and returns the final job.
+// A failed job is returned together with an error. Use the context to limit the total time spent waiting.
+func (h *NotificationHub) WaitForJob(ctx context.Context, jobID string, opts *JobWaitOptions) (*NotificationHubJob, error) {
+	var (
+		o            = opts.pollOptions()
+		interval     = o.InitialInterval
+		lastStatus   NotificationHubJobStatus
+		lastProgress = -1.0
+	)
+
+	for {
+		_, job, err := h.Job(ctx, jobID)
+		if err != nil {
+			return nil, fmt.Errorf("notificationhubs.WaitForJob: %w", err)
+		}
+		if job.Status != lastStatus || job.Progress != lastProgress {
+			lastStatus, lastProgress = job.Status, job.Progress
+			if opts != nil && opts.OnProgress != nil {
+				opts.OnProgress(job)
+			}
+		}
+		switch job.Status {
+		case JobCompleted:
+			return job, nil
+		case JobFailed:
+			return job, fmt.Errorf("notificationhubs.WaitForJob: job %s failed: %s", jobID, job.Failure)
+		}
+
+		if interval, err = o.backoff(ctx, interval); err != nil {
+			return nil, fmt.Errorf("notificationhubs.WaitForJob: %w", err)
+		}
+	}
+}
+
+// pollOptions returns the polling intervals of the options, defaulted like the ones of WaitForNotification
+func (o *JobWaitOptions) pollOptions() WaitOptions {
+	if o == nil {
+		return (*WaitOptions)(nil).withDefaults()
+	}
+	return (&WaitOptions{InitialInterval: o.InitialInterval, MaxInterval: o.MaxInterval, Multiplier: o.Multiplier}).withDefaults()
+}
+
+// OutputProperty returns the value of an output property, or an empty string
+func (j *NotificationHubJob) OutputProperty(key string) string {
+	for _, prop := range j.OutputProperties {
+		if prop.Key == key {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// UnmarshalXML decodes a job, parsing the timestamps of the hub
+func (j *NotificationHubJob) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type job NotificationHubJob // without the UnmarshalXML method
+	var node struct {
+		job
+		CreatedAt *string `xml:"CreatedAt"`
+		UpdatedAt *string `xml:"UpdatedAt"`
+	}
+	if err := dec.DecodeElement(&node, &start); err != nil {
+		return err
+	}
+	*j = NotificationHubJob(node.job)
+
+	var err error
+	if j.CreatedAt, err = parseHubTime(node.CreatedAt); err != nil {
+		return fmt.Errorf("CreatedAt: %w", err)
+	}
+	if j.UpdatedAt, err = parseHubTime(node.UpdatedAt); err != nil {
+		return fmt.Errorf("UpdatedAt: %w", err)
+	}
+	return nil
+}
+
+// IsValid identifies whether the job type is valid
+func (t NotificationHubJobType) IsValid() bool {
+	return t == ExportRegistrationsJob ||
+		t == ImportCreateRegistrationsJob ||
+		t == ImportUpdateRegistrationsJob ||
+		t == ImportDeleteRegistrationsJob ||
+		t == ImportUpsertRegistrationsJob
+}
+
+// parseJob reads a job from an atom entry
+func parseJob(raw []byte) (*NotificationHubJob, error) {
+	var entry jobResult
+	if err := xml.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Content.Job == nil {
+		return nil, errors.New("response doesn't contain a job")
+	}
+	return entry.Content.Job, nil
+}
+
+// escapeXML escapes s for use as XML character data
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,174 @@
+package notificationhubs_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+const jobsURL = "https://testhub-ns.servicebus.windows.net/testhub/jobs?api-version=2016-07"
+
+func Test_SubmitJob(t *testing.T) {
+	nhub, mockClient := initTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if req.Method != postMethod {
+			t.Errorf(errfmt, "method", postMethod, req.Method)
+		}
+		if gotURL := req.URL.String(); gotURL != jobsURL {
+			t.Errorf(errfmt, "URL", jobsURL, gotURL)
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		for _, expected := range []string{
+			"<Type>ImportCreateRegistrations</Type>",
+			"<OutputContainerUri>https://testhubstorage.blob.core.windows.net/import?sv=2015-07-08&amp;sig=testsig</OutputContainerUri>",
+			"<ImportFileUri>https://testhubstorage.blob.core.windows.net/import/input.txt</ImportFileUri>",
+		} {
+			if !strings.Contains(string(body), expected) {
+				t.Errorf(errfmt, "body", expected, string(body))
+			}
+		}
+		data, e := ioutil.ReadFile("./fixtures/jobResult.xml")
+		return data, nil, e
+	}
+
+	_, job, err := nhub.SubmitJob(context.Background(), NotificationHubJob{
+		Type:               ImportCreateRegistrationsJob,
+		OutputContainerURI: "https://testhubstorage.blob.core.windows.net/import?sv=2015-07-08&sig=testsig",
+		ImportFileURI:      "https://testhubstorage.blob.core.windows.net/import/input.txt",
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if job.ID != "1" {
+		t.Errorf(errfmt, "job ID", "1", job.ID)
+	}
+}
+
+func Test_SubmitJobValidation(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		t.Errorf("Expected no request to be sent")
+		return nil, nil, nil
+	}
+
+	for _, job := range []NotificationHubJob{
+		{Type: "Unknown", OutputContainerURI: "https://container"},
+		{Type: ExportRegistrationsJob},
+		{Type: ImportDeleteRegistrationsJob, OutputContainerURI: "https://container"},
+	} {
+		if _, _, err := nhub.SubmitJob(context.Background(), job); err == nil {
+			t.Errorf(errfmt, "error", "validation error", err)
+		}
+	}
+}
+
+func Test_Job(t *testing.T) {
+	nhub, mockClient := initTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		wantURL := "https://testhub-ns.servicebus.windows.net/testhub/jobs/1?api-version=2016-07"
+		if gotURL := req.URL.String(); gotURL != wantURL {
+			t.Errorf(errfmt, "URL", wantURL, gotURL)
+		}
+		data, e := ioutil.ReadFile("./fixtures/jobResult.xml")
+		return data, nil, e
+	}
+
+	_, job, err := nhub.Job(context.Background(), "1")
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if job.Type != ExportRegistrationsJob || job.Status != JobCompleted || job.Progress != 100 {
+		t.Errorf(errfmt, "job", "completed export", job)
+	}
+	if got := job.OutputProperty(OutputFilePathProperty); got != "export/1/Output.txt" {
+		t.Errorf(errfmt, "output file", "export/1/Output.txt", got)
+	}
+	if got := job.OutputProperty(FailedFilePathProperty); got != "export/1/Failed.txt" {
+		t.Errorf(errfmt, "failed file", "export/1/Failed.txt", got)
+	}
+	wantCreated := time.Date(2019, 4, 23, 9, 12, 50, 123000000, time.UTC)
+	if job.CreatedAt == nil || !job.CreatedAt.Equal(wantCreated) {
+		t.Errorf(errfmt, "created at", wantCreated, job.CreatedAt)
+	}
+}
+
+func Test_JobInvalidTime(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		data, e := ioutil.ReadFile("./fixtures/jobResult.xml")
+		return bytes.Replace(data, []byte("2019-04-23T09:12:50.123Z"), []byte("yesterday"), 1), nil, e
+	}
+
+	if _, _, err := nhub.Job(context.Background(), "1"); err == nil || !strings.Contains(err.Error(), "CreatedAt") {
+		t.Errorf(errfmt, "error", "unrecognized CreatedAt", err)
+	}
+}
+
+func Test_Jobs(t *testing.T) {
+	nhub, mockClient := initTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if gotURL := req.URL.String(); gotURL != jobsURL {
+			t.Errorf(errfmt, "URL", jobsURL, gotURL)
+		}
+		data, e := ioutil.ReadFile("./fixtures/jobsResult.xml")
+		return data, nil, e
+	}
+
+	_, jobs, err := nhub.Jobs(context.Background())
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "1" || jobs[1].Status != JobRunning {
+		t.Errorf(errfmt, "jobs", "2 jobs", jobs)
+	}
+}
+
+func Test_WaitForJob(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            = 0
+		progress         []float64
+	)
+	jobFmt := `<entry xmlns="http://www.w3.org/2005/Atom"><content type="application/xml"><NotificationHubJob xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><JobId>1</JobId><Progress>%d</Progress><Type>ExportRegistrations</Type><Status>%s</Status><Failure>%s</Failure></NotificationHubJob></content></entry>`
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		switch calls {
+		case 1:
+			return []byte(fmt.Sprintf(jobFmt, 0, JobStarted, "")), nil, nil
+		case 2:
+			return []byte(fmt.Sprintf(jobFmt, 50, JobRunning, "")), nil, nil
+		default:
+			return []byte(fmt.Sprintf(jobFmt, 50, JobFailed, "output container not writable")), nil, nil
+		}
+	}
+
+	opts := JobWaitOptions{
+		InitialInterval: fastWaitOptions.InitialInterval,
+		MaxInterval:     fastWaitOptions.MaxInterval,
+		OnProgress: func(job *NotificationHubJob) {
+			progress = append(progress, job.Progress)
+		},
+	}
+
+	job, err := nhub.WaitForJob(context.Background(), "1", &opts)
+	if err == nil || !strings.Contains(err.Error(), "output container not writable") {
+		t.Errorf(errfmt, "error", "job failure", err)
+	}
+	if job == nil || job.Status != JobFailed {
+		t.Errorf(errfmt, "job", JobFailed, job)
+	}
+	if len(progress) != 3 {
+		t.Errorf(errfmt, "progress callbacks", 3, progress)
+	}
+}
@@ -0,0 +1,113 @@
+package notificationhubs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// SendLocalized sends the message once per locale of its catalog, to the devices of the audience tagged with the locale.
+// Locales are sent one after another, every locale has an entry in the result and failed locales are also returned as a MultiError.
+// Format the audience according to https://docs.microsoft.com/en-us/azure/notification-hubs/notification-hubs-tags-segment-push-message
+// or nil to reach every device with a locale tag
+func (h *NotificationHub) SendLocalized(ctx context.Context, msg *LocalizedMessage, audience *string) (*LocalizedResult, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("notificationhubs.SendLocalized: message cannot be nil")
+	}
+	locales, err := msg.Locales()
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.SendLocalized: %w", err)
+	}
+
+	var (
+		result = &LocalizedResult{Results: make(map[string]*LocaleSendResult, len(locales))}
+		errs   = NewMultiError()
+	)
+	for _, locale := range locales {
+		localeResult := &LocaleSendResult{Locale: locale, TagExpression: msg.TagExpression(audience, locale)}
+		result.Results[locale] = localeResult
+
+		if message, ok := msg.Messages[locale]; ok {
+			localeResult.Platforms, localeResult.Err = h.SendMultiPlatform(ctx, message, &localeResult.TagExpression)
+		} else {
+			var n *Notification
+			if n, localeResult.Err = msg.TemplateNotification(locale); localeResult.Err == nil {
+				_, localeResult.Telemetry, localeResult.Err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+					return h.send(ctx, n, &localeResult.TagExpression, nil)
+				})
+			}
+		}
+		if localeResult.Err != nil {
+			errs.Add(fmt.Errorf("notificationhubs.SendLocalized: %s: %w", locale, localeResult.Err))
+		}
+	}
+	return result, errs.ToError()
+}
+
+// Locales returns the locales of the catalog in a stable order.
+// A locale can have either native messages or template properties, not both.
+func (m *LocalizedMessage) Locales() ([]string, error) {
+	locales := slices.Collect(maps.Keys(m.Messages))
+	for locale := range m.Properties {
+		if _, ok := m.Messages[locale]; ok {
+			return nil, fmt.Errorf("locale '%s' has both messages and template properties", locale)
+		}
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	if len(locales) == 0 {
+		return nil, fmt.Errorf("message catalog is empty")
+	}
+	return locales, nil
+}
+
+// TagExpression returns the tag expression reaching the devices of the audience using the locale
+func (m *LocalizedMessage) TagExpression(audience *string, locale string) string {
+	tag := defaultLocaleTag(locale)
+	if m.LocaleTag != nil {
+		tag = m.LocaleTag(locale)
+	}
+	if audience == nil || *audience == "" {
+		return tag
+	}
+	return "(" + *audience + ") && " + tag
+}
+
+// TemplateNotification returns the template notification carrying the template properties of a locale
+func (m *LocalizedMessage) TemplateNotification(locale string) (*Notification, error) {
+	properties, ok := m.Properties[locale]
+	if !ok {
+		return nil, fmt.Errorf("locale '%s' has no template properties", locale)
+	}
+	payload, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+	n, err := newNotification(Template, payload)
+	if err != nil {
+		return nil, err
+	}
+	if m.IdempotencyKey != "" {
+		n.IdempotencyKey = m.IdempotencyKey + ":" + locale
+	}
+	return n, nil
+}
+
+// Failed returns the locales that failed to send
+func (r *LocalizedResult) Failed() []string {
+	var failed []string
+	for locale, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, locale)
+		}
+	}
+	slices.Sort(failed)
+	return failed
+}
+
+// defaultLocaleTag is the tag of devices using a locale when LocalizedMessage.LocaleTag isn't set
+func defaultLocaleTag(locale string) string {
+	return localeTagPrefix + locale
+}
@@ -0,0 +1,142 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_LocalizedMessage(t *testing.T) {
+	audience := "sports || news"
+	msg := &LocalizedMessage{
+		Messages:   map[string]*MultiPlatformMessage{"en": {Title: "Goal"}},
+		Properties: map[string]map[string]string{"pt-BR": {"title": "Gol"}},
+	}
+
+	locales, err := msg.Locales()
+	if err != nil || !reflect.DeepEqual(locales, []string{"en", "pt-BR"}) {
+		t.Errorf(errfmt, "locales", []string{"en", "pt-BR"}, locales)
+	}
+	if got := msg.TagExpression(&audience, "en"); got != "(sports || news) && locale:en" {
+		t.Errorf(errfmt, "tag expression", "(sports || news) && locale:en", got)
+	}
+	if got := msg.TagExpression(nil, "en"); got != "locale:en" {
+		t.Errorf(errfmt, "tag expression", "locale:en", got)
+	}
+	msg.LocaleTag = func(locale string) string { return "lang_" + strings.ReplaceAll(locale, "-", "_") }
+	if got := msg.TagExpression(nil, "pt-BR"); got != "lang_pt_BR" {
+		t.Errorf(errfmt, "custom tag expression", "lang_pt_BR", got)
+	}
+
+	n, err := msg.TemplateNotification("pt-BR")
+	if err != nil || n.Format != Template || string(n.Payload) != `{"title":"Gol"}` {
+		t.Errorf(errfmt, "template notification", `{"title":"Gol"}`, n)
+	}
+
+	msg.Properties["en"] = map[string]string{}
+	if _, err = msg.Locales(); err == nil {
+		t.Errorf(errfmt, "error", "locale with messages and properties", nil)
+	}
+	if _, err = (&LocalizedMessage{}).Locales(); err == nil {
+		t.Errorf(errfmt, "error", "empty catalog", nil)
+	}
+}
+
+func Test_SendLocalized(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		mu               sync.Mutex
+		sent             = map[string][]string{}
+		audience         = "sports"
+	)
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		tags := req.Header.Get("ServiceBusNotification-Tags")
+		body, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		sent[tags] = append(sent[tags], req.Header.Get("ServiceBusNotification-Format"))
+		mu.Unlock()
+		if strings.Contains(string(body), "fail") {
+			return nil, &http.Response{StatusCode: http.StatusBadRequest}, errors.New("Got unexpected response status code: 400")
+		}
+		return nil, &http.Response{
+			StatusCode: http.StatusCreated,
+			Header:     http.Header{"Location": {"https://testhub-ns.servicebus.windows.net/testhub/messages/1?api-version=2016-07"}},
+		}, nil
+	}
+
+	result, err := nhub.SendLocalized(context.Background(), &LocalizedMessage{
+		Messages: map[string]*MultiPlatformMessage{
+			"en": {Title: "Goal", Platforms: []NotificationFormat{AppleFormat, FcmV1Format}},
+		},
+		Properties: map[string]map[string]string{
+			"de": {"title": "Tor"},
+			"fr": {"title": "fail"},
+		},
+	}, &audience)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 {
+		t.Fatalf(errfmt, "error", "fr failure", err)
+	}
+	if failed := result.Failed(); !reflect.DeepEqual(failed, []string{"fr"}) {
+		t.Errorf(errfmt, "failed locales", []string{"fr"}, failed)
+	}
+	if result.Results["en"].Platforms == nil || len(result.Results["en"].Platforms.Telemetry()) != 2 {
+		t.Errorf(errfmt, "en platforms", 2, result.Results["en"].Platforms)
+	}
+	if result.Results["de"].Telemetry == nil {
+		t.Errorf(errfmt, "de telemetry", "set", nil)
+	}
+
+	expected := map[string][]string{
+		"(sports) && locale:en": {"apple", "fcmv1"},
+		"(sports) && locale:de": {"template"},
+		"(sports) && locale:fr": {"template"},
+	}
+	for _, formats := range sent {
+		sort.Strings(formats)
+	}
+	if !reflect.DeepEqual(sent, expected) {
+		t.Errorf(errfmt, "sends", expected, sent)
+	}
+}
+
+func Test_SendLocalizedDedup(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		sent             []string
+		failFrench       = true
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		tags := req.Header.Get("ServiceBusNotification-Tags")
+		sent = append(sent, tags)
+		if tags == "locale:fr" && failFrench {
+			return nil, &http.Response{StatusCode: http.StatusBadRequest}, errors.New("Got unexpected response status code: 400")
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+	msg := &LocalizedMessage{
+		Properties:     map[string]map[string]string{"de": {"title": "Tor"}, "fr": {"title": "But"}},
+		IdempotencyKey: "goal-7",
+	}
+
+	if _, err := nhub.SendLocalized(context.Background(), msg, nil); err == nil {
+		t.Fatalf(errfmt, "error", "fr failure", err)
+	}
+	failFrench, sent = false, nil
+	if _, err := nhub.SendLocalized(context.Background(), msg, nil); err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if !reflect.DeepEqual(sent, []string{"locale:fr"}) {
+		t.Errorf(errfmt, "resent locales", []string{"locale:fr"}, sent)
+	}
+}
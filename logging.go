@@ -0,0 +1,93 @@
+package notificationhubs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/koreset/azure-notifications-sdk-go/utils"
+)
+
+// defaultLogOptions log successful requests at debug level and failed ones as warnings
+var defaultLogOptions = LogOptions{SuccessLevel: slog.LevelDebug, FailureLevel: slog.LevelWarn}
+
+// SetLogger makes the hub log every request to logger, nil disables logging.
+// Credentials and device handles are never logged: the Authorization header isn't, SAS signatures in URLs are redacted,
+// device handle headers are hashed and failures are logged by status, error code and error type, as error messages
+// can quote response bodies. With nil opts successful requests are logged at debug level, failures as warnings.
+func (h *NotificationHub) SetLogger(logger *slog.Logger, opts *LogOptions) {
+	h.logger = logger
+	h.logOptions = defaultLogOptions
+	if opts != nil {
+		h.logOptions = *opts
+	}
+}
+
+// logRequest logs a completed request
+func (h *NotificationHub) logRequest(ctx context.Context, operation Operation, req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	if h.logger == nil {
+		return
+	}
+	level := h.logOptions.SuccessLevel
+	if err != nil {
+		level = h.logOptions.FailureLevel
+	}
+	if !h.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("operation", string(operation)),
+		slog.String("method", req.Method),
+		slog.String("url", utils.RedactURL(req.URL.String())),
+		slog.Duration("latency", latency),
+		slog.Int("attempt", RequestAttempt(ctx)),
+	}
+	if handle := req.Header.Get("ServiceBusNotification-DeviceHandle"); handle != "" {
+		attrs = append(attrs, slog.String("deviceHandle", utils.HashHandle(handle)))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if requestID := resp.Header.Get("x-ms-request-id"); requestID != "" {
+			attrs = append(attrs, slog.String("requestId", requestID))
+		}
+		if trackingID := resp.Header.Get("TrackingId"); trackingID != "" {
+			attrs = append(attrs, slog.String("trackingId", trackingID))
+		}
+	}
+	if err != nil {
+		var hubErr *NotificationHubError
+		if errors.As(err, &hubErr) {
+			attrs = append(attrs, slog.String("errorCode", string(hubErr.Code)))
+		} else {
+			attrs = append(attrs, slog.String("errorType", errorType(err)))
+		}
+	}
+
+	message := "notification hub request"
+	if err != nil {
+		message = "notification hub request failed"
+	}
+	h.logger.LogAttrs(ctx, level, message, attrs...)
+}
+
+// errorType names the type of the innermost error of err
+func errorType(err error) string {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = inner
+	}
+}
+
+// logStoreError logs a dispatcher store failure, which may deliver a notification twice
+func (h *NotificationHub) logStoreError(err error) {
+	if h.logger != nil {
+		h.logger.Error("notification dispatcher store failed", slog.String("error", err.Error()))
+	}
+}
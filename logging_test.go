@@ -0,0 +1,123 @@
+package notificationhubs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_SetLogger(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		buf              bytes.Buffer
+		deviceHandle     = "ABCDEF0123456789"
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+	)
+	nhub.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), nil)
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{
+			StatusCode: http.StatusCreated,
+			Header: http.Header{
+				"X-Ms-Request-Id": []string{"request-1"},
+				"Trackingid":      []string{"tracking-1"},
+			},
+		}, nil
+	}
+	if _, _, err := nhub.SendDirect(context.Background(), notification, deviceHandle); err != nil {
+		t.Fatalf(errfmt, "SendDirect error", nil, err)
+	}
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusNotFound}, errors.New("Got unexpected response status code: 404, installation with push channel " + deviceHandle + " not found")
+	}
+	_, _, _ = nhub.Installation(context.Background(), "installation-1")
+
+	output := buf.String()
+	for _, secret := range []string{deviceHandle, "testAccessKey", "SharedAccessSignature"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("log output contains %q: %s", secret, output)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		t.Fatalf(errfmt, "log lines", 2, len(lines))
+	}
+	var sent, failed map[string]interface{}
+	_ = json.Unmarshal([]byte(lines[0]), &sent)
+	_ = json.Unmarshal([]byte(lines[1]), &failed)
+
+	want := map[string]interface{}{
+		"level":      "DEBUG",
+		"operation":  string(OperationSendDirect),
+		"method":     http.MethodPost,
+		"status":     float64(http.StatusCreated),
+		"requestId":  "request-1",
+		"trackingId": "tracking-1",
+		"attempt":    float64(1),
+	}
+	for key, value := range want {
+		if sent[key] != value {
+			t.Errorf(errfmt, key, value, sent[key])
+		}
+	}
+	if handle, _ := sent["deviceHandle"].(string); !strings.HasPrefix(handle, "sha256:") {
+		t.Errorf(errfmt, "deviceHandle", "sha256:...", handle)
+	}
+
+	want = map[string]interface{}{
+		"level":     "WARN",
+		"operation": string(OperationInstallation),
+		"status":    float64(http.StatusNotFound),
+		"errorCode": string(ErrorCodeRegistrationNotFound),
+	}
+	for key, value := range want {
+		if failed[key] != value {
+			t.Errorf(errfmt, key, value, failed[key])
+		}
+	}
+	if _, ok := failed["error"]; ok {
+		t.Errorf(errfmt, "error message", "not logged", failed["error"])
+	}
+
+	buf.Reset()
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, nil, context.DeadlineExceeded
+	}
+	_, _, _ = nhub.Installation(context.Background(), "installation-1")
+	if !strings.Contains(buf.String(), `"errorType":"context.deadlineExceededError"`) {
+		t.Errorf(errfmt, "transport failure", "errorType", buf.String())
+	}
+}
+
+func Test_SetLoggerLevels(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		buf              bytes.Buffer
+	)
+	nhub.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)), &LogOptions{SuccessLevel: slog.LevelDebug, FailureLevel: slog.LevelError})
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusNoContent}, nil
+	}
+	_ = nhub.Uninstall(context.Background(), "installation-1")
+	if buf.Len() != 0 {
+		t.Errorf(errfmt, "debug output", "", buf.String())
+	}
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusInternalServerError}, errors.New("Got unexpected response status code: 500")
+	}
+	_ = nhub.Uninstall(context.Background(), "installation-1")
+	if !strings.Contains(buf.String(), "level=ERROR") || !strings.Contains(buf.String(), "operation="+string(OperationUninstall)) {
+		t.Errorf(errfmt, "error output", "level=ERROR operation=Uninstall", buf.String())
+	}
+}
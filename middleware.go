@@ -0,0 +1,83 @@
+package notificationhubs
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Use appends middlewares to the chain intercepting the requests of the hub.
+// Middlewares run in the order they were added, after retries and before the built-in logging,
+// circuit breaker, rate limiting and authorization. They see the requests of every attempt.
+// See the otelhubs package for OpenTelemetry instrumentation.
+func (h *NotificationHub) Use(middlewares ...Middleware) {
+	h.middlewares = append(h.middlewares, middlewares...)
+}
+
+// handler chains the middlewares of the hub in front of its HTTP client
+func (h *NotificationHub) handler() RequestHandler {
+	chain := make([]Middleware, 0, len(h.middlewares)+5)
+	if h.retryOptions != nil {
+		chain = append(chain, h.retryMiddleware)
+	}
+	chain = append(chain, h.middlewares...)
+	chain = append(chain, h.loggingMiddleware, h.circuitBreakerMiddleware, h.rateLimitMiddleware, h.authMiddleware)
+
+	handler := h.transport
+	for _, middleware := range slices.Backward(chain) {
+		handler = middleware(handler)
+	}
+	return handler
+}
+
+// transport sends a request with the HTTP client, turning error responses into NotificationHubErrors
+func (h *NotificationHub) transport(_ Operation, req *http.Request) ([]byte, *http.Response, error) {
+	raw, resp, err := h.client.Exec(req)
+	if err != nil && resp != nil && resp.StatusCode >= http.StatusMultipleChoices {
+		err = newErrorFromFailedRequest(resp, err)
+	}
+	return raw, resp, err
+}
+
+// authMiddleware signs requests with a fresh shared access signature
+func (h *NotificationHub) authMiddleware(next RequestHandler) RequestHandler {
+	return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+		req.Header.Set("Authorization", h.generateSasToken())
+		return next(operation, req)
+	}
+}
+
+// loggingMiddleware logs requests
+func (h *NotificationHub) loggingMiddleware(next RequestHandler) RequestHandler {
+	return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+		start := time.Now()
+		raw, resp, err := next(operation, req)
+		h.logRequest(req.Context(), operation, req, resp, err, time.Since(start))
+		return raw, resp, err
+	}
+}
+
+// circuitBreakerMiddleware fails fast while the circuit breaker is open
+func (h *NotificationHub) circuitBreakerMiddleware(next RequestHandler) RequestHandler {
+	return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+		probe, err := h.circuitBreaker.allow()
+		if err != nil {
+			return nil, nil, err
+		}
+		raw, resp, err := next(operation, req)
+		h.circuitBreaker.record(probe, err)
+		return raw, resp, err
+	}
+}
+
+// rateLimitMiddleware waits for a token of the operation class and adapts the rate to throttling
+func (h *NotificationHub) rateLimitMiddleware(next RequestHandler) RequestHandler {
+	return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+		if err := h.waitRateLimit(req.Context(), operation); err != nil {
+			return nil, nil, err
+		}
+		raw, resp, err := next(operation, req)
+		h.observeRateLimit(operation, err)
+		return raw, resp, err
+	}
+}
@@ -0,0 +1,189 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_Use(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		order            []string
+		operations       []Operation
+	)
+	trace := func(name string) Middleware {
+		return func(next RequestHandler) RequestHandler {
+			return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+				order = append(order, name)
+				operations = append(operations, operation)
+				req.Header.Set("X-Middleware", name)
+				return next(operation, req)
+			}
+		}
+	}
+	nhub.Use(trace("first"), trace("second"))
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if got := req.Header.Get("X-Middleware"); got != "second" {
+			t.Errorf(errfmt, "X-Middleware header", "second", got)
+		}
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "SharedAccessSignature ") {
+			t.Errorf(errfmt, "Authorization header", "SharedAccessSignature ...", req.Header.Get("Authorization"))
+		}
+		return nil, &http.Response{StatusCode: http.StatusNoContent}, nil
+	}
+	if err := nhub.Uninstall(context.Background(), "installation-1"); err != nil {
+		t.Fatalf(errfmt, "Uninstall error", nil, err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(order, want) {
+		t.Errorf(errfmt, "order", want, order)
+	}
+	if want := []Operation{OperationUninstall, OperationUninstall}; !reflect.DeepEqual(operations, want) {
+		t.Errorf(errfmt, "operations", want, operations)
+	}
+}
+
+func Test_UseShortCircuit(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            int
+		cached           = []byte(`{"installationId":"installation-1","platform":"apns","pushChannel":"token"}`)
+	)
+	nhub.Use(func(next RequestHandler) RequestHandler {
+		return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+			if operation == OperationInstallation {
+				return cached, &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+			}
+			return next(operation, req)
+		}
+	})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		return nil, &http.Response{StatusCode: http.StatusNoContent}, nil
+	}
+
+	_, installation, err := nhub.Installation(context.Background(), "installation-1")
+	if err != nil {
+		t.Fatalf(errfmt, "Installation error", nil, err)
+	}
+	if installation.PushChannel != "token" || calls != 0 {
+		t.Errorf(errfmt, "short-circuited installation", "token, 0 calls", installation.PushChannel)
+	}
+}
+
+func Test_SetRetry(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		attempts         []int
+		bodies           []string
+		statuses         = []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK}
+	)
+	nhub.SetRetry(&RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	nhub.Use(func(next RequestHandler) RequestHandler {
+		return func(operation Operation, req *http.Request) ([]byte, *http.Response, error) {
+			attempts = append(attempts, RequestAttempt(req.Context()))
+			return next(operation, req)
+		}
+	})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		status := statuses[len(bodies)-1]
+		if status >= http.StatusMultipleChoices {
+			return nil, &http.Response{StatusCode: status}, errors.New("Got unexpected response status code")
+		}
+		return nil, &http.Response{StatusCode: status}, nil
+	}
+
+	installation := Installation{InstallationID: "installation-1", Platform: APNSPlatform, PushChannel: "token"}
+	if err := nhub.Install(context.Background(), installation); err != nil {
+		t.Fatalf(errfmt, "Install error", nil, err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(attempts, want) {
+		t.Errorf(errfmt, "attempts", want, attempts)
+	}
+	if bodies[0] == "" || bodies[1] != bodies[0] || bodies[2] != bodies[0] {
+		t.Errorf(errfmt, "replayed bodies", bodies[0], bodies)
+	}
+}
+
+func Test_SetRetryClientErrors(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            int
+		status           int
+	)
+	nhub.SetRetry(&RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		return nil, &http.Response{StatusCode: status}, errors.New("Got unexpected response status code")
+	}
+
+	tests := []struct {
+		status int
+		calls  int
+	}{
+		{http.StatusMovedPermanently, 1},
+		{http.StatusMethodNotAllowed, 1},
+		{http.StatusGone, 1},
+		{http.StatusUnprocessableEntity, 1},
+		{http.StatusBadGateway, 3},
+	}
+	for _, test := range tests {
+		calls, status = 0, test.status
+		if err := nhub.Uninstall(context.Background(), "installation-1"); err == nil {
+			t.Errorf(errfmt, "Uninstall error", "error", nil)
+		}
+		if calls != test.calls {
+			t.Errorf(errfmt, "calls for "+http.StatusText(test.status), test.calls, calls)
+		}
+	}
+}
+
+func Test_SetRetrySends(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+		calls            int
+		status           int
+	)
+	nhub.SetRetry(&RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		return nil, &http.Response{StatusCode: status}, errors.New("Got unexpected response status code")
+	}
+
+	tests := []struct {
+		status int
+		calls  int
+	}{
+		{http.StatusInternalServerError, 1}, // the hub may have sent the notification
+		{http.StatusTooManyRequests, 3},
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusBadRequest, 1},
+	}
+	for _, test := range tests {
+		calls, status = 0, test.status
+		if _, _, err := nhub.Send(context.Background(), notification, nil); err == nil {
+			t.Errorf(errfmt, "Send error", "error", nil)
+		}
+		if calls != test.calls {
+			t.Errorf(errfmt, "calls for "+http.StatusText(test.status), test.calls, calls)
+		}
+	}
+
+	nhub.SetRetry(nil)
+	calls, status = 0, http.StatusServiceUnavailable
+	_, _, _ = nhub.Send(context.Background(), notification, nil)
+	if calls != 1 {
+		t.Errorf(errfmt, "calls without retries", 1, calls)
+	}
+}
@@ -0,0 +1,233 @@
+package notificationhubs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultMultiPlatformFormats are the platforms a MultiPlatformMessage is sent to by default
+var defaultMultiPlatformFormats = []NotificationFormat{AppleFormat, FcmV1Format, WindowsFormat}
+
+// SendMultiPlatform renders the message natively for each of its platforms and sends the notifications concurrently.
+// Every platform has an entry in the result, failed platforms are also returned as a MultiError.
+// Each platform send is deduplicated and validated like Send, listing a platform twice is an error.
+// Format tags according to https://docs.microsoft.com/en-us/azure/notification-hubs/notification-hubs-tags-segment-push-message
+// or nil if no tags should be used
+func (h *NotificationHub) SendMultiPlatform(ctx context.Context, msg *MultiPlatformMessage, tags *string) (*MultiPlatformResult, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("notificationhubs.SendMultiPlatform: message cannot be nil")
+	}
+	formats := msg.Platforms
+	if len(formats) == 0 {
+		formats = defaultMultiPlatformFormats
+	}
+	for i, format := range formats {
+		if slices.Contains(formats[:i], format) {
+			return nil, fmt.Errorf("notificationhubs.SendMultiPlatform: %w", NewValidationError("Platforms", "lists a platform twice", format))
+		}
+	}
+
+	var (
+		result = &MultiPlatformResult{Results: make(map[NotificationFormat]*PlatformSendResult, len(formats))}
+		wg     sync.WaitGroup
+	)
+	for _, format := range formats {
+		platformResult := &PlatformSendResult{Format: format}
+		result.Results[format] = platformResult
+
+		if platformResult.Notification, platformResult.Err = msg.Render(format); platformResult.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := platformResult.Notification
+			_, platformResult.Telemetry, platformResult.Err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+				return h.send(ctx, n, tags, nil)
+			})
+		}()
+	}
+	wg.Wait()
+
+	errs := NewMultiError()
+	for _, format := range formats {
+		if err := result.Results[format].Err; err != nil {
+			errs.Add(fmt.Errorf("notificationhubs.SendMultiPlatform: %s: %w", format, err))
+		}
+	}
+	return result, errs.ToError()
+}
+
+// Render builds the platform native notification for the message, applying the platform override.
+// Apple, FCM v1, Windows, Kindle and Baidu formats are supported.
+func (m *MultiPlatformMessage) Render(format NotificationFormat) (*Notification, error) {
+	n, err := m.render(format)
+	if err != nil {
+		return nil, err
+	}
+	if m.IdempotencyKey != "" {
+		n.IdempotencyKey = m.IdempotencyKey + ":" + string(format)
+	}
+	return n, nil
+}
+
+// render builds the platform native notification for the message
+func (m *MultiPlatformMessage) render(format NotificationFormat) (*Notification, error) {
+	var (
+		override = m.Overrides[format]
+		msg      = *m
+	)
+	if len(override.Payload) > 0 {
+		return newNotification(format, override.Payload)
+	}
+	if override.Title != "" {
+		msg.Title = override.Title
+	}
+	if override.Body != "" {
+		msg.Body = override.Body
+	}
+	if override.Badge != nil {
+		msg.Badge = override.Badge
+	}
+	if len(override.Data) > 0 {
+		msg.Data = maps.Clone(m.Data)
+		if msg.Data == nil {
+			msg.Data = make(map[string]string, len(override.Data))
+		}
+		maps.Copy(msg.Data, override.Data)
+	}
+
+	var (
+		payload []byte
+		err     error
+	)
+	switch format {
+	case AppleFormat:
+		payload, err = msg.renderApple()
+	case FcmV1Format:
+		payload, err = msg.renderFcmV1()
+	case WindowsFormat:
+		payload, err = msg.renderWindows()
+	case KindleFormat:
+		payload, err = marshalPayload(map[string]interface{}{"data": msg.flatData()})
+	case BaiduFormat:
+		payload, err = marshalPayload(map[string]interface{}{"title": msg.Title, "description": msg.Body, "custom_content": msg.customData()})
+	default:
+		return nil, fmt.Errorf("format '%s' can't be rendered", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newNotification(format, payload)
+}
+
+// renderApple renders an APNS payload, custom data is added next to aps
+func (m MultiPlatformMessage) renderApple() ([]byte, error) {
+	aps := map[string]interface{}{
+		"alert": map[string]string{"title": m.Title, "body": m.Body},
+	}
+	if m.Badge != nil {
+		aps["badge"] = *m.Badge
+	}
+	payload := map[string]interface{}{}
+	for key, value := range m.customData() {
+		payload[key] = value
+	}
+	payload["aps"] = aps
+	return marshalPayload(payload)
+}
+
+// renderFcmV1 renders an FCM v1 payload, the badge becomes the Android notification count
+func (m MultiPlatformMessage) renderFcmV1() ([]byte, error) {
+	message := map[string]interface{}{
+		"notification": map[string]string{"title": m.Title, "body": m.Body},
+	}
+	if data := m.customData(); len(data) > 0 {
+		message["data"] = data
+	}
+	if m.Badge != nil {
+		message["android"] = map[string]interface{}{
+			"notification": map[string]int{"notification_count": *m.Badge},
+		}
+	}
+	return marshalPayload(map[string]interface{}{"message": message})
+}
+
+// renderWindows renders a WNS toast, the deep link becomes the launch argument.
+// WNS badges are separate notifications, so the badge and custom data aren't rendered.
+func (m MultiPlatformMessage) renderWindows() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("<toast")
+	if m.DeepLink != "" {
+		b.WriteString(` launch="`)
+		if err := xml.EscapeText(&b, []byte(m.DeepLink)); err != nil {
+			return nil, err
+		}
+		b.WriteString(`"`)
+	}
+	b.WriteString(`><visual><binding template="ToastGeneric">`)
+	for _, text := range []string{m.Title, m.Body} {
+		b.WriteString("<text>")
+		if err := xml.EscapeText(&b, []byte(text)); err != nil {
+			return nil, err
+		}
+		b.WriteString("</text>")
+	}
+	b.WriteString("</binding></visual></toast>")
+	return []byte(b.String()), nil
+}
+
+// marshalPayload encodes a JSON payload without HTML escaping, which only wastes payload bytes
+func marshalPayload(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// customData returns the data with the deep link
+func (m MultiPlatformMessage) customData() map[string]string {
+	data := maps.Clone(m.Data)
+	if m.DeepLink != "" {
+		if data == nil {
+			data = make(map[string]string, 1)
+		}
+		data[deepLinkKey] = m.DeepLink
+	}
+	return data
+}
+
+// flatData returns title, body, badge, deep link and data as a single map, for platforms without a notification structure
+func (m MultiPlatformMessage) flatData() map[string]string {
+	data := m.customData()
+	if data == nil {
+		data = make(map[string]string, 3)
+	}
+	data["title"], data["body"] = m.Title, m.Body
+	if m.Badge != nil {
+		data["badge"] = strconv.Itoa(*m.Badge)
+	}
+	return data
+}
+
+// Telemetry returns the telemetry of every platform sent successfully
+func (r *MultiPlatformResult) Telemetry() map[NotificationFormat]*NotificationTelemetry {
+	telemetry := make(map[NotificationFormat]*NotificationTelemetry, len(r.Results))
+	for format, result := range r.Results {
+		if result.Err == nil && result.Telemetry != nil {
+			telemetry[format] = result.Telemetry
+		}
+	}
+	return telemetry
+}
@@ -0,0 +1,155 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_MultiPlatformMessageRender(t *testing.T) {
+	badge := 3
+	msg := &MultiPlatformMessage{
+		Title:    "Score",
+		Body:     "2 - 1",
+		Badge:    &badge,
+		Data:     map[string]string{"match": "42"},
+		DeepLink: "app://match/42?tab=live&x=<1>",
+		Overrides: map[NotificationFormat]PlatformOverride{
+			FcmV1Format:  {Title: "Android score"},
+			KindleFormat: {Payload: []byte(`{"data":{"custom":"1"}}`)},
+		},
+	}
+
+	tests := []struct {
+		format   NotificationFormat
+		expected string
+	}{
+		{AppleFormat, `{"aps":{"alert":{"body":"2 - 1","title":"Score"},"badge":3},"deepLink":"app://match/42?tab=live&x=<1>","match":"42"}`},
+		{FcmV1Format, `{"message":{"android":{"notification":{"notification_count":3}},"data":{"deepLink":"app://match/42?tab=live&x=<1>","match":"42"},"notification":{"body":"2 - 1","title":"Android score"}}}`},
+		{WindowsFormat, `<toast launch="app://match/42?tab=live&amp;x=&lt;1&gt;"><visual><binding template="ToastGeneric"><text>Score</text><text>2 - 1</text></binding></visual></toast>`},
+		{KindleFormat, `{"data":{"custom":"1"}}`},
+		{BaiduFormat, `{"custom_content":{"deepLink":"app://match/42?tab=live&x=<1>","match":"42"},"description":"2 - 1","title":"Score"}`},
+	}
+	for _, test := range tests {
+		n, err := msg.Render(test.format)
+		if err != nil {
+			t.Fatalf(errfmt, string(test.format)+" error", nil, err)
+		}
+		if n.Format != test.format || string(n.Payload) != test.expected {
+			t.Errorf(errfmt, string(test.format)+" payload", test.expected, string(n.Payload))
+		}
+	}
+
+	if _, err := msg.Render(WindowsPhoneFormat); err == nil {
+		t.Errorf(errfmt, "error", "unsupported format", nil)
+	}
+}
+
+func Test_SendMultiPlatform(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		mu               sync.Mutex
+		formats          = map[string]http.Header{}
+		tags             = "sports"
+	)
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		format := req.Header.Get("ServiceBusNotification-Format")
+		mu.Lock()
+		formats[format] = req.Header
+		mu.Unlock()
+		if format == string(WindowsFormat) {
+			return nil, &http.Response{StatusCode: http.StatusBadRequest}, errors.New("Got unexpected response status code: 400")
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if !json.Valid(body) {
+			t.Errorf(errfmt, "payload", "JSON", string(body))
+		}
+		return nil, &http.Response{
+			StatusCode: http.StatusCreated,
+			Header:     http.Header{"Location": {"https://testhub-ns.servicebus.windows.net/testhub/messages/" + format + "?api-version=2016-07"}},
+		}, nil
+	}
+
+	result, err := nhub.SendMultiPlatform(context.Background(), &MultiPlatformMessage{Title: "title", Body: "body"}, &tags)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || !strings.Contains(multi.Errors[0].Error(), "windows") {
+		t.Fatalf(errfmt, "error", "windows failure", err)
+	}
+	if len(result.Results) != 3 || result.Results[WindowsFormat].Err == nil {
+		t.Errorf(errfmt, "results", "3 platforms, windows failed", result.Results)
+	}
+	telemetry := result.Telemetry()
+	ids := map[NotificationFormat]string{}
+	for format, tm := range telemetry {
+		ids[format] = tm.NotificationMessageID
+	}
+	if expected := map[NotificationFormat]string{AppleFormat: "apple", FcmV1Format: "fcmv1"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf(errfmt, "telemetry", expected, ids)
+	}
+	for format, header := range formats {
+		if header.Get("ServiceBusNotification-Tags") != tags {
+			t.Errorf(errfmt, format+" tags", tags, header.Get("ServiceBusNotification-Tags"))
+		}
+	}
+	if got := formats[string(WindowsFormat)].Get("X-WNS-Type"); got != "wns/toast" {
+		t.Errorf(errfmt, "X-WNS-Type", "wns/toast", got)
+	}
+}
+
+func Test_SendMultiPlatformDedupAndValidation(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		mu               sync.Mutex
+		sent             []string
+		failWindows      = true
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		format := req.Header.Get("ServiceBusNotification-Format")
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, format)
+		if format == string(WindowsFormat) && failWindows {
+			return nil, &http.Response{StatusCode: http.StatusBadRequest}, errors.New("Got unexpected response status code: 400")
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+	msg := &MultiPlatformMessage{Title: "title", Body: "body", IdempotencyKey: "order-42"}
+
+	if _, err := nhub.SendMultiPlatform(context.Background(), msg, nil); err == nil {
+		t.Fatalf(errfmt, "error", "windows failure", err)
+	}
+	failWindows, sent = false, nil
+	if _, err := nhub.SendMultiPlatform(context.Background(), msg, nil); err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if !reflect.DeepEqual(sent, []string{string(WindowsFormat)}) {
+		t.Errorf(errfmt, "resent platforms", []string{string(WindowsFormat)}, sent)
+	}
+
+	sent = nil
+	nhub.SetPayloadValidation(true)
+	invalid := &MultiPlatformMessage{
+		Platforms: []NotificationFormat{AppleFormat},
+		Overrides: map[NotificationFormat]PlatformOverride{AppleFormat: {Payload: []byte(`{"alert":"no aps"}`)}},
+	}
+	result, err := nhub.SendMultiPlatform(context.Background(), invalid, nil)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !errors.As(result.Results[AppleFormat].Err, &validationErr) || len(sent) != 0 {
+		t.Errorf(errfmt, "validation error without request", "*ValidationError", err)
+	}
+
+	duplicate := &MultiPlatformMessage{Platforms: []NotificationFormat{AppleFormat, FcmV1Format, AppleFormat}}
+	if _, err := nhub.SendMultiPlatform(context.Background(), duplicate, nil); !errors.As(err, &validationErr) || validationErr.Field != "Platforms" || len(sent) != 0 {
+		t.Errorf(errfmt, "duplicate platform error", "Platforms", err)
+	}
+}
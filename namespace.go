@@ -0,0 +1,540 @@
+package notificationhubs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koreset/azure-notifications-sdk-go/utils"
+)
+
+// Namespace management constants
+const (
+	atomNamespace          = "http://www.w3.org/2005/Atom"
+	connectNamespace       = "http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"
+	instanceNamespace      = "http://www.w3.org/2001/XMLSchema-instance"
+	hubsResourcePath       = "$Resources/NotificationHubs"
+	sharedAccessRuleType   = "SharedAccessAuthorizationRule"
+	sharedAccessClaimType  = "SharedAccessKey"
+	sharedAccessClaimValue = "None"
+	sharedAccessKeySize    = 32
+)
+
+type (
+	// NamespaceManager manages the notification hubs of a namespace.
+	// Its connection string needs a key with the Manage right on the namespace.
+	NamespaceManager struct {
+		client *NotificationHub // signs and sends the requests, its URL is the namespace
+	}
+
+	// hubEntry is the Atom entry of a notification hub description
+	hubEntry struct {
+		XMLName xml.Name   `xml:"entry"`
+		Xmlns   string     `xml:"xmlns,attr,omitempty"`
+		Title   string     `xml:"title,omitempty"`
+		Content hubContent `xml:"content"`
+	}
+
+	// hubContent is the content of a hubEntry
+	hubContent struct {
+		Type        string             `xml:"type,attr,omitempty"`
+		Description hubDescriptionNode `xml:"NotificationHubDescription"`
+	}
+
+	// hubDescriptionNode is the XML of a NotificationHubDescription, elements in the order the service expects
+	hubDescriptionNode struct {
+		XmlnsI             string                  `xml:"xmlns:i,attr,omitempty"`
+		Xmlns              string                  `xml:"xmlns,attr,omitempty"`
+		ApnsCredential     *credentialNode         `xml:"ApnsCredential"`
+		WnsCredential      *credentialNode         `xml:"WnsCredential"`
+		AdmCredential      *credentialNode         `xml:"AdmCredential"`
+		BaiduCredential    *credentialNode         `xml:"BaiduCredential"`
+		FcmV1Credential    *credentialNode         `xml:"FcmV1Credential"`
+		RegistrationTTL    string                  `xml:"RegistrationTtl,omitempty"`
+		AuthorizationRules *authorizationRulesNode `xml:"AuthorizationRules"`
+	}
+
+	// authorizationRulesNode lists the authorization rules of a hub
+	authorizationRulesNode struct {
+		Rules []authorizationRuleNode `xml:"AuthorizationRule"`
+	}
+
+	// authorizationRuleNode is the XML of an AuthorizationRule
+	authorizationRuleNode struct {
+		Type         string   `xml:"i:type,attr,omitempty"`
+		ClaimType    string   `xml:"ClaimType"`
+		ClaimValue   string   `xml:"ClaimValue"`
+		Rights       []string `xml:"Rights>AccessRights"`
+		CreatedTime  string   `xml:"CreatedTime,omitempty"`
+		ModifiedTime string   `xml:"ModifiedTime,omitempty"`
+		KeyName      string   `xml:"KeyName"`
+		PrimaryKey   string   `xml:"PrimaryKey"`
+		SecondaryKey string   `xml:"SecondaryKey"`
+	}
+
+	// hubFeed is the Atom feed listing the hubs of a namespace
+	hubFeed struct {
+		Entries []hubEntry `xml:"entry"`
+	}
+)
+
+// NewNamespaceManager creates a manager for the namespace of the connection string endpoint
+func NewNamespaceManager(connectionString string) (*NamespaceManager, error) {
+	if connectionString == "" {
+		return nil, errors.New("connection string cannot be empty")
+	}
+	endpoint, sasKeyName, sasKeyValue, err := parseConnectionString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	return &NamespaceManager{client: newHubClient(endpoint, "", sasKeyName, sasKeyValue)}, nil
+}
+
+// NewAuthorizationRule creates a rule with random primary and secondary keys
+func NewAuthorizationRule(keyName string, rights ...AccessRight) (AuthorizationRule, error) {
+	if keyName == "" {
+		return AuthorizationRule{}, errors.New("notificationhubs.NewAuthorizationRule: key name cannot be empty")
+	}
+	if len(rights) == 0 {
+		return AuthorizationRule{}, errors.New("notificationhubs.NewAuthorizationRule: at least one right is required")
+	}
+	rule := AuthorizationRule{KeyName: keyName, Rights: rights}
+	for _, key := range []*string{&rule.PrimaryKey, &rule.SecondaryKey} {
+		b := make([]byte, sharedAccessKeySize)
+		if _, err := rand.Read(b); err != nil {
+			return AuthorizationRule{}, fmt.Errorf("notificationhubs.NewAuthorizationRule: %w", err)
+		}
+		*key = base64.StdEncoding.EncodeToString(b)
+	}
+	return rule, nil
+}
+
+// fields lists the rule fields, the rights comma separated and the times in RFC 3339
+func (r AuthorizationRule) fields() []credentialField {
+	var rights []string
+	for _, right := range r.Rights {
+		rights = append(rights, string(right))
+	}
+	var (
+		joined           = strings.Join(rights, ",")
+		created, updated string
+	)
+	if r.CreatedTime != nil {
+		created = r.CreatedTime.Format(time.RFC3339)
+	}
+	if r.ModifiedTime != nil {
+		updated = r.ModifiedTime.Format(time.RFC3339)
+	}
+	return []credentialField{
+		{name: "KeyName", value: &r.KeyName},
+		{name: "PrimaryKey", value: &r.PrimaryKey, secret: true},
+		{name: "SecondaryKey", value: &r.SecondaryKey, secret: true},
+		{name: "Rights", value: &joined},
+		{name: "CreatedTime", value: &created},
+		{name: "ModifiedTime", value: &updated},
+	}
+}
+
+// String returns the rule with its keys redacted
+func (r AuthorizationRule) String() string { return formatCredential("AuthorizationRule", r.fields()) }
+
+// GoString returns the rule with its keys redacted
+func (r AuthorizationRule) GoString() string { return r.String() }
+
+// LogValue returns the rule with its keys redacted
+func (r AuthorizationRule) LogValue() slog.Value { return credentialLogValue(r.fields()) }
+
+// SetHTTPClient makes it possible to use a custom http client
+func (m *NamespaceManager) SetHTTPClient(c utils.HTTPClient) {
+	m.client.SetHTTPClient(c)
+}
+
+// SetRetry makes the manager retry failed requests, see NotificationHub.SetRetry
+func (m *NamespaceManager) SetRetry(opts *RetryOptions) {
+	m.client.SetRetry(opts)
+}
+
+// Use appends middlewares to the chain intercepting the requests of the manager, see NotificationHub.Use
+func (m *NamespaceManager) Use(middlewares ...Middleware) {
+	m.client.Use(middlewares...)
+}
+
+// ConnectionString returns the connection string of the namespace with the primary key of rule
+func (m *NamespaceManager) ConnectionString(rule AuthorizationRule) string {
+	endpoint := url.URL{Scheme: m.client.HubURL.Scheme, Host: m.client.HubURL.Host, Path: "/"}
+	if endpoint.Scheme == schemeDefault {
+		endpoint.Scheme = schemeServiceBus
+	}
+	return fmt.Sprintf("%s%s;%s%s;%s%s", paramEndpoint, endpoint.String(), paramSaasKeyName, rule.KeyName, paramSaasKeyValue, rule.PrimaryKey)
+}
+
+// CreateHub validates the credentials and creates a notification hub, it fails with ErrorCodeConflict when the hub exists
+func (m *NamespaceManager) CreateHub(ctx context.Context, hub *NotificationHubDescription) (*NotificationHubDescription, error) {
+	if hub != nil {
+		if err := hub.PnsCredentials.Validate(); err != nil {
+			return nil, fmt.Errorf("notificationhubs.CreateHub: %w", err)
+		}
+	}
+	result, err := m.putHub(ctx, OperationCreateHub, hub, Headers{})
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.CreateHub: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateHub validates the credentials and replaces the description of an existing notification hub.
+// The description replaces the hub entirely: authorization rules and PNS credentials left out of it are removed,
+// so change a description read with Hub. Its ETag is sent as If-Match and the update fails with
+// ErrorCodePreconditionFailed when the hub changed since it was read, an empty ETag overwrites unconditionally.
+func (m *NamespaceManager) UpdateHub(ctx context.Context, hub *NotificationHubDescription) (*NotificationHubDescription, error) {
+	if hub != nil {
+		if err := hub.PnsCredentials.Validate(); err != nil {
+			return nil, fmt.Errorf("notificationhubs.UpdateHub: %w", err)
+		}
+	}
+	result, err := m.putHub(ctx, OperationUpdateHub, hub, Headers{"If-Match": ifMatch(hub)})
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.UpdateHub: %w", err)
+	}
+	return result, nil
+}
+
+// Hub reads the description of a notification hub
+func (m *NamespaceManager) Hub(ctx context.Context, name string) (*NotificationHubDescription, error) {
+	if name == "" {
+		return nil, errors.New("notificationhubs.Hub: hub name cannot be empty")
+	}
+	raw, resp, err := m.client.execOperation(ctx, OperationHub, getMethod, m.client.generateAPIURL(name), Headers{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.Hub: %w", err)
+	}
+	hub, err := parseHubEntry(raw)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.Hub: %w", err)
+	}
+	if hub.Name == "" {
+		hub.Name = name
+	}
+	hub.ETag = resp.Header.Get("ETag")
+	return hub, nil
+}
+
+// Hubs lists the notification hubs of the namespace
+func (m *NamespaceManager) Hubs(ctx context.Context) ([]*NotificationHubDescription, error) {
+	raw, _, err := m.client.execOperation(ctx, OperationHubs, getMethod, m.client.generateAPIURL(hubsResourcePath), Headers{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.Hubs: %w", err)
+	}
+	var feed hubFeed
+	if err := xml.Unmarshal(raw, &feed); err != nil {
+		return nil, fmt.Errorf("notificationhubs.Hubs: %w", err)
+	}
+	hubs := make([]*NotificationHubDescription, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		hub, err := entry.description()
+		if err != nil {
+			return nil, fmt.Errorf("notificationhubs.Hubs: %w", err)
+		}
+		hubs = append(hubs, hub)
+	}
+	return hubs, nil
+}
+
+// DeleteHub deletes a notification hub with its registrations and installations
+func (m *NamespaceManager) DeleteHub(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.New("notificationhubs.DeleteHub: hub name cannot be empty")
+	}
+	if _, _, err := m.client.execOperation(ctx, OperationDeleteHub, deleteMethod, m.client.generateAPIURL(name), Headers{}, nil); err != nil {
+		return fmt.Errorf("notificationhubs.DeleteHub: %w", err)
+	}
+	return nil
+}
+
+// SetAuthorizationRule adds a rule to a notification hub, or replaces the rule with the same key name
+func (m *NamespaceManager) SetAuthorizationRule(ctx context.Context, hubName string, rule AuthorizationRule) (*NotificationHubDescription, error) {
+	hub, err := m.modifyHub(ctx, hubName, func(hub *NotificationHubDescription) bool {
+		i := slices.IndexFunc(hub.AuthorizationRules, func(r AuthorizationRule) bool { return r.KeyName == rule.KeyName })
+		if i >= 0 {
+			hub.AuthorizationRules[i] = rule
+		} else {
+			hub.AuthorizationRules = append(hub.AuthorizationRules, rule)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.SetAuthorizationRule: %w", err)
+	}
+	return hub, nil
+}
+
+// DeleteAuthorizationRule removes the rule with the key name from a notification hub, a missing rule isn't an error
+func (m *NamespaceManager) DeleteAuthorizationRule(ctx context.Context, hubName, keyName string) (*NotificationHubDescription, error) {
+	hub, err := m.modifyHub(ctx, hubName, func(hub *NotificationHubDescription) bool {
+		matches := func(r AuthorizationRule) bool { return r.KeyName == keyName }
+		if !slices.ContainsFunc(hub.AuthorizationRules, matches) {
+			return false
+		}
+		hub.AuthorizationRules = slices.DeleteFunc(hub.AuthorizationRules, matches)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.DeleteAuthorizationRule: %w", err)
+	}
+	return hub, nil
+}
+
+// modifyHub reads a hub, lets modify change its description and writes it back, conditional on its ETag.
+// When the hub changed in the meantime the read-modify-write is retried, up to maxModifyAttempts times.
+// Nothing is written when modify returns false.
+func (m *NamespaceManager) modifyHub(ctx context.Context, hubName string, modify func(*NotificationHubDescription) bool) (*NotificationHubDescription, error) {
+	var err error
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		var hub *NotificationHubDescription
+		if hub, err = m.Hub(ctx, hubName); err != nil {
+			return nil, err
+		}
+		if !modify(hub) {
+			return hub, nil
+		}
+		hub, err = m.putHub(ctx, OperationUpdateHub, hub, Headers{"If-Match": ifMatch(hub)})
+		if !isPreconditionFailed(err) {
+			return hub, err
+		}
+	}
+	return nil, fmt.Errorf("giving up after %d conflicts: %w", maxModifyAttempts, err)
+}
+
+// ifMatch returns the If-Match header of a hub update, any version when the ETag is unknown
+func ifMatch(hub *NotificationHubDescription) string {
+	if hub == nil || hub.ETag == "" {
+		return "*"
+	}
+	return hub.ETag
+}
+
+// putHub writes the description of a hub
+func (m *NamespaceManager) putHub(ctx context.Context, operation Operation, hub *NotificationHubDescription, headers Headers) (*NotificationHubDescription, error) {
+	if hub == nil || hub.Name == "" {
+		return nil, errors.New("hub name cannot be empty")
+	}
+	payload, err := xml.Marshal(newHubEntry(hub))
+	if err != nil {
+		return nil, err
+	}
+	headers["Content-Type"] = "application/atom+xml;type=entry;charset=utf-8"
+	raw, resp, err := m.client.execOperation(ctx, operation, putMethod, m.client.generateAPIURL(hub.Name), headers, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseHubEntry(raw)
+	if err != nil {
+		return nil, err
+	}
+	if result.Name == "" {
+		result.Name = hub.Name
+	}
+	result.ETag = resp.Header.Get("ETag")
+	return result, nil
+}
+
+// newHubEntry converts a hub description to its Atom entry
+func newHubEntry(hub *NotificationHubDescription) hubEntry {
+	node := hubDescriptionNode{XmlnsI: instanceNamespace, Xmlns: connectNamespace}
+	if hub.Apns != nil {
+		node.ApnsCredential = newCredentialNode(hub.Apns.fields())
+	}
+	if hub.Wns != nil {
+		node.WnsCredential = newCredentialNode(hub.Wns.fields())
+	}
+	if hub.Adm != nil {
+		node.AdmCredential = newCredentialNode(hub.Adm.fields())
+	}
+	if hub.Baidu != nil {
+		node.BaiduCredential = newCredentialNode(hub.Baidu.fields())
+	}
+	if hub.FcmV1 != nil {
+		node.FcmV1Credential = newCredentialNode(hub.FcmV1.fields())
+	}
+	if hub.RegistrationTTL > 0 {
+		node.RegistrationTTL = formatXSDuration(hub.RegistrationTTL)
+	}
+	if len(hub.AuthorizationRules) > 0 {
+		node.AuthorizationRules = &authorizationRulesNode{}
+	}
+	for _, rule := range hub.AuthorizationRules {
+		ruleNode := authorizationRuleNode{
+			Type:         sharedAccessRuleType,
+			ClaimType:    sharedAccessClaimType,
+			ClaimValue:   sharedAccessClaimValue,
+			KeyName:      rule.KeyName,
+			PrimaryKey:   rule.PrimaryKey,
+			SecondaryKey: rule.SecondaryKey,
+		}
+		for _, right := range rule.Rights {
+			ruleNode.Rights = append(ruleNode.Rights, string(right))
+		}
+		node.AuthorizationRules.Rules = append(node.AuthorizationRules.Rules, ruleNode)
+	}
+	return hubEntry{
+		Xmlns:   atomNamespace,
+		Content: hubContent{Type: "application/xml", Description: node},
+	}
+}
+
+// parseHubEntry parses the Atom entry of a hub description
+func parseHubEntry(raw []byte) (*NotificationHubDescription, error) {
+	var entry hubEntry
+	if err := xml.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	return entry.description()
+}
+
+// description converts the entry to a hub description
+func (e hubEntry) description() (*NotificationHubDescription, error) {
+	var (
+		node = e.Content.Description
+		hub  = &NotificationHubDescription{Name: e.Title}
+		err  error
+	)
+	if node.RegistrationTTL != "" {
+		if hub.RegistrationTTL, err = parseXSDuration(node.RegistrationTTL); err != nil {
+			return nil, fmt.Errorf("registration TTL: %w", err)
+		}
+	}
+	if node.ApnsCredential != nil {
+		hub.Apns = &ApnsCredential{}
+		node.ApnsCredential.fill(hub.Apns.fields())
+	}
+	if node.WnsCredential != nil {
+		hub.Wns = &WnsCredential{}
+		node.WnsCredential.fill(hub.Wns.fields())
+	}
+	if node.AdmCredential != nil {
+		hub.Adm = &AdmCredential{}
+		node.AdmCredential.fill(hub.Adm.fields())
+	}
+	if node.BaiduCredential != nil {
+		hub.Baidu = &BaiduCredential{}
+		node.BaiduCredential.fill(hub.Baidu.fields())
+	}
+	if node.FcmV1Credential != nil {
+		hub.FcmV1 = &FcmV1Credential{}
+		node.FcmV1Credential.fill(hub.FcmV1.fields())
+	}
+	if node.AuthorizationRules == nil {
+		return hub, nil
+	}
+	for _, ruleNode := range node.AuthorizationRules.Rules {
+		rule := AuthorizationRule{
+			KeyName:      ruleNode.KeyName,
+			PrimaryKey:   ruleNode.PrimaryKey,
+			SecondaryKey: ruleNode.SecondaryKey,
+		}
+		if rule.CreatedTime, err = parseHubTime(&ruleNode.CreatedTime); err != nil {
+			return nil, fmt.Errorf("authorization rule %s created time: %w", rule.KeyName, err)
+		}
+		if rule.ModifiedTime, err = parseHubTime(&ruleNode.ModifiedTime); err != nil {
+			return nil, fmt.Errorf("authorization rule %s modified time: %w", rule.KeyName, err)
+		}
+		for _, right := range ruleNode.Rights {
+			rule.Rights = append(rule.Rights, AccessRight(right))
+		}
+		hub.AuthorizationRules = append(hub.AuthorizationRules, rule)
+	}
+	return hub, nil
+}
+
+// formatXSDuration formats a duration as an XML schema duration, such as P90D or PT1H30M
+func formatXSDuration(d time.Duration) string {
+	var b strings.Builder
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		b.WriteString(strconv.FormatInt(int64(days), 10) + "D")
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if hours := d / time.Hour; hours > 0 {
+			b.WriteString(strconv.FormatInt(int64(hours), 10) + "H")
+			d -= hours * time.Hour
+		}
+		if minutes := d / time.Minute; minutes > 0 {
+			b.WriteString(strconv.FormatInt(int64(minutes), 10) + "M")
+			d -= minutes * time.Minute
+		}
+		if d > 0 {
+			b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+		}
+	}
+	if b.Len() == 1 {
+		return "PT0S"
+	}
+	return b.String()
+}
+
+// parseXSDuration parses an XML schema duration made of days, hours, minutes and seconds.
+// Durations beyond the time.Duration range, such as the TimeSpan.MaxValue the service uses for
+// registrations that never expire, saturate to the maximum time.Duration.
+func parseXSDuration(value string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(value, "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	var (
+		total  time.Duration
+		inTime bool
+		number strings.Builder
+	)
+	for _, c := range rest {
+		switch {
+		case c == 'T' && !inTime && number.Len() == 0:
+			inTime = true
+			continue
+		case c >= '0' && c <= '9' || c == '.':
+			number.WriteRune(c)
+			continue
+		}
+
+		n, err := strconv.ParseFloat(number.String(), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		number.Reset()
+
+		var unit time.Duration
+		switch {
+		case c == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case c == 'H' && inTime:
+			unit = time.Hour
+		case c == 'M' && inTime:
+			unit = time.Minute
+		case c == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("unsupported duration %q", value)
+		}
+		if component := n * float64(unit); component >= float64(math.MaxInt64-total) {
+			total = math.MaxInt64
+		} else {
+			total += time.Duration(component)
+		}
+	}
+	if number.Len() > 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return total, nil
+}
@@ -0,0 +1,39 @@
+package notificationhubs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_XSDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		duration time.Duration
+		format   string
+	}{
+		{"P90D", 90 * 24 * time.Hour, "P90D"},
+		{"PT12H30M", 12*time.Hour + 30*time.Minute, "PT12H30M"},
+		{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, "P1DT2H3M4S"},
+		{"PT1.5S", 1500 * time.Millisecond, "PT1.5S"},
+		{"PT0S", 0, "PT0S"},
+		{"P10675199DT2H48M5.4775807S", math.MaxInt64, "P106751DT23H47M16.854775807S"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			duration, err := parseXSDuration(test.value)
+			if err != nil || duration != test.duration {
+				t.Errorf("Expected %s to parse as %v, got %v (%v)", test.value, test.duration, duration, err)
+			}
+			if format := formatXSDuration(duration); format != test.format {
+				t.Errorf("Expected %v to format as %s, got %s", duration, test.format, format)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "P", "90D", "P1H", "PT1D", "P1Y", "P1.2.3D", "PT5"} {
+		if _, err := parseXSDuration(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package notificationhubs_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+const hubURL = "https://testhub-ns.servicebus.windows.net/testhub?api-version=2016-07"
+
+// compactXML removes the indentation between the elements of an XML document
+func compactXML(data []byte) string {
+	return string(regexp.MustCompile(`>\s+<`).ReplaceAll(bytes.TrimSpace(data), []byte("><")))
+}
+
+func Test_NamespaceManagerHub(t *testing.T) {
+	var (
+		manager, _ = NewNamespaceManager(connectionString)
+		created, _ = time.Parse(time.RFC3339Nano, "2024-03-05T10:12:44.6170932Z")
+		modified   = time.Date(2024, 3, 5, 10, 15, 2, 103478600, time.UTC)
+	)
+	manager.SetHTTPClient(&mockHubHTTPClient{execFunc: func(req *http.Request) ([]byte, *http.Response, error) {
+		if req.Method != getMethod || req.URL.String() != hubURL {
+			t.Errorf(errfmt, "request", getMethod+" "+hubURL, req.Method+" "+req.URL.String())
+		}
+		data, err := ioutil.ReadFile("./fixtures/hubDescriptionResult.xml")
+		return data, &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": {`W/"3"`}}}, err
+	}})
+
+	hub, err := manager.Hub(context.Background(), hubPath)
+	if err != nil {
+		t.Fatalf(errfmt, "hub error", nil, err)
+	}
+	expected := &NotificationHubDescription{
+		Name:            "testhub",
+		RegistrationTTL: math.MaxInt64,
+		AuthorizationRules: []AuthorizationRule{
+			{
+				KeyName:      "DefaultListenSharedAccessSignature",
+				PrimaryKey:   "listenprimary",
+				SecondaryKey: "listensecondary",
+				Rights:       []AccessRight{AccessRightListen},
+				CreatedTime:  &created,
+				ModifiedTime: &created,
+			},
+			{
+				KeyName:      "DefaultFullSharedAccessSignature",
+				PrimaryKey:   "fullprimary",
+				SecondaryKey: "fullsecondary",
+				Rights:       []AccessRight{AccessRightListen, AccessRightManage, AccessRightSend},
+				CreatedTime:  &created,
+				ModifiedTime: &modified,
+			},
+		},
+		PnsCredentials: PnsCredentials{
+			Apns: &ApnsCredential{
+				Endpoint:       ApnsSandboxEndpoint,
+				Certificate:    "MIIKcQIBAzCCCjcGCSqG",
+				CertificateKey: "certificatekey",
+				Thumbprint:     "7FA3C9E1B2D4F6A8C0E2B4D6F8A0C2E4B6D8F0A2",
+			},
+			Wns: &WnsCredential{
+				PackageSID:          "ms-app://s-1-15-2-1",
+				SecretKey:           "wnssecret",
+				WindowsLiveEndpoint: "https://login.live.com/accesstoken.srf",
+			},
+		},
+		ETag: `W/"3"`,
+	}
+	if !reflect.DeepEqual(hub, expected) {
+		t.Errorf(errfmt, "hub", expected, hub)
+	}
+}
+
+func Test_NamespaceManagerHubs(t *testing.T) {
+	manager, _ := NewNamespaceManager(connectionString)
+	manager.SetHTTPClient(&mockHubHTTPClient{execFunc: func(req *http.Request) ([]byte, *http.Response, error) {
+		wantURL := "https://testhub-ns.servicebus.windows.net/$Resources/NotificationHubs?api-version=2016-07"
+		if req.URL.String() != wantURL {
+			t.Errorf(errfmt, "URL", wantURL, req.URL.String())
+		}
+		data, err := ioutil.ReadFile("./fixtures/hubsResult.xml")
+		return data, &http.Response{StatusCode: http.StatusOK}, err
+	}})
+
+	hubs, err := manager.Hubs(context.Background())
+	if err != nil {
+		t.Fatalf(errfmt, "hubs error", nil, err)
+	}
+	expected := []*NotificationHubDescription{
+		{Name: "orders", RegistrationTTL: 90 * 24 * time.Hour},
+		{Name: "testhub", RegistrationTTL: 12*time.Hour + 30*time.Minute},
+	}
+	if !reflect.DeepEqual(hubs, expected) {
+		t.Errorf(errfmt, "hubs", expected, hubs)
+	}
+}
+
+func Test_NamespaceManagerCreateHub(t *testing.T) {
+	manager, _ := NewNamespaceManager(connectionString)
+	manager.SetHTTPClient(&mockHubHTTPClient{execFunc: func(req *http.Request) ([]byte, *http.Response, error) {
+		if req.Method != putMethod || req.URL.String() != hubURL || req.Header.Get("If-Match") != "" {
+			t.Errorf(errfmt, "request", "unconditional "+putMethod+" "+hubURL, req.Method+" "+req.URL.String())
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		expected, _ := ioutil.ReadFile("./fixtures/hubDescriptionRequest.xml")
+		if compactXML(body) != compactXML(expected) {
+			t.Errorf(errfmt, "body", compactXML(expected), string(body))
+		}
+		data, err := ioutil.ReadFile("./fixtures/hubDescriptionResult.xml")
+		return data, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, err
+	}})
+
+	hub, err := manager.CreateHub(context.Background(), &NotificationHubDescription{
+		Name:            hubPath,
+		RegistrationTTL: 90*24*time.Hour + 12*time.Hour,
+		AuthorizationRules: []AuthorizationRule{{
+			KeyName:      "DefaultListenSharedAccessSignature",
+			PrimaryKey:   "listenprimary",
+			SecondaryKey: "listensecondary",
+			Rights:       []AccessRight{AccessRightListen, AccessRightSend},
+		}},
+		PnsCredentials: PnsCredentials{Apns: &ApnsCredential{
+			Endpoint:       ApnsSandboxEndpoint,
+			Certificate:    "MIIKcQIBAzCCCjcGCSqG",
+			CertificateKey: "certificatekey",
+			Thumbprint:     "ignored, set by the service",
+		}},
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "create error", nil, err)
+	}
+	if hub.Name != hubPath || len(hub.AuthorizationRules) != 2 {
+		t.Errorf(errfmt, "created hub", hubPath, hub)
+	}
+}
@@ -1,6 +1,7 @@
 package notificationhubs
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 )
@@ -10,6 +11,10 @@ type (
 	Notification struct {
 		Format  NotificationFormat
 		Payload []byte
+
+		// IdempotencyKey identifies the notification, sends repeating a successful send with the same key
+		// within the deduplication window are suppressed, see NotificationHub.SetDeduplication
+		IdempotencyKey string
 	}
 
 	// IosBackgroundNotificationPayload is the payload required for a background notification
@@ -26,7 +31,7 @@ func newNotification(format NotificationFormat, payload []byte) (*Notification,
 		return nil, fmt.Errorf("unknown format '%s'", format)
 	}
 
-	return &Notification{format, payload}, nil
+	return &Notification{Format: format, Payload: payload}, nil
 }
 
 // String returns Notification string representation
@@ -43,3 +48,21 @@ func isIosBackgroundNotification(payload []byte) bool {
 
 	return backgroundNotification.Aps.ContentAvailable == 1
 }
+
+// wnsType identifies the WNS notification type from the payload root element, payloads without a
+// toast, tile or badge root are raw notifications
+func wnsType(payload []byte) string {
+	trimmed := bytes.TrimSpace(payload)
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		if end := bytes.Index(trimmed, []byte("?>")); end >= 0 {
+			trimmed = bytes.TrimSpace(trimmed[end+2:])
+		}
+	}
+	for _, kind := range []string{"toast", "tile", "badge"} {
+		rest, found := bytes.CutPrefix(trimmed, []byte("<"+kind))
+		if found && (len(rest) == 0 || bytes.IndexByte([]byte(" \t\r\n/>"), rest[0]) >= 0) {
+			return "wns/" + kind
+		}
+	}
+	return "wns/raw"
+}
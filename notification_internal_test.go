@@ -40,3 +40,28 @@ func Test_isIosBackgroundNotification(t *testing.T) {
 		})
 	}
 }
+
+func Test_wnsType(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"toast", `<toast><visual><binding template="ToastGeneric"><text>hi</text></binding></visual></toast>`, "wns/toast"},
+		{"toast with attributes", `<toast launch="app://1"><visual/></toast>`, "wns/toast"},
+		{"tile", `<tile><visual><binding template="TileMedium"/></visual></tile>`, "wns/tile"},
+		{"badge", `<badge value="3"/>`, "wns/badge"},
+		{"declaration and whitespace", "\n <?xml version=\"1.0\" encoding=\"utf-8\"?>\n<badge value=\"1\"/>", "wns/badge"},
+		{"other element", `<toaster/>`, "wns/raw"},
+		{"raw text", `raw data`, "wns/raw"},
+		{"raw JSON", `{"toast":true}`, "wns/raw"},
+		{"empty", ``, "wns/raw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wnsType([]byte(tt.payload)); got != tt.expected {
+				t.Errorf("wnsType() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package notificationhubs_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+const notificationID = "3288835312934927344-986564390439048203-1"
+
+func Test_NotificationDetails(t *testing.T) {
+	nhub, mockClient := initTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if req.Method != getMethod {
+			t.Errorf(errfmt, "method", getMethod, req.Method)
+		}
+		wantURL := "https://testhub-ns.servicebus.windows.net/testhub/messages/" + notificationID + "?api-version=" + telemetryAPIVersionValue
+		if gotURL := req.URL.String(); gotURL != wantURL {
+			t.Errorf(errfmt, "URL", wantURL, gotURL)
+		}
+		data, e := ioutil.ReadFile("./fixtures/notificationDetailsResult.xml")
+		if e != nil {
+			return nil, nil, e
+		}
+		return data, nil, nil
+	}
+
+	details, _, err := nhub.NotificationDetails(context.Background(), notificationID)
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+
+	if details.ID != notificationID {
+		t.Errorf(errfmt, "ID", notificationID, details.ID)
+	}
+	if details.State != Completed {
+		t.Errorf(errfmt, "State", Completed, details.State)
+	}
+
+	times := []struct {
+		name string
+		got  *time.Time
+		want time.Time
+	}{
+		{"EnqueueTime", details.EnqueueTime, time.Date(2019, 4, 23, 9, 12, 50, 123000000, time.UTC)},
+		{"StartTime", details.StartTime, time.Date(2019, 4, 23, 9, 12, 51, 234567800, time.UTC)},
+		{"EndTime", details.EndTime, time.Date(2019, 4, 23, 9, 12, 52, 0, time.UTC)},
+	}
+	for _, tt := range times {
+		if tt.got == nil || !tt.got.Equal(tt.want) {
+			t.Errorf(errfmt, tt.name, tt.want, tt.got)
+		}
+	}
+
+	if details.PnsErrorDetailsURI == "" {
+		t.Errorf(errfmt, "PnsErrorDetailsURI", "a blob URI", details.PnsErrorDetailsURI)
+	}
+	if got := details.ApnsOutcomeCounts.TotalByOutcome(WrongToken); got != 2 {
+		t.Errorf(errfmt, "APNS WrongToken count", 2, got)
+	}
+	if got := details.WnsOutcomeCounts.TotalByOutcome(ExpiredChannel); got != 1 {
+		t.Errorf(errfmt, "WNS ExpiredChannel count", 1, got)
+	}
+	if details.MpnsOutcomeCounts != nil {
+		t.Errorf(errfmt, "MPNS outcomes", nil, details.MpnsOutcomeCounts)
+	}
+	if got := len(details.PlatformOutcomeCounts()); got != 3 {
+		t.Errorf(errfmt, "platform outcome count", 3, got)
+	}
+
+	totals := details.TotalOutcomes()
+	if got := totals.TotalByOutcome(Success); got != 17 {
+		t.Errorf(errfmt, "total Success", 17, got)
+	}
+	if got := totals.Total(); got != 20 {
+		t.Errorf(errfmt, "total", 20, got)
+	}
+	if got := totals.FailureRate(); got != 0.15 {
+		t.Errorf(errfmt, "failure rate", 0.15, got)
+	}
+}
+
+func Test_NotificationDetailsInvalidTime(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		data, e := ioutil.ReadFile("./fixtures/notificationDetailsResult.xml")
+		return bytes.Replace(data, []byte("2019-04-23T09:12:51.2345678Z"), []byte("23/04/2019 09:12"), 1), nil, e
+	}
+
+	if _, _, err := nhub.NotificationDetails(context.Background(), notificationID); err == nil || !strings.Contains(err.Error(), "StartTime") {
+		t.Errorf(errfmt, "error", "unrecognized StartTime", err)
+	}
+}
+
+func Test_NotificationOutcomes_FailureRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes *NotificationOutcomes
+		expected float64
+	}{
+		{
+			name:     "Nil outcomes",
+			outcomes: nil,
+			expected: 0,
+		},
+		{
+			name:     "No outcomes",
+			outcomes: &NotificationOutcomes{},
+			expected: 0,
+		},
+		{
+			name: "Skipped and NoTargets are not failures",
+			outcomes: &NotificationOutcomes{Outcomes: []NotificationOutcome{
+				{Name: Success, Count: 2},
+				{Name: Skipped, Count: 1},
+				{Name: NoTargets, Count: 1},
+			}},
+			expected: 0,
+		},
+		{
+			name: "Mixed outcomes",
+			outcomes: &NotificationOutcomes{Outcomes: []NotificationOutcome{
+				{Name: Success, Count: 3},
+				{Name: BadChannel, Count: 1},
+			}},
+			expected: 0.25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.outcomes.FailureRate(); got != tt.expected {
+				t.Errorf("NotificationOutcomes.FailureRate() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
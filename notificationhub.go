@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"path"
@@ -23,17 +24,19 @@ type NotificationHub struct {
 
 	client                  utils.HTTPClient
 	expirationTimeGenerator utils.ExpirationTimeGenerator
+	blobReader              utils.BlobReader
+	logger                  *slog.Logger
+	logOptions              LogOptions
+	rateLimiter             *rateLimiter
+	circuitBreaker          *circuitBreaker
+	retryOptions            *RetryOptions
+	middlewares             []Middleware
+	dedup                   *deduplicator
+	validatePayloads        bool
 }
 
 // newNotificationHub initializes and returns NotificationHub pointer
 func newNotificationHub(connectionString, hubPath string) (*NotificationHub, error) {
-	var (
-		connData    = strings.Split(connectionString, ";")
-		_url        = &url.URL{}
-		sasKeyName  = ""
-		sasKeyValue = ""
-	)
-
 	if connectionString == "" {
 		return nil, fmt.Errorf("connection string cannot be empty")
 	}
@@ -42,13 +45,22 @@ func newNotificationHub(connectionString, hubPath string) (*NotificationHub, err
 		return nil, fmt.Errorf("hub path cannot be empty")
 	}
 
-	for _, connItem := range connData {
+	endpoint, sasKeyName, sasKeyValue, err := parseConnectionString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	return newHubClient(endpoint, hubPath, sasKeyName, sasKeyValue), nil
+}
+
+// parseConnectionString returns the endpoint and the SAS key of a connection string
+func parseConnectionString(connectionString string) (endpoint *url.URL, sasKeyName, sasKeyValue string, err error) {
+	endpoint = &url.URL{}
+	for _, connItem := range strings.Split(connectionString, ";") {
 		if strings.HasPrefix(connItem, paramEndpoint) {
-			hubURL, err := url.Parse(connItem[len(paramEndpoint):])
+			endpoint, err = url.Parse(connItem[len(paramEndpoint):])
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse endpoint URL: %w", err)
+				return nil, "", "", fmt.Errorf("failed to parse endpoint URL: %w", err)
 			}
-			_url = hubURL
 			continue
 		}
 
@@ -64,23 +76,30 @@ func newNotificationHub(connectionString, hubPath string) (*NotificationHub, err
 	}
 
 	if sasKeyName == "" || sasKeyValue == "" {
-		return nil, fmt.Errorf("invalid connection string: missing SAS key name or value")
+		return nil, "", "", fmt.Errorf("invalid connection string: missing SAS key name or value")
 	}
 
-	if _url.Scheme == schemeServiceBus || _url.Scheme == "" {
-		_url.Scheme = schemeDefault
+	if endpoint.Scheme == schemeServiceBus || endpoint.Scheme == "" {
+		endpoint.Scheme = schemeDefault
 	}
+	return endpoint, sasKeyName, sasKeyValue, nil
+}
 
-	_url.Path = hubPath
-	_url.RawQuery = url.Values{apiVersionParam: {apiVersionValue}}.Encode()
+// newHubClient returns a client sending requests under path of the endpoint
+func newHubClient(endpoint *url.URL, path, sasKeyName, sasKeyValue string) *NotificationHub {
+	hubURL := *endpoint
+	hubURL.Path = path
+	hubURL.RawQuery = url.Values{apiVersionParam: {apiVersionValue}}.Encode()
 	return &NotificationHub{
 		SasKeyName:  sasKeyName,
 		SasKeyValue: sasKeyValue,
-		HubURL:      _url,
+		HubURL:      &hubURL,
 
 		client:                  utils.NewHubHTTPClient(),
 		expirationTimeGenerator: utils.NewExpirationTimeGenerator(),
-	}, nil
+		blobReader:              utils.NewHTTPBlobReader(),
+		dedup:                   newDeduplicator(nil),
+	}
 }
 
 // SetHTTPClient makes it possible to use a custom http client
@@ -93,6 +112,11 @@ func (h *NotificationHub) SetExpirationTimeGenerator(e utils.ExpirationTimeGener
 	h.expirationTimeGenerator = e
 }
 
+// SetBlobReader makes it possible to use a custom reader for blobs such as the PNS error details
+func (h *NotificationHub) SetBlobReader(r utils.BlobReader) {
+	h.blobReader = r
+}
+
 // generateSasToken generates and returns
 // azure notification hub shared access signature token
 func (h *NotificationHub) generateSasToken() string {
@@ -123,16 +147,19 @@ func (h *NotificationHub) generateSasToken() string {
 
 // exec request using method to url
 func (h *NotificationHub) exec(ctx context.Context, method string, url *url.URL, headers Headers, buf io.Reader) ([]byte, *http.Response, error) {
-	headers["Authorization"] = h.generateSasToken()
-	req, err := http.NewRequest(method, url.String(), buf)
+	return h.execOperation(ctx, h.operationFor(method, url), method, url, headers, buf)
+}
+
+// execOperation executes the request of an operation using method to url
+func (h *NotificationHub) execOperation(ctx context.Context, operation Operation, method string, url *url.URL, headers Headers, buf io.Reader) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), buf)
 	if err != nil {
 		return nil, nil, err
 	}
-	req = req.WithContext(ctx)
 	for header, val := range headers {
 		req.Header.Set(header, val)
 	}
-	return h.client.Exec(req)
+	return h.handler()(operation, req)
 }
 
 // generate an URL for path
@@ -0,0 +1,306 @@
+package notificationhubstest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hubsResourcePath lists the hubs of the namespace
+const hubsResourcePath = "/$Resources/NotificationHubs"
+
+type (
+	// hubResource is a notification hub description stored by the fake
+	hubResource struct {
+		name     string
+		elements []hubElement // the description elements other than the authorization rules, in request order
+		rules    []hubRule
+		created  time.Time
+		updated  time.Time
+		etag     int
+	}
+
+	// hubElement is an element of a hub description kept verbatim
+	hubElement struct {
+		XMLName xml.Name
+		Inner   string `xml:",innerxml"`
+	}
+
+	// hubRule is a shared access authorization rule of a hub
+	hubRule struct {
+		KeyName      string   `xml:"KeyName"`
+		PrimaryKey   string   `xml:"PrimaryKey"`
+		SecondaryKey string   `xml:"SecondaryKey"`
+		Rights       []string `xml:"Rights>AccessRights"`
+		created      time.Time
+		modified     time.Time
+	}
+
+	// hubEntry is the atom entry of a hub description sent by clients
+	hubEntry struct {
+		Content struct {
+			Description struct {
+				Elements []hubElement `xml:",any"`
+			} `xml:"NotificationHubDescription"`
+		} `xml:"content"`
+	}
+)
+
+// Hubs returns the names of the hubs of the fake namespace, sorted
+func (s *Server) Hubs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.hubs))
+	for name := range s.hubs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultHubs returns the hubs of a new namespace, the served hub with the key of the options
+func (s *Server) defaultHubs() map[string]*hubResource {
+	now := s.opts.Now().UTC()
+	return map[string]*hubResource{
+		s.opts.HubPath: {
+			name: s.opts.HubPath,
+			rules: []hubRule{{
+				KeyName:    s.opts.KeyName,
+				PrimaryKey: s.opts.Key,
+				Rights:     []string{"Listen", "Send", "Manage"},
+				created:    now,
+				modified:   now,
+			}},
+			created: now,
+			updated: now,
+			etag:    1,
+		},
+	}
+}
+
+// ruleKeys returns the keys of the rules named keyName of a hub granting right,
+// the Manage right grants every other right
+func (s *Server) ruleKeys(hubName, keyName, right string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hub, ok := s.hubs[hubName]
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, rule := range hub.rules {
+		if rule.KeyName != keyName || !slices.Contains(rule.Rights, right) && !slices.Contains(rule.Rights, "Manage") {
+			continue
+		}
+		for _, key := range []string{rule.PrimaryKey, rule.SecondaryKey} {
+			if key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// requiredRight returns the hub targeted by a request and the right it requires,
+// the hub is empty for requests on the namespace
+func requiredRight(r *http.Request) (hub, right string) {
+	if r.URL.Path == hubsResourcePath {
+		return "", "Manage"
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) == 1 {
+		return segments[0], "Manage"
+	}
+
+	hub, resource, id := segments[0], segments[1], strings.Join(segments[2:], "/")
+	switch {
+	case resource == "messages" || resource == "schedulednotifications":
+		if r.Method == http.MethodGet {
+			return hub, "Manage"
+		}
+		return hub, "Send"
+	case resource == "registrations" || resource == "installations":
+		if r.Method == http.MethodGet && id == "" {
+			return hub, "Manage"
+		}
+		return hub, "Listen"
+	default:
+		return hub, "Manage"
+	}
+}
+
+// serveHubs handles the hub descriptions of the namespace
+func (s *Server) serveHubs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == hubsResourcePath {
+		if r.Method != http.MethodGet {
+			allowedMethods(w, http.MethodGet)
+			return
+		}
+		s.writeHubFeed(w)
+		return
+	}
+
+	name := strings.Trim(r.URL.Path, "/")
+	hub, exists := s.hubs[name]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.Error(w, "hub not found", http.StatusNotFound)
+			return
+		}
+		s.writeHub(w, http.StatusOK, hub)
+	case http.MethodPut:
+		s.putHub(w, r, name, hub)
+	case http.MethodDelete:
+		if !exists {
+			http.Error(w, "hub not found", http.StatusNotFound)
+			return
+		}
+		delete(s.hubs, name)
+		if name == s.opts.HubPath {
+			s.registrations = make(map[string]*Registration)
+			s.installations = make(map[string]*installationEntry)
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		allowedMethods(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// putHub creates a hub, or updates it when the request has an If-Match header
+func (s *Server) putHub(w http.ResponseWriter, r *http.Request, name string, existing *hubResource) {
+	update := r.Header.Get("If-Match") != ""
+	if update && existing == nil {
+		http.Error(w, "hub not found", http.StatusNotFound)
+		return
+	}
+	if !update && existing != nil {
+		http.Error(w, "hub already exists", http.StatusConflict)
+		return
+	}
+	if update && !matchesETag(r, strconv.Itoa(existing.etag)) {
+		http.Error(w, "hub changed", http.StatusPreconditionFailed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var entry hubEntry
+	if err := xml.Unmarshal(body, &entry); err != nil {
+		http.Error(w, "malformed hub description: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		now = s.opts.Now().UTC()
+		hub = &hubResource{name: name, created: now, updated: now, etag: 1}
+	)
+	if existing != nil {
+		hub.created = existing.created
+		hub.etag = existing.etag + 1
+	}
+	for _, element := range entry.Content.Description.Elements {
+		if element.XMLName.Local != "AuthorizationRules" {
+			hub.elements = append(hub.elements, hubElement{XMLName: xml.Name{Local: element.XMLName.Local}, Inner: element.Inner})
+			continue
+		}
+		var rules struct {
+			Rules []hubRule `xml:"AuthorizationRule"`
+		}
+		if err := xml.Unmarshal([]byte("<AuthorizationRules>"+element.Inner+"</AuthorizationRules>"), &rules); err != nil {
+			http.Error(w, "malformed authorization rules: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		hub.rules = rules.Rules
+	}
+
+	for i := range hub.rules {
+		rule := &hub.rules[i]
+		if rule.KeyName == "" || rule.PrimaryKey == "" || len(rule.Rights) == 0 {
+			http.Error(w, fmt.Sprintf("authorization rule %d needs a key name, a primary key and rights", i), http.StatusBadRequest)
+			return
+		}
+		rule.created, rule.modified = now, now
+		if existing == nil {
+			continue
+		}
+		for _, previous := range existing.rules {
+			if previous.KeyName == rule.KeyName {
+				rule.created = previous.created
+			}
+		}
+	}
+
+	s.hubs[name] = hub
+	status := http.StatusCreated
+	if update {
+		status = http.StatusOK
+	}
+	s.writeHub(w, status, hub)
+}
+
+// writeHub writes a hub atom entry
+func (s *Server) writeHub(w http.ResponseWriter, status int, hub *hubResource) {
+	w.Header().Set("Content-Type", atomContentType)
+	w.Header().Set("ETag", strconv.Itoa(hub.etag))
+	w.WriteHeader(status)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n")
+	io.WriteString(w, s.hubEntryXML(hub, true))
+}
+
+// writeHubFeed writes an atom feed of the hubs of the namespace
+func (s *Server) writeHubFeed(w http.ResponseWriter) {
+	names := make([]string, 0, len(s.hubs))
+	for name := range s.hubs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "application/atom+xml;type=feed;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title type="text">NotificationHubs</title><id>%s</id><updated>%s</updated>`,
+		escape(s.URL+hubsResourcePath), s.opts.Now().UTC().Format(time.RFC3339))
+	for _, name := range names {
+		io.WriteString(w, s.hubEntryXML(s.hubs[name], false))
+	}
+	io.WriteString(w, "</feed>")
+}
+
+// hubEntryXML serializes a hub description as an atom entry
+func (s *Server) hubEntryXML(hub *hubResource, root bool) string {
+	var b strings.Builder
+	if root {
+		b.WriteString(`<entry xmlns="http://www.w3.org/2005/Atom">`)
+	} else {
+		b.WriteString(`<entry>`)
+	}
+	fmt.Fprintf(&b, `<id>%s</id><title type="text">%s</title>`, escape(s.URL+"/"+hub.name), escape(hub.name))
+	fmt.Fprintf(&b, `<published>%s</published><updated>%s</updated>`, hub.created.Format(time.RFC3339), hub.updated.Format(time.RFC3339))
+	fmt.Fprintf(&b, `<content type="application/xml"><NotificationHubDescription xmlns:i="%s" xmlns="%s">`, instanceNS, connectNamespace)
+	for _, element := range hub.elements {
+		fmt.Fprintf(&b, `<%s>%s</%s>`, element.XMLName.Local, element.Inner, element.XMLName.Local)
+	}
+	b.WriteString(`<AuthorizationRules>`)
+	for _, rule := range hub.rules {
+		b.WriteString(`<AuthorizationRule i:type="SharedAccessAuthorizationRule"><ClaimType>SharedAccessKey</ClaimType><ClaimValue>None</ClaimValue><Rights>`)
+		for _, right := range rule.Rights {
+			fmt.Fprintf(&b, `<AccessRights>%s</AccessRights>`, escape(right))
+		}
+		fmt.Fprintf(&b, `</Rights><CreatedTime>%s</CreatedTime><ModifiedTime>%s</ModifiedTime>`,
+			rule.created.Format(hubTimeFormat), rule.modified.Format(hubTimeFormat))
+		fmt.Fprintf(&b, `<KeyName>%s</KeyName><PrimaryKey>%s</PrimaryKey><SecondaryKey>%s</SecondaryKey></AuthorizationRule>`,
+			escape(rule.KeyName), escape(rule.PrimaryKey), escape(rule.SecondaryKey))
+	}
+	b.WriteString(`</AuthorizationRules></NotificationHubDescription></content></entry>`)
+	return b.String()
+}
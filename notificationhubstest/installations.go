@@ -0,0 +1,172 @@
+package notificationhubstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// installationEntry is an installation stored by the fake hub
+type installationEntry struct {
+	installation notificationhubs.Installation
+	etag         int
+}
+
+// installationTargets maps installation platforms to the registration targets listing them
+var installationTargets = map[notificationhubs.InstallationPlatform]notificationhubs.TargetPlatform{
+	notificationhubs.APNSPlatform:  notificationhubs.ApplePlatform,
+	notificationhubs.FCMV1Platform: notificationhubs.FcmV1Platform,
+	notificationhubs.WNSPlatform:   notificationhubs.WindowsPlatform,
+	notificationhubs.MPNSPlatform:  notificationhubs.WindowsphonePlatform,
+	notificationhubs.ADMPlatform:   notificationhubs.AdmPlatform,
+	"baidu":                        notificationhubs.BaiduPlatform,
+}
+
+// Installations returns a copy of the installations, ordered by ID
+func (s *Server) Installations() []notificationhubs.Installation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]notificationhubs.Installation, 0, len(s.installations))
+	for _, entry := range s.installations {
+		result = append(result, copyInstallation(entry.installation))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].InstallationID < result[j].InstallationID })
+	return result
+}
+
+// AddInstallation stores an installation directly, without going through the API
+func (s *Server) AddInstallation(installation notificationhubs.Installation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.storeInstallation(copyInstallation(installation))
+}
+
+// storeInstallation stores an installation, updating its last update time and ETag
+func (s *Server) storeInstallation(installation notificationhubs.Installation) *installationEntry {
+	now := s.opts.Now().UTC()
+	installation.LastUpdate = &now
+	if installation.ExpirationTime == nil {
+		expiration := endOfTime
+		installation.ExpirationTime = &expiration
+	}
+	entry := &installationEntry{installation: installation, etag: 1}
+	if existing, ok := s.installations[installation.InstallationID]; ok {
+		entry.etag = existing.etag + 1
+	}
+	s.installations[installation.InstallationID] = entry
+	return entry
+}
+
+// serveInstallations handles /installations requests
+func (s *Server) serveInstallations(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 0 || segments[0] == "" {
+		allowedMethods(w)
+		return
+	}
+
+	id := segments[0]
+	if entry, ok := s.installations[id]; r.Method != http.MethodGet && !installationPreconditions(r, entry, ok) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		entry, ok := s.installations[id]
+		if !ok {
+			http.Error(w, "installation not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", strconv.Itoa(entry.etag))
+		writeJSON(w, http.StatusOK, entry.installation)
+	case http.MethodPut:
+		var installation notificationhubs.Installation
+		if err := json.NewDecoder(r.Body).Decode(&installation); err != nil {
+			http.Error(w, "malformed installation: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if installation.InstallationID != id {
+			http.Error(w, "installation ID doesn't match the request URL", http.StatusBadRequest)
+			return
+		}
+		if err := validateInstallation(installation); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.storeInstallation(installation)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		entry, ok := s.installations[id]
+		if !ok {
+			http.Error(w, "installation not found", http.StatusNotFound)
+			return
+		}
+		var changes []notificationhubs.InstallationChange
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			http.Error(w, "malformed patch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		installation, err := notificationhubs.ApplyChanges(entry.installation, changes...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateInstallation(installation); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.storeInstallation(installation)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(s.installations, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		allowedMethods(w, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// installationPreconditions evaluates the If-Match and If-None-Match headers of a request against an installation
+func installationPreconditions(r *http.Request, entry *installationEntry, exists bool) bool {
+	if r.Header.Get("If-Match") != "" && (!exists || !matchesETag(r, strconv.Itoa(entry.etag))) {
+		return false
+	}
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		return false
+	}
+	return true
+}
+
+// validateInstallation checks the required installation fields
+func validateInstallation(installation notificationhubs.Installation) error {
+	if installation.InstallationID == "" {
+		return fmt.Errorf("installation ID is required")
+	}
+	if installation.PushChannel == "" {
+		return fmt.Errorf("push channel is required")
+	}
+	switch installation.Platform {
+	case notificationhubs.APNSPlatform, notificationhubs.WNSPlatform, notificationhubs.MPNSPlatform,
+		notificationhubs.ADMPlatform, notificationhubs.FCMV1Platform, "baidu":
+		return nil
+	}
+	return fmt.Errorf("unsupported platform '%s'", installation.Platform)
+}
+
+// copyInstallation deep copies an installation
+func copyInstallation(installation notificationhubs.Installation) notificationhubs.Installation {
+	raw, _ := json.Marshal(installation)
+	var copied notificationhubs.Installation
+	_ = json.Unmarshal(raw, &copied)
+	return copied
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
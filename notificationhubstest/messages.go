@@ -0,0 +1,489 @@
+package notificationhubstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// blobsPath is the path the fake serves PNS error details blobs from
+const blobsPath = "/_blobs/"
+
+type (
+	// Notification is a notification received by the fake hub
+	Notification struct {
+		ID            string
+		Format        notificationhubs.NotificationFormat
+		Payload       []byte
+		Headers       http.Header
+		TagExpression string
+		Direct        bool
+		DeviceHandles []string
+		ScheduledTime *time.Time
+		EnqueueTime   time.Time
+		Canceled      bool
+		// Targets are the devices the notification reached.
+		// Scheduled notifications are only resolved once their time has come.
+		Targets []Target
+
+		resolved bool
+	}
+
+	// Target is a device reached by a notification
+	Target struct {
+		RegistrationID string
+		InstallationID string
+		// Template is the name of the installation template used, if any
+		Template string
+		// Format is the native format of the device
+		Format  notificationhubs.NotificationFormat
+		Handle  string
+		Outcome notificationhubs.NotificationOutcomeName
+	}
+)
+
+// outcomeElements maps native formats to the telemetry outcome elements
+var outcomeElements = map[notificationhubs.NotificationFormat]string{
+	notificationhubs.AppleFormat:        "ApnsOutcomeCounts",
+	notificationhubs.FcmV1Format:        "FcmV1OutcomeCounts",
+	notificationhubs.WindowsFormat:      "WnsOutcomeCounts",
+	notificationhubs.WindowsPhoneFormat: "MpnsOutcomeCounts",
+	notificationhubs.KindleFormat:       "AdmOutcomeCounts",
+	notificationhubs.BaiduFormat:        "BaiduOutcomeCounts",
+}
+
+// installationFormats maps installation platforms to native formats
+var installationFormats = map[notificationhubs.InstallationPlatform]notificationhubs.NotificationFormat{
+	notificationhubs.APNSPlatform:  notificationhubs.AppleFormat,
+	notificationhubs.FCMV1Platform: notificationhubs.FcmV1Format,
+	notificationhubs.WNSPlatform:   notificationhubs.WindowsFormat,
+	notificationhubs.MPNSPlatform:  notificationhubs.WindowsPhoneFormat,
+	notificationhubs.ADMPlatform:   notificationhubs.KindleFormat,
+	"baidu":                        notificationhubs.BaiduFormat,
+}
+
+// Notifications returns a copy of the notifications received, in order
+func (s *Server) Notifications() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Notification, 0, len(s.notifications))
+	for _, n := range s.notifications {
+		s.resolve(n)
+		copied := *n
+		copied.Targets = append([]Target(nil), n.Targets...)
+		result = append(result, copied)
+	}
+	return result
+}
+
+// Notification returns a copy of a notification received, and whether it exists
+func (s *Server) Notification(id string) (Notification, bool) {
+	for _, n := range s.Notifications() {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return Notification{}, false
+}
+
+// Targets returns the devices a notification in format sent to the tag expression would reach
+func (s *Server) Targets(format notificationhubs.NotificationFormat, tagExpression string) ([]Target, error) {
+	expr, err := parseTagExpression(tagExpression)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.targets(format, expr), nil
+}
+
+// MarkHandle makes the fake report outcome, such as WrongToken, for every notification sent to the device handle
+func (s *Server) MarkHandle(handle string, outcome notificationhubs.NotificationOutcomeName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadHandles[handle] = outcome
+}
+
+// targets resolves the devices matching a format and tag expression
+func (s *Server) targets(format notificationhubs.NotificationFormat, expr tagExpression) []Target {
+	var result []Target
+
+	for _, reg := range s.sortedRegistrations() {
+		native := notificationhubs.NotificationFormat(strings.TrimSuffix(string(reg.Target), "template"))
+		if reg.IsTemplate() != (format == notificationhubs.Template) || (format != notificationhubs.Template && native != format) {
+			continue
+		}
+		if !matches(expr, reg.Tags) {
+			continue
+		}
+		result = append(result, s.target(Target{RegistrationID: reg.RegistrationID, Format: native, Handle: reg.Handle}))
+	}
+
+	ids := make([]string, 0, len(s.installations))
+	for id := range s.installations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		inst := s.installations[id].installation
+		native := installationFormats[inst.Platform]
+		tags := append(installationTags(inst), inst.Tags...)
+
+		if format != notificationhubs.Template {
+			if native == format && matches(expr, tags) {
+				result = append(result, s.target(Target{InstallationID: id, Format: native, Handle: inst.PushChannel}))
+			}
+			continue
+		}
+
+		names := make([]string, 0, len(inst.Templates))
+		for name := range inst.Templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if matches(expr, append(tags, inst.Templates[name].Tags...)) {
+				result = append(result, s.target(Target{InstallationID: id, Template: name, Format: native, Handle: inst.PushChannel}))
+			}
+		}
+	}
+	return result
+}
+
+// installationTags returns the tags the hub implicitly adds to an installation
+func installationTags(inst notificationhubs.Installation) []string {
+	tags := []string{notificationhubs.InstallationIDTag(inst.InstallationID)}
+	if inst.UserID != "" {
+		tags = append(tags, notificationhubs.UserIDTag(inst.UserID))
+	}
+	return tags
+}
+
+// target sets the outcome of a target
+func (s *Server) target(t Target) Target {
+	t.Outcome = notificationhubs.Success
+	if outcome, ok := s.deadHandles[t.Handle]; ok {
+		t.Outcome = outcome
+	}
+	return t
+}
+
+// resolve computes the targets of a notification once it's due
+func (s *Server) resolve(n *Notification) {
+	if n.resolved || n.Canceled || (n.ScheduledTime != nil && s.opts.Now().Before(*n.ScheduledTime)) {
+		return
+	}
+	n.resolved = true
+	if n.Direct {
+		for _, handle := range n.DeviceHandles {
+			n.Targets = append(n.Targets, s.target(Target{Format: n.Format, Handle: handle}))
+		}
+		return
+	}
+	expr, _ := parseTagExpression(n.TagExpression) // validated when received
+	n.Targets = s.targets(n.Format, expr)
+}
+
+// serveMessages handles /messages requests
+func (s *Server) serveMessages(w http.ResponseWriter, r *http.Request, segments []string) {
+	_, direct := r.URL.Query()["direct"]
+
+	switch {
+	case len(segments) == 0 || segments[0] == "":
+		if r.Method != http.MethodPost {
+			allowedMethods(w, http.MethodPost)
+			return
+		}
+		if direct {
+			s.receive(w, r, []string{r.Header.Get("ServiceBusNotification-DeviceHandle")}, nil)
+		} else {
+			s.receive(w, r, nil, nil)
+		}
+	case segments[0] == "$batch":
+		if r.Method != http.MethodPost {
+			allowedMethods(w, http.MethodPost)
+			return
+		}
+		if !direct {
+			http.Error(w, "batch sends must be direct", http.StatusBadRequest)
+			return
+		}
+		s.receiveBatch(w, r)
+	default:
+		if r.Method != http.MethodGet {
+			allowedMethods(w, http.MethodGet)
+			return
+		}
+		s.writeTelemetry(w, segments[0])
+	}
+}
+
+// serveScheduled handles /schedulednotifications requests
+func (s *Server) serveScheduled(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) > 0 && segments[0] != "" {
+		if r.Method != http.MethodDelete {
+			allowedMethods(w, http.MethodDelete)
+			return
+		}
+		for _, n := range s.notifications {
+			if n.ID == segments[0] && n.ScheduledTime != nil && !n.resolved && !n.Canceled {
+				s.resolve(n)
+				if !n.resolved {
+					n.Canceled = true
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+			}
+		}
+		http.Error(w, "scheduled notification not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		allowedMethods(w, http.MethodPost)
+		return
+	}
+	scheduleTime, err := time.Parse("2006-01-02T15:04:05", r.Header.Get("ServiceBusNotification-ScheduleTime"))
+	if err != nil {
+		http.Error(w, "invalid schedule time", http.StatusBadRequest)
+		return
+	}
+	s.receive(w, r, nil, &scheduleTime)
+}
+
+// receiveBatch reads a multipart batch send
+func (s *Server) receiveBatch(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		http.Error(w, "batch sends must be multipart", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		reader  = multipart.NewReader(r.Body, params["boundary"])
+		payload []byte
+		handles []string
+	)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "malformed multipart body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, dispositionParams, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		body, _ := io.ReadAll(part)
+		switch dispositionParams["name"] {
+		case "notification":
+			payload = body
+		case "devices":
+			if err := json.Unmarshal(body, &handles); err != nil {
+				http.Error(w, "malformed device list: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if len(handles) == 0 {
+		http.Error(w, "batch send requires devices", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	s.receive(w, r, handles, nil)
+}
+
+// receive validates and stores a notification
+func (s *Server) receive(w http.ResponseWriter, r *http.Request, handles []string, scheduleTime *time.Time) {
+	format := notificationhubs.NotificationFormat(r.Header.Get("ServiceBusNotification-Format"))
+	if !format.IsValid() {
+		http.Error(w, "invalid notification format '"+string(format)+"'", http.StatusBadRequest)
+		return
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = checkPayload(format, payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if format == notificationhubs.WindowsFormat && r.Header.Get("X-WNS-Type") == "" {
+		http.Error(w, "windows notifications require the X-WNS-Type header", http.StatusBadRequest)
+		return
+	}
+
+	n := &Notification{
+		ID:            s.newID(),
+		Format:        format,
+		Payload:       payload,
+		Headers:       r.Header.Clone(),
+		TagExpression: r.Header.Get("ServiceBusNotification-Tags"),
+		Direct:        handles != nil,
+		DeviceHandles: handles,
+		ScheduledTime: scheduleTime,
+		EnqueueTime:   s.opts.Now().UTC(),
+	}
+	if n.Direct {
+		if n.DeviceHandles[0] == "" {
+			http.Error(w, "direct sends require a device handle", http.StatusBadRequest)
+			return
+		}
+		if format == notificationhubs.Template {
+			http.Error(w, "direct sends don't support templates", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, err = parseTagExpression(n.TagExpression); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.notifications = append(s.notifications, n)
+	s.resolve(n)
+
+	w.Header().Set("Location", s.hubURL("messages/"+n.ID))
+	w.Header().Set("TrackingId", n.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkPayload verifies that the payload is well formed for the format
+func checkPayload(format notificationhubs.NotificationFormat, payload []byte) error {
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return fmt.Errorf("notification payload cannot be empty")
+	}
+	if format.GetContentType() == "application/json" {
+		if !json.Valid(payload) {
+			return fmt.Errorf("notification payload is not valid JSON")
+		}
+		return nil
+	}
+	dec := xml.NewDecoder(bytes.NewReader(payload))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("notification payload is not valid XML: %w", err)
+		}
+	}
+}
+
+// writeTelemetry writes the notification details of a notification
+func (s *Server) writeTelemetry(w http.ResponseWriter, id string) {
+	var n *Notification
+	for _, candidate := range s.notifications {
+		if candidate.ID == id {
+			n = candidate
+		}
+	}
+	if n == nil {
+		http.Error(w, "notification not found", http.StatusNotFound)
+		return
+	}
+	s.resolve(n)
+
+	state := notificationhubs.Completed
+	switch {
+	case n.Canceled:
+		state = notificationhubs.Canceled
+	case !n.resolved:
+		state = notificationhubs.Scheduled
+	case len(n.Targets) == 0:
+		state = notificationhubs.NoTargetFound
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="utf-8"?><NotificationDetails xmlns="%s" xmlns:i="%s">`, connectNamespace, instanceNS)
+	fmt.Fprintf(&b, `<NotificationId>%s</NotificationId><Location>%s</Location><State>%s</State>`, escape(n.ID), escape(s.hubURL("messages/"+n.ID)), state)
+	fmt.Fprintf(&b, `<EnqueueTime>%s</EnqueueTime>`, n.EnqueueTime.Format(time.RFC3339Nano))
+	if n.resolved {
+		start := n.EnqueueTime
+		if n.ScheduledTime != nil {
+			start = n.ScheduledTime.UTC()
+		}
+		fmt.Fprintf(&b, `<StartTime>%s</StartTime><EndTime>%s</EndTime>`, start.Format(time.RFC3339Nano), start.Format(time.RFC3339Nano))
+	}
+	fmt.Fprintf(&b, `<NotificationBody>%s</NotificationBody><TargetPlatforms>%s</TargetPlatforms>`, escape(string(n.Payload)), n.Format)
+
+	counts := make(map[string]map[notificationhubs.NotificationOutcomeName]int)
+	failed := false
+	for _, t := range n.Targets {
+		element := outcomeElements[t.Format]
+		if counts[element] == nil {
+			counts[element] = make(map[notificationhubs.NotificationOutcomeName]int)
+		}
+		counts[element][t.Outcome]++
+		failed = failed || t.Outcome.IsFailure()
+	}
+	elements := make([]string, 0, len(counts))
+	for element := range counts {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	for _, element := range elements {
+		fmt.Fprintf(&b, "<%s>", element)
+		outcomes := make([]string, 0, len(counts[element]))
+		for outcome := range counts[element] {
+			outcomes = append(outcomes, string(outcome))
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(&b, "<Outcome><Name>%s</Name><Count>%d</Count></Outcome>", outcome, counts[element][notificationhubs.NotificationOutcomeName(outcome)])
+		}
+		fmt.Fprintf(&b, "</%s>", element)
+	}
+	if failed {
+		fmt.Fprintf(&b, `<PnsErrorDetailsUri>%s</PnsErrorDetailsUri>`, escape(s.URL+blobsPath+n.ID+".txt?sig=fake"))
+	}
+	b.WriteString("</NotificationDetails>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, b.String())
+}
+
+// serveBlob serves the PNS error details of a notification
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("sig") == "" {
+		http.Error(w, "missing blob signature", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, blobsPath), ".txt")
+	for _, n := range s.notifications {
+		if n.ID != id {
+			continue
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for _, t := range n.Targets {
+			if t.Outcome.IsFailure() {
+				_ = enc.Encode(notificationhubs.PnsErrorDetail{
+					RegistrationID:   t.RegistrationID,
+					InstallationID:   t.InstallationID,
+					PnsHandle:        t.Handle,
+					Outcome:          t.Outcome,
+					ErrorDescription: string(t.Outcome),
+				})
+			}
+		}
+		return
+	}
+	http.Error(w, "blob not found", http.StatusNotFound)
+}
@@ -0,0 +1,355 @@
+package notificationhubstest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+)
+
+const (
+	atomContentType  = "application/atom+xml;type=entry;charset=utf-8"
+	hubTimeFormat    = "2006-01-02T15:04:05.000Z"
+	connectNamespace = "http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"
+	instanceNS       = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+type (
+	// Registration is a registration stored by the fake hub
+	Registration struct {
+		RegistrationID string
+		ETag           string
+		Target         notificationhubs.TargetPlatform
+		Handle         string
+		Tags           []string
+		Template       string
+		ExpirationTime time.Time
+		Updated        time.Time
+	}
+
+	// registrationDescription describes how a registration type is serialized
+	registrationDescription struct {
+		element string
+		target  notificationhubs.TargetPlatform
+		handle  string
+	}
+
+	// registrationEntry is the atom entry sent by clients
+	registrationEntry struct {
+		Content struct {
+			Description struct {
+				XMLName             xml.Name
+				Tags                string `xml:"Tags"`
+				BodyTemplate        string `xml:"BodyTemplate"`
+				ExpirationTime      string `xml:"ExpirationTime"`
+				DeviceToken         string `xml:"DeviceToken"`
+				FcmV1RegistrationID string `xml:"FcmV1RegistrationId"`
+				ChannelURI          string `xml:"ChannelUri"`
+				AdmRegistrationID   string `xml:"AdmRegistrationId"`
+				BaiduChannelID      string `xml:"BaiduChannelId"`
+			} `xml:",any"`
+		} `xml:"content"`
+	}
+)
+
+// registrationDescriptions lists the supported registration types
+var registrationDescriptions = []registrationDescription{
+	{"AppleRegistrationDescription", notificationhubs.ApplePlatform, "DeviceToken"},
+	{"AppleTemplateRegistrationDescription", notificationhubs.AppleTemplatePlatform, "DeviceToken"},
+	{"FcmV1RegistrationDescription", notificationhubs.FcmV1Platform, "FcmV1RegistrationId"},
+	{"FcmV1TemplateRegistrationDescription", notificationhubs.FcmV1TemplatePlatform, "FcmV1RegistrationId"},
+	{"WindowsRegistrationDescription", notificationhubs.WindowsPlatform, "ChannelUri"},
+	{"WindowsTemplateRegistrationDescription", notificationhubs.WindowsTemplatePlatform, "ChannelUri"},
+	{"MpnsRegistrationDescription", notificationhubs.WindowsphonePlatform, "ChannelUri"},
+	{"MpnsTemplateRegistrationDescription", notificationhubs.WindowsphoneTemplatePlatform, "ChannelUri"},
+	{"AdmRegistrationDescription", notificationhubs.AdmPlatform, "AdmRegistrationId"},
+	{"AdmTemplateRegistrationDescription", notificationhubs.AdmTemplatePlatform, "AdmRegistrationId"},
+	{"BaiduRegistrationDescription", notificationhubs.BaiduPlatform, "BaiduChannelId"},
+	{"BaiduTemplateRegistrationDescription", notificationhubs.BaiduTemplatePlatform, "BaiduChannelId"},
+}
+
+// Registrations returns a copy of the registrations, ordered by ID
+func (s *Server) Registrations() []Registration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedRegistrations()
+}
+
+// AddRegistration stores a registration directly, without going through the API.
+// A registration ID and ETag are generated if empty.
+func (s *Server) AddRegistration(r Registration) Registration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.RegistrationID == "" {
+		r.RegistrationID = s.newID()
+	}
+	if r.ETag == "" {
+		r.ETag = "1"
+	}
+	if r.ExpirationTime.IsZero() {
+		r.ExpirationTime = endOfTime
+	}
+	if r.Updated.IsZero() {
+		r.Updated = s.opts.Now().UTC()
+	}
+	stored := r
+	stored.Tags = append([]string(nil), r.Tags...)
+	s.registrations[r.RegistrationID] = &stored
+	return r
+}
+
+// IsTemplate identifies whether the registration is a template registration
+func (r Registration) IsTemplate() bool {
+	return strings.HasSuffix(string(r.Target), "template")
+}
+
+// sortedRegistrations returns a copy of the registrations ordered by ID
+func (s *Server) sortedRegistrations() []Registration {
+	result := make([]Registration, 0, len(s.registrations))
+	for _, r := range s.registrations {
+		copied := *r
+		copied.Tags = append([]string(nil), r.Tags...)
+		result = append(result, copied)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RegistrationID < result[j].RegistrationID })
+	return result
+}
+
+// serveRegistrations handles /registrations requests
+func (s *Server) serveRegistrations(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 0 || segments[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			s.writeRegistrationPage(w, r, s.listedRegistrations(""))
+		case http.MethodPost:
+			s.putRegistration(w, r, s.newID())
+		default:
+			allowedMethods(w, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	id := segments[0]
+	switch r.Method {
+	case http.MethodGet:
+		reg, ok := s.registrations[id]
+		if !ok {
+			http.Error(w, "registration not found", http.StatusNotFound)
+			return
+		}
+		s.writeRegistration(w, http.StatusOK, reg)
+	case http.MethodPut:
+		if reg, ok := s.registrations[id]; ok && !matchesETag(r, reg.ETag) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+		s.putRegistration(w, r, id)
+	case http.MethodDelete:
+		reg, ok := s.registrations[id]
+		if !ok {
+			http.Error(w, "registration not found", http.StatusNotFound)
+			return
+		}
+		if !matchesETag(r, reg.ETag) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+		delete(s.registrations, id)
+		w.WriteHeader(http.StatusOK)
+	default:
+		allowedMethods(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// serveTags handles /tags/{tag}/registrations requests
+func (s *Server) serveTags(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) != 2 || segments[0] == "" || segments[1] != "registrations" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		allowedMethods(w, http.MethodGet)
+		return
+	}
+	s.writeRegistrationPage(w, r, s.listedRegistrations(segments[0]))
+}
+
+// listedRegistrations returns the registrations listed by the API, ordered by ID, optionally filtered by tag.
+// Like the hub, every installation is listed as a registration carrying its implicit tags.
+func (s *Server) listedRegistrations(tag string) []Registration {
+	result := s.sortedRegistrations()
+	for id, entry := range s.installations {
+		inst := entry.installation
+		result = append(result, Registration{
+			RegistrationID: "installation-" + id,
+			ETag:           "1",
+			Target:         installationTargets[inst.Platform],
+			Handle:         inst.PushChannel,
+			Tags:           append(installationTags(inst), inst.Tags...),
+			ExpirationTime: *inst.ExpirationTime,
+			Updated:        *inst.LastUpdate,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RegistrationID < result[j].RegistrationID })
+
+	if tag == "" {
+		return result
+	}
+	filtered := result[:0]
+	for _, reg := range result {
+		for _, t := range reg.Tags {
+			if t == tag {
+				filtered = append(filtered, reg)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// writeRegistrationPage writes the page of registrations selected by the $top and ContinuationToken parameters.
+// The continuation token is the offset of the next page.
+func (s *Server) writeRegistrationPage(w http.ResponseWriter, r *http.Request, regs []Registration) {
+	var (
+		query  = r.URL.Query()
+		offset = 0
+	)
+	if token := query.Get("ContinuationToken"); token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 || offset > len(regs) {
+			http.Error(w, "invalid continuation token", http.StatusBadRequest)
+			return
+		}
+	}
+	regs = regs[offset:]
+	if top, err := strconv.Atoi(query.Get("$top")); err == nil && top > 0 && top < len(regs) {
+		regs = regs[:top]
+		w.Header().Set("X-MS-ContinuationToken", strconv.Itoa(offset+top))
+	}
+	s.writeRegistrationFeed(w, regs)
+}
+
+// putRegistration creates or replaces a registration from an atom entry
+func (s *Server) putRegistration(w http.ResponseWriter, r *http.Request, id string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var entry registrationEntry
+	if err = xml.Unmarshal(body, &entry); err != nil {
+		http.Error(w, "malformed registration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d := entry.Content.Description
+	var desc *registrationDescription
+	for i := range registrationDescriptions {
+		if registrationDescriptions[i].element == d.XMLName.Local {
+			desc = &registrationDescriptions[i]
+		}
+	}
+	if desc == nil {
+		http.Error(w, "unsupported registration type '"+d.XMLName.Local+"'", http.StatusBadRequest)
+		return
+	}
+
+	reg := &Registration{
+		RegistrationID: id,
+		ETag:           "1",
+		Target:         desc.target,
+		Handle:         firstNonEmpty(d.DeviceToken, d.FcmV1RegistrationID, d.ChannelURI, d.AdmRegistrationID, d.BaiduChannelID),
+		Template:       d.BodyTemplate,
+		ExpirationTime: endOfTime,
+		Updated:        s.opts.Now().UTC(),
+	}
+	if reg.Handle == "" {
+		http.Error(w, "registration is missing the device handle", http.StatusBadRequest)
+		return
+	}
+	if reg.IsTemplate() && reg.Template == "" {
+		http.Error(w, "template registration is missing the body template", http.StatusBadRequest)
+		return
+	}
+	if tags := strings.TrimSpace(d.Tags); tags != "" {
+		reg.Tags = strings.Split(tags, ",")
+	}
+	if d.ExpirationTime != "" {
+		if t, err := time.Parse(time.RFC3339Nano, d.ExpirationTime); err == nil {
+			reg.ExpirationTime = t
+		}
+	}
+	if existing, ok := s.registrations[id]; ok {
+		etag, _ := strconv.Atoi(existing.ETag)
+		reg.ETag = strconv.Itoa(etag + 1)
+	}
+
+	s.registrations[id] = reg
+	s.writeRegistration(w, http.StatusOK, reg)
+}
+
+// writeRegistration writes a registration atom entry
+func (s *Server) writeRegistration(w http.ResponseWriter, status int, reg *Registration) {
+	w.Header().Set("Content-Type", atomContentType)
+	w.Header().Set("ETag", reg.ETag)
+	w.WriteHeader(status)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n")
+	io.WriteString(w, s.registrationEntryXML(reg, true))
+}
+
+// writeRegistrationFeed writes an atom feed of registrations
+func (s *Server) writeRegistrationFeed(w http.ResponseWriter, regs []Registration) {
+	w.Header().Set("Content-Type", "application/atom+xml;type=feed;charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title type="text">Registrations</title><id>%s</id><updated>%s</updated>`,
+		escape(s.hubURL("registrations")), s.opts.Now().UTC().Format(time.RFC3339))
+	for i := range regs {
+		io.WriteString(w, s.registrationEntryXML(&regs[i], false))
+	}
+	io.WriteString(w, "</feed>")
+}
+
+// registrationEntryXML serializes a registration as an atom entry
+func (s *Server) registrationEntryXML(reg *Registration, root bool) string {
+	var desc registrationDescription
+	for _, d := range registrationDescriptions {
+		if d.target == reg.Target {
+			desc = d
+		}
+	}
+
+	var b strings.Builder
+	if root {
+		b.WriteString(`<entry xmlns="http://www.w3.org/2005/Atom" xmlns:m="http://schemas.microsoft.com/ado/2007/08/dataservices/metadata"`)
+	} else {
+		b.WriteString(`<entry xmlns:m="http://schemas.microsoft.com/ado/2007/08/dataservices/metadata"`)
+	}
+	fmt.Fprintf(&b, ` m:etag="W/&quot;%s&quot;">`, escape(reg.ETag))
+	fmt.Fprintf(&b, `<id>%s</id><title type="text">%s</title>`, escape(s.hubURL("registrations/"+reg.RegistrationID)), escape(reg.RegistrationID))
+	fmt.Fprintf(&b, `<published>%s</published><updated>%s</updated>`, reg.Updated.Format(time.RFC3339), reg.Updated.Format(time.RFC3339))
+	fmt.Fprintf(&b, `<content type="application/xml"><%s xmlns:i="%s" xmlns="%s">`, desc.element, instanceNS, connectNamespace)
+	fmt.Fprintf(&b, `<ETag>%s</ETag><ExpirationTime>%s</ExpirationTime><RegistrationId>%s</RegistrationId>`,
+		escape(reg.ETag), reg.ExpirationTime.UTC().Format(hubTimeFormat), escape(reg.RegistrationID))
+	if len(reg.Tags) > 0 {
+		fmt.Fprintf(&b, `<Tags>%s</Tags>`, escape(strings.Join(reg.Tags, ",")))
+	}
+	fmt.Fprintf(&b, `<%s>%s</%s>`, desc.handle, escape(reg.Handle), desc.handle)
+	if reg.IsTemplate() {
+		fmt.Fprintf(&b, `<BodyTemplate>%s</BodyTemplate>`, escape(reg.Template))
+	}
+	fmt.Fprintf(&b, `</%s></content></entry>`, desc.element)
+	return b.String()
+}
+
+// matchesETag evaluates the If-Match header of a request
+func matchesETag(r *http.Request, etag string) bool {
+	ifMatch := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`)
+	return ifMatch == "" || ifMatch == "*" || ifMatch == etag
+}
@@ -0,0 +1,280 @@
+// Package notificationhubstest provides an in-memory fake Azure Notification Hub for tests.
+//
+// The fake speaks the hub REST API over an httptest.Server: registrations (Atom XML),
+// paged registration listings by tag, installations (JSON and JSON Patch),
+// direct, batch, tagged and scheduled sends, per message telemetry, cancellation of scheduled notifications
+// and the management of the hub descriptions of the namespace with their authorization rules and PNS credentials.
+// Inspection methods make it possible to assert which devices a send reached.
+package notificationhubstest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// Defaults of the fake hub
+const (
+	DefaultHubPath = "testhub"
+	DefaultKeyName = "DefaultFullSharedAccessSignature"
+	DefaultKey     = "dGVzdEFjY2Vzc0tleQ=="
+
+	sasPrefix = "SharedAccessSignature "
+)
+
+type (
+	// Options configures the fake hub
+	Options struct {
+		// HubPath is the hub served by the fake. Defaults to DefaultHubPath.
+		HubPath string
+		// KeyName and Key are the shared access key expected in signatures.
+		// Default to DefaultKeyName and DefaultKey.
+		KeyName string
+		Key     string
+		// SkipSignatureCheck accepts any Authorization header
+		SkipSignatureCheck bool
+		// Now returns the current time of the fake, used for scheduled sends
+		// and token expiry. Defaults to time.Now.
+		Now func() time.Time
+	}
+
+	// Server is a fake Azure Notification Hub
+	Server struct {
+		URL string
+
+		opts   Options
+		server *httptest.Server
+
+		mu            sync.Mutex
+		nextID        int64
+		registrations map[string]*Registration
+		installations map[string]*installationEntry
+		notifications []*Notification
+		deadHandles   map[string]notificationhubs.NotificationOutcomeName
+		faults        []fault
+		hubs          map[string]*hubResource
+	}
+
+	// fault is an injected failure response
+	fault struct {
+		statusCode int
+		remaining  int
+	}
+)
+
+// NewServer starts and returns a fake hub. Call Close when done.
+func NewServer(opts *Options) *Server {
+	s := &Server{
+		registrations: make(map[string]*Registration),
+		installations: make(map[string]*installationEntry),
+		deadHandles:   make(map[string]notificationhubs.NotificationOutcomeName),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.HubPath == "" {
+		s.opts.HubPath = DefaultHubPath
+	}
+	s.opts.HubPath = strings.Trim(s.opts.HubPath, "/")
+	if s.opts.KeyName == "" {
+		s.opts.KeyName = DefaultKeyName
+	}
+	if s.opts.Key == "" {
+		s.opts.Key = DefaultKey
+	}
+	if s.opts.Now == nil {
+		s.opts.Now = time.Now
+	}
+	s.hubs = s.defaultHubs()
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts down the fake hub
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// HubPath returns the path of the hub served by the fake
+func (s *Server) HubPath() string {
+	return s.opts.HubPath
+}
+
+// ConnectionString returns a connection string pointing at the fake
+func (s *Server) ConnectionString() string {
+	return fmt.Sprintf("Endpoint=%s/;SharedAccessKeyName=%s;SharedAccessKey=%s", s.URL, s.opts.KeyName, s.opts.Key)
+}
+
+// NewHub returns a client for the fake hub
+func (s *Server) NewHub() (*notificationhubs.NotificationHub, error) {
+	return notificationhubs.NewNotificationHub(s.ConnectionString(), s.opts.HubPath)
+}
+
+// FailNext makes the next n requests fail with statusCode
+func (s *Server) FailNext(statusCode, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, fault{statusCode: statusCode, remaining: n})
+}
+
+// Reset removes all registrations, installations, notifications and injected failures,
+// and restores the served hub as the only hub of the namespace
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hubs = s.defaultHubs()
+	s.registrations = make(map[string]*Registration)
+	s.installations = make(map[string]*installationEntry)
+	s.notifications = nil
+	s.deadHandles = make(map[string]notificationhubs.NotificationOutcomeName)
+	s.faults = nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, blobsPath) {
+		s.serveBlob(w, r)
+		return
+	}
+
+	if err := s.checkSignature(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.faults) > 0 {
+		f := &s.faults[0]
+		f.remaining--
+		if f.remaining <= 0 {
+			s.faults = s.faults[1:]
+		}
+		http.Error(w, http.StatusText(f.statusCode), f.statusCode)
+		return
+	}
+
+	if r.URL.Path == hubsResourcePath || strings.Count(strings.TrimSuffix(r.URL.Path, "/"), "/") == 1 {
+		s.serveHubs(w, r)
+		return
+	}
+
+	hubPrefix := "/" + s.opts.HubPath + "/"
+	if _, ok := s.hubs[s.opts.HubPath]; !ok || !strings.HasPrefix(r.URL.Path, hubPrefix) {
+		http.Error(w, "hub not found", http.StatusNotFound)
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, hubPrefix), "/")
+
+	switch segments[0] {
+	case "registrations":
+		s.serveRegistrations(w, r, segments[1:])
+	case "installations":
+		s.serveInstallations(w, r, segments[1:])
+	case "tags":
+		s.serveTags(w, r, segments[1:])
+	case "messages":
+		s.serveMessages(w, r, segments[1:])
+	case "schedulednotifications":
+		s.serveScheduled(w, r, segments[1:])
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// checkSignature verifies the shared access signature of a request
+func (s *Server) checkSignature(r *http.Request) error {
+	if s.opts.SkipSignatureCheck {
+		return nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, sasPrefix) {
+		return fmt.Errorf("missing shared access signature")
+	}
+	params, err := url.ParseQuery(strings.TrimPrefix(auth, sasPrefix))
+	if err != nil {
+		return fmt.Errorf("malformed shared access signature: %w", err)
+	}
+
+	var (
+		resource = params.Get("sr")
+		expiry   = params.Get("se")
+	)
+	hub, right := requiredRight(r)
+	keys := s.ruleKeys(hub, params.Get("skn"), right)
+	if params.Get("skn") == s.opts.KeyName {
+		keys = append(keys, s.opts.Key)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("key '%s' doesn't grant %s on '%s'", params.Get("skn"), right, hub)
+	}
+	if resource == "" || !strings.HasPrefix(strings.ToLower(s.URL+r.URL.Path), resource) {
+		return fmt.Errorf("signature resource '%s' doesn't match the hub", resource)
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || expires < s.opts.Now().Unix() {
+		return fmt.Errorf("signature expired")
+	}
+
+	for _, key := range keys {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(url.QueryEscape(resource) + "\n" + expiry))
+		expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(expected), []byte(params.Get("sig"))) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid signature")
+}
+
+// endOfTime is the expiration time of registrations that never expire
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 999000000, time.UTC)
+
+// hubURL returns the absolute URL of a resource in the hub
+func (s *Server) hubURL(resource string) string {
+	return fmt.Sprintf("%s/%s/%s?api-version=%s", s.URL, s.opts.HubPath, resource, notificationhubs.LatestAPIVersion)
+}
+
+// newID generates an identifier resembling the ones used by the hub
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%d-%d-%d", 1000000000000000000+s.nextID, 2000000000000000000+s.nextID, s.nextID)
+}
+
+// escape escapes s for use as XML character data
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// firstNonEmpty returns the first non empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// allowedMethods writes a method not allowed response
+func allowedMethods(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,756 @@
+package notificationhubstest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+	"github.com/koreset/azure-notifications-sdk-go/notificationhubstest"
+)
+
+const errfmt = "Expected %s: \n%v\ngot:\n%v"
+
+func newTestHub(t *testing.T, opts *notificationhubstest.Options) (*notificationhubstest.Server, *notificationhubs.NotificationHub) {
+	t.Helper()
+	server := notificationhubstest.NewServer(opts)
+	t.Cleanup(server.Close)
+	hub, err := server.NewHub()
+	if err != nil {
+		t.Fatalf(errfmt, "hub error", nil, err)
+	}
+	return server, hub
+}
+
+func Test_Registrations(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+
+	_, result, err := hub.Register(ctx, notificationhubs.Registration{
+		DeviceID:           "ABCDEF",
+		NotificationFormat: notificationhubs.AppleFormat,
+		Tags:               "tag1,tag2",
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "register error", nil, err)
+	}
+	device := result.RegistrationContent.RegisteredDevice
+	if device.DeviceID != "ABCDEF" || device.ETag != "1" || !reflect.DeepEqual(device.Tags, []string{"tag1", "tag2"}) {
+		t.Errorf(errfmt, "registered device", "ABCDEF with tags", device)
+	}
+
+	_, result, err = hub.RegisterWithTemplate(ctx, notificationhubs.TemplateRegistration{
+		DeviceID:       "fcmv1_token",
+		RegistrationID: device.RegistrationID,
+		Tags:           "tag3",
+		Platform:       notificationhubs.FcmV1Platform,
+		Template:       `{"message":{"notification":{"body":"$(message)"}}}`,
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "update error", nil, err)
+	}
+	if result.RegistrationContent.Target != notificationhubs.FcmV1TemplatePlatform || result.RegistrationContent.RegisteredDevice.ETag != "2" {
+		t.Errorf(errfmt, "updated registration", notificationhubs.FcmV1TemplatePlatform, result.RegistrationContent)
+	}
+
+	_, registrations, err := hub.Registrations(ctx)
+	if err != nil || len(registrations.Entries) != 1 {
+		t.Fatalf(errfmt, "registrations", 1, registrations)
+	}
+
+	err = hub.Unregister(ctx, notificationhubs.RegisteredDevice{RegistrationID: device.RegistrationID, ETag: "1"})
+	var hubErr *notificationhubs.NotificationHubError
+	if !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf(errfmt, "stale ETag error", http.StatusPreconditionFailed, err)
+	}
+	if err = hub.Unregister(ctx, notificationhubs.RegisteredDevice{RegistrationID: device.RegistrationID, ETag: "*"}); err != nil {
+		t.Errorf(errfmt, "unregister error", nil, err)
+	}
+	if _, _, err = hub.Registration(ctx, device.RegistrationID); !errors.As(err, &hubErr) || !hubErr.IsNotFound() {
+		t.Errorf(errfmt, "error", "not found", err)
+	}
+	if len(server.Registrations()) != 0 {
+		t.Errorf(errfmt, "registrations", 0, server.Registrations())
+	}
+}
+
+func Test_Installations(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+
+	err := hub.Install(ctx, notificationhubs.Installation{
+		InstallationID: "installation1",
+		Platform:       notificationhubs.FCMV1Platform,
+		PushChannel:    "fcmv1_token",
+		Tags:           []string{"tag1"},
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "install error", nil, err)
+	}
+
+	err = hub.Update(ctx, "installation1",
+		notificationhubs.AddTag("tag2"),
+		notificationhubs.RemoveTag("tag1"),
+		notificationhubs.AddTemplate("greeting", notificationhubs.InstallationTemplate{Body: `{"message":{}}`}),
+		notificationhubs.SetTemplateTags("greeting", "template-tag"),
+	)
+	if err != nil {
+		t.Fatalf(errfmt, "update error", nil, err)
+	}
+
+	_, installation, err := hub.Installation(ctx, "installation1")
+	if err != nil {
+		t.Fatalf(errfmt, "installation error", nil, err)
+	}
+	if !reflect.DeepEqual(installation.Tags, []string{"tag2"}) || !reflect.DeepEqual(installation.Templates["greeting"].Tags, []string{"template-tag"}) {
+		t.Errorf(errfmt, "installation", "patched tags", installation)
+	}
+	if installation.LastUpdate == nil {
+		t.Errorf(errfmt, "last update", "a timestamp", nil)
+	}
+
+	if err = hub.Update(ctx, "installation1", notificationhubs.InstallationChange{Op: notificationhubs.InstallationChangeAdd, Path: "/unknown"}); err == nil {
+		t.Errorf(errfmt, "error", "bad request", err)
+	}
+	if err = hub.Install(ctx, notificationhubs.Installation{InstallationID: "installation2", Platform: notificationhubs.APNSPlatform}); err == nil {
+		t.Errorf(errfmt, "error", "missing push channel", err)
+	}
+
+	if err = hub.Uninstall(ctx, "installation1"); err != nil {
+		t.Errorf(errfmt, "uninstall error", nil, err)
+	}
+	if len(server.Installations()) != 0 {
+		t.Errorf(errfmt, "installations", 0, server.Installations())
+	}
+}
+
+func Test_ModifyRegistration(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+		reg         = server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.ApplePlatform, Handle: "token", Tags: []string{"a"}})
+		attempts    = 0
+		requests    []int
+	)
+	hub.Use(func(next notificationhubs.RequestHandler) notificationhubs.RequestHandler {
+		return func(operation notificationhubs.Operation, req *http.Request) ([]byte, *http.Response, error) {
+			requests = append(requests, notificationhubs.RequestAttempt(req.Context()))
+			return next(operation, req)
+		}
+	})
+
+	_, result, err := hub.ModifyRegistration(ctx, reg.RegistrationID, func(device *notificationhubs.RegisteredDevice) error {
+		attempts++
+		if attempts == 1 { // a concurrent writer wins the first round
+			server.AddRegistration(notificationhubstest.Registration{
+				RegistrationID: reg.RegistrationID, ETag: "2", Target: notificationhubs.ApplePlatform, Handle: "token", Tags: []string{"a", "b"},
+			})
+		}
+		device.Tags = append(device.Tags, "c")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "modify error", nil, err)
+	}
+	if attempts != 2 {
+		t.Errorf(errfmt, "attempts", 2, attempts)
+	}
+	// read-modify-write rounds aren't HTTP retries, every request is a first attempt
+	if want := []int{1, 1, 1, 1}; !reflect.DeepEqual(requests, want) {
+		t.Errorf(errfmt, "request attempts", want, requests)
+	}
+	if tags := result.RegistrationContent.RegisteredDevice.Tags; !reflect.DeepEqual(tags, []string{"a", "b", "c"}) {
+		t.Errorf(errfmt, "tags", []string{"a", "b", "c"}, tags)
+	}
+
+	modifyErr := errors.New("rejected")
+	if _, _, err = hub.ModifyRegistration(ctx, reg.RegistrationID, func(*notificationhubs.RegisteredDevice) error { return modifyErr }); err != modifyErr {
+		t.Errorf(errfmt, "callback error", modifyErr, err)
+	}
+
+	// fields that can't be written back are rejected, not dropped
+	expiration := time.Now().Add(time.Hour)
+	unsupported := map[string]func(*notificationhubs.RegisteredDevice){
+		"expiration time":          func(device *notificationhubs.RegisteredDevice) { device.ExpirationTime = &expiration },
+		"template of a native one": func(device *notificationhubs.RegisteredDevice) { device.Template = `{"aps":{}}` },
+	}
+	for name, modify := range unsupported {
+		requests = nil
+		_, _, err = hub.ModifyRegistration(ctx, reg.RegistrationID, func(device *notificationhubs.RegisteredDevice) error {
+			device.Tags = append(device.Tags, "d")
+			modify(device)
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "can be modified") {
+			t.Errorf(errfmt, name+" error", "can't be modified", err)
+		}
+		if len(requests) != 1 {
+			t.Errorf(errfmt, name+" requests", "the read only", requests)
+		}
+	}
+}
+
+func Test_InstallationConcurrency(t *testing.T) {
+	var (
+		_, hub       = newTestHub(t, nil)
+		ctx          = context.Background()
+		installation = notificationhubs.Installation{InstallationID: "device", Platform: notificationhubs.APNSPlatform, PushChannel: "token"}
+		conflict     = notificationhubs.NewError(notificationhubs.ErrorCodePreconditionFailed, "")
+	)
+
+	if err := hub.InstallIfNotExists(ctx, installation); err != nil {
+		t.Fatalf(errfmt, "create error", nil, err)
+	}
+	if err := hub.InstallIfNotExists(ctx, installation); !errors.Is(err, conflict) {
+		t.Errorf(errfmt, "second create error", conflict, err)
+	}
+
+	_, first, _ := hub.Installation(ctx, "device")
+	_, second, _ := hub.Installation(ctx, "device")
+	if first.ETag == "" {
+		t.Fatalf(errfmt, "ETag", "set", first.ETag)
+	}
+	if err := hub.UpdateIfMatch(ctx, "device", first.ETag, notificationhubs.AddTag("first")); err != nil {
+		t.Fatalf(errfmt, "first update error", nil, err)
+	}
+	if err := hub.UpdateIfMatch(ctx, "device", second.ETag, notificationhubs.AddTag("second")); !errors.Is(err, conflict) {
+		t.Errorf(errfmt, "stale update error", conflict, err)
+	}
+	if err := hub.UninstallIfMatch(ctx, "device", second.ETag); !errors.Is(err, conflict) {
+		t.Errorf(errfmt, "stale delete error", conflict, err)
+	}
+
+	_, current, _ := hub.Installation(ctx, "device")
+	if !reflect.DeepEqual(current.Tags, []string{"first"}) {
+		t.Errorf(errfmt, "tags", []string{"first"}, current.Tags)
+	}
+	if err := hub.UninstallIfMatch(ctx, "device", current.ETag); err != nil {
+		t.Errorf(errfmt, "delete error", nil, err)
+	}
+	if exists, err := hub.InstallationExists(ctx, "device"); exists || err != nil {
+		t.Errorf(errfmt, "exists", false, exists)
+	}
+}
+
+func Test_InstallationListing(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		tags := []string{"all"}
+		if id == "b" || id == "d" {
+			tags = append(tags, notificationhubs.UserIDTag("alice"))
+		}
+		server.AddInstallation(notificationhubs.Installation{
+			InstallationID: id,
+			Platform:       notificationhubs.FCMV1Platform,
+			PushChannel:    "channel-" + id,
+			Tags:           tags,
+		})
+	}
+	server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.ApplePlatform, Handle: "apple", Tags: []string{"all"}})
+
+	page, err := hub.InstallationsPage(ctx, &notificationhubs.InstallationQuery{Top: 2})
+	if err != nil {
+		t.Fatalf(errfmt, "page error", nil, err)
+	}
+	if len(page.Installations) > 2 || page.ContinuationToken == "" {
+		t.Errorf(errfmt, "first page", "at most 2 installations and a continuation token", page)
+	}
+
+	var ids []string
+	for installation, err := range hub.Installations(ctx, &notificationhubs.InstallationQuery{Tag: "all", Top: 2}) {
+		if err != nil {
+			t.Fatalf(errfmt, "iteration error", nil, err)
+		}
+		ids = append(ids, installation.InstallationID)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf(errfmt, "installations", []string{"a", "b", "c", "d", "e"}, ids)
+	}
+
+	ids = nil
+	for installation, err := range hub.InstallationsByUserID(ctx, "alice") {
+		if err != nil {
+			t.Fatalf(errfmt, "iteration error", nil, err)
+		}
+		ids = append(ids, installation.InstallationID)
+	}
+	if !reflect.DeepEqual(ids, []string{"b", "d"}) {
+		t.Errorf(errfmt, "user installations", []string{"b", "d"}, ids)
+	}
+
+	page, err = hub.InstallationsPage(ctx, &notificationhubs.InstallationQuery{PushChannel: "channel-c"})
+	if err != nil {
+		t.Fatalf(errfmt, "page error", nil, err)
+	}
+	if len(page.Installations) != 1 || page.Installations[0].InstallationID != "c" || page.ContinuationToken != "" {
+		t.Errorf(errfmt, "installations by push channel", "c", page)
+	}
+}
+
+func Test_InstallationUserID(t *testing.T) {
+	var (
+		_, hub       = newTestHub(t, nil)
+		ctx          = context.Background()
+		installation = notificationhubs.Installation{InstallationID: "phone", Platform: notificationhubs.FCMV1Platform, PushChannel: "phone", UserID: "alice"}
+	)
+	userInstallations := func(userID string) []string {
+		var ids []string
+		for installation, err := range hub.InstallationsByUserID(ctx, userID) {
+			if err != nil {
+				t.Fatalf(errfmt, "iteration error", nil, err)
+			}
+			ids = append(ids, installation.InstallationID)
+		}
+		return ids
+	}
+
+	if err := hub.Install(ctx, installation); err != nil {
+		t.Fatalf(errfmt, "install error", nil, err)
+	}
+	if ids := userInstallations("alice"); !reflect.DeepEqual(ids, []string{"phone"}) {
+		t.Errorf(errfmt, "alice installations", []string{"phone"}, ids)
+	}
+
+	desired := installation
+	desired.UserID = "bob"
+	changes, _ := notificationhubs.DiffInstallations(installation, desired)
+	if err := hub.Update(ctx, installation.InstallationID, changes...); err != nil {
+		t.Fatalf(errfmt, "update error", nil, err)
+	}
+	if ids := userInstallations("alice"); len(ids) != 0 {
+		t.Errorf(errfmt, "alice installations", nil, ids)
+	}
+	if ids := userInstallations("bob"); !reflect.DeepEqual(ids, []string{"phone"}) {
+		t.Errorf(errfmt, "bob installations", []string{"phone"}, ids)
+	}
+
+	if err := hub.Update(ctx, installation.InstallationID, notificationhubs.RemoveUserID()); err != nil {
+		t.Fatalf(errfmt, "update error", nil, err)
+	}
+	if _, current, _ := hub.Installation(ctx, installation.InstallationID); current.UserID != "" {
+		t.Errorf(errfmt, "user ID", "", current.UserID)
+	}
+
+	// a missing user ID can be added, not replaced
+	if err := hub.Update(ctx, installation.InstallationID, notificationhubs.SetUserID("alice")); err == nil {
+		t.Errorf(errfmt, "replace error", "user ID doesn't exist", nil)
+	}
+	if err := hub.Update(ctx, installation.InstallationID, notificationhubs.AddUserID("alice")); err != nil {
+		t.Fatalf(errfmt, "update error", nil, err)
+	}
+	if ids := userInstallations("alice"); !reflect.DeepEqual(ids, []string{"phone"}) {
+		t.Errorf(errfmt, "alice installations", []string{"phone"}, ids)
+	}
+}
+
+func Test_Send(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+	apple := server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.ApplePlatform, Handle: "apple1", Tags: []string{"sports", "boston"}})
+	server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.ApplePlatform, Handle: "apple2", Tags: []string{"news"}})
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "fcm1", Platform: notificationhubs.FCMV1Platform, PushChannel: "fcm1", Tags: []string{"sports"}})
+
+	notification, _ := notificationhubs.NewNotification(notificationhubs.AppleFormat, []byte(`{"aps":{"alert":"score"}}`))
+	tags := "sports && (boston || denver)"
+	_, telemetry, err := hub.Send(ctx, notification, &tags)
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+
+	sent, ok := server.Notification(telemetry.NotificationMessageID)
+	if !ok {
+		t.Fatalf(errfmt, "notification", telemetry.NotificationMessageID, server.Notifications())
+	}
+	expected := []notificationhubstest.Target{{RegistrationID: apple.RegistrationID, Format: notificationhubs.AppleFormat, Handle: "apple1", Outcome: notificationhubs.Success}}
+	if !reflect.DeepEqual(sent.Targets, expected) {
+		t.Errorf(errfmt, "targets", expected, sent.Targets)
+	}
+	if sent.Headers.Get("X-Apns-Push-Type") != "alert" {
+		t.Errorf(errfmt, "APNS push type", "alert", sent.Headers.Get("X-Apns-Push-Type"))
+	}
+
+	fcm, _ := notificationhubs.NewNotification(notificationhubs.FcmV1Format, []byte(`{"message":{}}`))
+	installationTag := "$InstallationId:{fcm1}"
+	_, telemetry, err = hub.Send(ctx, fcm, &installationTag)
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+	details, _, err := hub.NotificationDetails(ctx, telemetry.NotificationMessageID)
+	if err != nil {
+		t.Fatalf(errfmt, "telemetry error", nil, err)
+	}
+	if details.State != notificationhubs.Completed || details.FcmV1OutcomeCounts.TotalByOutcome(notificationhubs.Success) != 1 {
+		t.Errorf(errfmt, "telemetry", "1 FCM v1 success", details)
+	}
+
+	invalid, _ := notificationhubs.NewNotification(notificationhubs.AppleFormat, []byte(`{"aps":`))
+	if _, _, err = hub.Send(ctx, invalid, nil); err == nil {
+		t.Errorf(errfmt, "error", "invalid payload", err)
+	}
+}
+
+func Test_SendToInstallationAndUser(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "phone", Platform: notificationhubs.FCMV1Platform, PushChannel: "phone", UserID: "alice"})
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "tablet", Platform: notificationhubs.FCMV1Platform, PushChannel: "tablet", UserID: "alice"})
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "other", Platform: notificationhubs.FCMV1Platform, PushChannel: "other", UserID: "bob"})
+
+	notification, _ := notificationhubs.NewNotification(notificationhubs.FcmV1Format, []byte(`{"message":{}}`))
+	handles := func(telemetry *notificationhubs.NotificationTelemetry) []string {
+		sent, _ := server.Notification(telemetry.NotificationMessageID)
+		var result []string
+		for _, target := range sent.Targets {
+			result = append(result, target.Handle)
+		}
+		return result
+	}
+
+	_, telemetry, err := hub.SendToInstallation(ctx, notification, "tablet")
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+	if got := handles(telemetry); !reflect.DeepEqual(got, []string{"tablet"}) {
+		t.Errorf(errfmt, "installation targets", []string{"tablet"}, got)
+	}
+
+	_, telemetry, err = hub.SendToUser(ctx, notification, "alice")
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+	if got := handles(telemetry); !reflect.DeepEqual(got, []string{"phone", "tablet"}) {
+		t.Errorf(errfmt, "user targets", []string{"phone", "tablet"}, got)
+	}
+
+	if _, _, err = hub.SendToUser(ctx, notification, ""); err == nil {
+		t.Errorf(errfmt, "error", "empty user ID", err)
+	}
+}
+
+func Test_SendMultiPlatform(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+		tags        = "sports"
+	)
+	server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.ApplePlatform, Handle: "apple", Tags: []string{"sports"}})
+	server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.WindowsPlatform, Handle: "https://wns", Tags: []string{"sports"}})
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "fcm", Platform: notificationhubs.FCMV1Platform, PushChannel: "fcm", Tags: []string{"sports"}})
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "news", Platform: notificationhubs.FCMV1Platform, PushChannel: "news", Tags: []string{"news"}})
+
+	result, err := hub.SendMultiPlatform(ctx, &notificationhubs.MultiPlatformMessage{Title: "Goal", Body: "1 - 0", DeepLink: "app://match/1"}, &tags)
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+
+	expected := map[notificationhubs.NotificationFormat]string{
+		notificationhubs.AppleFormat:   "apple",
+		notificationhubs.FcmV1Format:   "fcm",
+		notificationhubs.WindowsFormat: "https://wns",
+	}
+	for format, handle := range expected {
+		sent, ok := server.Notification(result.Results[format].Telemetry.NotificationMessageID)
+		if !ok || len(sent.Targets) != 1 || sent.Targets[0].Handle != handle {
+			t.Errorf(errfmt, string(format)+" targets", handle, sent)
+		}
+	}
+}
+
+func Test_SendDirectAndBatch(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+	notification, _ := notificationhubs.NewNotification(notificationhubs.FcmV1Format, []byte(`{"message":{}}`))
+
+	if _, _, err := hub.SendDirect(ctx, notification, "handle1"); err != nil {
+		t.Fatalf(errfmt, "direct send error", nil, err)
+	}
+	if _, _, err := hub.SendDirectBatch(ctx, notification, "handle2", "handle3"); err != nil {
+		t.Fatalf(errfmt, "batch send error", nil, err)
+	}
+
+	sent := server.Notifications()
+	if len(sent) != 2 {
+		t.Fatalf(errfmt, "notifications", 2, sent)
+	}
+	if !reflect.DeepEqual(sent[1].DeviceHandles, []string{"handle2", "handle3"}) || string(sent[1].Payload) != `{"message":{}}` {
+		t.Errorf(errfmt, "batch", "2 handles", sent[1])
+	}
+	if len(sent[0].Targets) != 1 || sent[0].Targets[0].Handle != "handle1" {
+		t.Errorf(errfmt, "direct targets", "handle1", sent[0].Targets)
+	}
+}
+
+func Test_ScheduleAndTelemetry(t *testing.T) {
+	var (
+		now         = time.Now().UTC()
+		server, hub = newTestHub(t, &notificationhubstest.Options{Now: func() time.Time { return now }})
+		ctx         = context.Background()
+	)
+	server.AddRegistration(notificationhubstest.Registration{Target: notificationhubs.AppleTemplatePlatform, Handle: "apple1", Template: `{"aps":{"alert":"$(message)"}}`})
+
+	notification, _ := notificationhubs.NewNotification(notificationhubs.Template, []byte(`{"message":"hello"}`))
+	_, telemetry, err := hub.Schedule(ctx, notification, nil, time.Now().Add(15*time.Minute))
+	if err != nil {
+		t.Fatalf(errfmt, "schedule error", nil, err)
+	}
+
+	details, _, err := hub.NotificationDetails(ctx, telemetry.NotificationMessageID)
+	if err != nil || details.State != notificationhubs.Scheduled {
+		t.Fatalf(errfmt, "state", notificationhubs.Scheduled, details)
+	}
+
+	now = now.Add(30 * time.Minute)
+	details, err = hub.WaitForNotification(ctx, telemetry.NotificationMessageID, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "wait error", nil, err)
+	}
+	if details.State != notificationhubs.Completed || details.ApnsOutcomeCounts.TotalByOutcome(notificationhubs.Success) != 1 {
+		t.Errorf(errfmt, "telemetry", "1 APNS success", details)
+	}
+}
+
+func Test_DeadHandlesArePruned(t *testing.T) {
+	var (
+		server, hub = newTestHub(t, nil)
+		ctx         = context.Background()
+	)
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "dead", Platform: notificationhubs.APNSPlatform, PushChannel: "dead-token"})
+	server.AddInstallation(notificationhubs.Installation{InstallationID: "alive", Platform: notificationhubs.APNSPlatform, PushChannel: "alive-token"})
+	server.MarkHandle("dead-token", notificationhubs.WrongToken)
+
+	notification, _ := notificationhubs.NewNotification(notificationhubs.AppleFormat, []byte(`{"aps":{"alert":"hi"}}`))
+	_, telemetry, err := hub.Send(ctx, notification, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+
+	report, err := hub.PruneDeadTokens(ctx, telemetry.NotificationMessageID, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "prune error", nil, err)
+	}
+	if report.Count(notificationhubs.PruneDeleteInstallation) != 1 {
+		t.Errorf(errfmt, "deleted installations", 1, report.Actions)
+	}
+	if installations := server.Installations(); len(installations) != 1 || installations[0].InstallationID != "alive" {
+		t.Errorf(errfmt, "installations", "alive", installations)
+	}
+}
+
+func Test_SignatureAndFaults(t *testing.T) {
+	server, hub := newTestHub(t, nil)
+	hub.SasKeyValue = "wrong"
+
+	var hubErr *notificationhubs.NotificationHubError
+	if _, _, err := hub.Registrations(context.Background()); !errors.As(err, &hubErr) || hubErr.Code != notificationhubs.ErrorCodeUnauthorized {
+		t.Errorf(errfmt, "error", notificationhubs.ErrorCodeUnauthorized, err)
+	}
+
+	hub, _ = server.NewHub()
+	server.FailNext(http.StatusServiceUnavailable, 1)
+	if _, _, err := hub.Registrations(context.Background()); !errors.As(err, &hubErr) || !hubErr.IsRetryable() {
+		t.Errorf(errfmt, "error", notificationhubs.ErrorCodeServiceUnavailable, err)
+	}
+	if _, _, err := hub.Registrations(context.Background()); err != nil {
+		t.Errorf(errfmt, "error", nil, err)
+	}
+}
+
+func Test_NamespaceManager(t *testing.T) {
+	var (
+		server = notificationhubstest.NewServer(nil)
+		ctx    = context.Background()
+		hubErr *notificationhubs.NotificationHubError
+	)
+	t.Cleanup(server.Close)
+	manager, err := notificationhubs.NewNamespaceManager(server.ConnectionString())
+	if err != nil {
+		t.Fatalf(errfmt, "manager error", nil, err)
+	}
+
+	listen, err := notificationhubs.NewAuthorizationRule("listen", notificationhubs.AccessRightListen)
+	if err != nil {
+		t.Fatalf(errfmt, "rule error", nil, err)
+	}
+	created, err := manager.CreateHub(ctx, &notificationhubs.NotificationHubDescription{
+		Name:               "orders",
+		RegistrationTTL:    90 * 24 * time.Hour,
+		AuthorizationRules: []notificationhubs.AuthorizationRule{listen},
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "create error", nil, err)
+	}
+	if created.Name != "orders" || created.RegistrationTTL != 90*24*time.Hour || len(created.AuthorizationRules) != 1 || created.AuthorizationRules[0].CreatedTime == nil {
+		t.Errorf(errfmt, "created hub", "orders with a listen rule", created)
+	}
+	if _, err := manager.CreateHub(ctx, &notificationhubs.NotificationHubDescription{Name: "orders"}); !errors.As(err, &hubErr) || hubErr.Code != notificationhubs.ErrorCodeConflict {
+		t.Errorf(errfmt, "create error", notificationhubs.ErrorCodeConflict, err)
+	}
+
+	hubs, err := manager.Hubs(ctx)
+	if err != nil || len(hubs) != 2 || hubs[0].Name != "orders" || hubs[1].Name != server.HubPath() {
+		t.Errorf(errfmt, "hubs", []string{"orders", server.HubPath()}, hubs)
+	}
+
+	created.RegistrationTTL = 30 * time.Minute
+	updated, err := manager.UpdateHub(ctx, created)
+	if err != nil || updated.RegistrationTTL != 30*time.Minute {
+		t.Errorf(errfmt, "updated hub", 30*time.Minute, updated)
+	}
+
+	send, _ := notificationhubs.NewAuthorizationRule("send", notificationhubs.AccessRightSend, notificationhubs.AccessRightListen)
+	if _, err := manager.SetAuthorizationRule(ctx, "orders", send); err != nil {
+		t.Fatalf(errfmt, "set rule error", nil, err)
+	}
+	crossHub, err := notificationhubs.NewNotificationHub(manager.ConnectionString(send), server.HubPath())
+	if err != nil {
+		t.Fatalf(errfmt, "hub error", nil, err)
+	}
+	if _, _, err := crossHub.Register(ctx, notificationhubs.Registration{DeviceID: "ABCDEF", NotificationFormat: notificationhubs.AppleFormat}); !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf(errfmt, "registration signed with a rule of another hub", http.StatusUnauthorized, err)
+	}
+
+	listenOnly, _ := notificationhubs.NewAuthorizationRule("device", notificationhubs.AccessRightListen)
+	if _, err := manager.SetAuthorizationRule(ctx, server.HubPath(), listenOnly); err != nil {
+		t.Fatalf(errfmt, "set rule error", nil, err)
+	}
+	listenHub, _ := notificationhubs.NewNotificationHub(manager.ConnectionString(listenOnly), server.HubPath())
+	if _, _, err := listenHub.Register(ctx, notificationhubs.Registration{DeviceID: "ABCDEF", NotificationFormat: notificationhubs.AppleFormat}); err != nil {
+		t.Errorf(errfmt, "registration signed with a listen rule", nil, err)
+	}
+	notification, _ := notificationhubs.NewNotification(notificationhubs.AppleFormat, []byte(`{"aps":{"alert":"hello"}}`))
+	if _, _, err := listenHub.Send(ctx, notification, nil); !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf(errfmt, "send signed with a listen rule", http.StatusUnauthorized, err)
+	}
+	if _, _, err := listenHub.Registrations(ctx); !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf(errfmt, "listing signed with a listen rule", http.StatusUnauthorized, err)
+	}
+	listenManager, _ := notificationhubs.NewNamespaceManager(manager.ConnectionString(listenOnly))
+	if _, err := listenManager.Hub(ctx, server.HubPath()); !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf(errfmt, "hub read signed with a listen rule", http.StatusUnauthorized, err)
+	}
+	if err := manager.DeleteHub(ctx, server.HubPath()); err != nil {
+		t.Errorf(errfmt, "delete error", nil, err)
+	}
+
+	hub, _ := server.NewHub()
+	if _, _, err := hub.Registrations(ctx); !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusNotFound {
+		t.Errorf(errfmt, "registrations of a deleted hub", http.StatusNotFound, err)
+	}
+
+	after, err := manager.DeleteAuthorizationRule(ctx, "orders", "send")
+	if err != nil || len(after.AuthorizationRules) != 1 || after.AuthorizationRules[0].KeyName != "listen" {
+		t.Errorf(errfmt, "rules", []string{"listen"}, after)
+	}
+	if err := manager.DeleteHub(ctx, "orders"); err != nil {
+		t.Errorf(errfmt, "delete error", nil, err)
+	}
+	if _, err := manager.Hub(ctx, "orders"); !errors.As(err, &hubErr) || hubErr.StatusCode != http.StatusNotFound {
+		t.Errorf(errfmt, "get error", http.StatusNotFound, err)
+	}
+	if !reflect.DeepEqual(server.Hubs(), []string{}) {
+		t.Errorf(errfmt, "hubs", []string{}, server.Hubs())
+	}
+}
+
+func Test_PnsCredentials(t *testing.T) {
+	var (
+		server = notificationhubstest.NewServer(nil)
+		ctx    = context.Background()
+	)
+	t.Cleanup(server.Close)
+	manager, _ := notificationhubs.NewNamespaceManager(server.ConnectionString())
+
+	wns := &notificationhubs.WnsCredential{PackageSID: "ms-app://s-1-15-2-1", SecretKey: "secret"}
+	if _, err := manager.SetPnsCredentials(ctx, server.HubPath(), &notificationhubs.PnsCredentials{Wns: wns}); err != nil {
+		t.Fatalf(errfmt, "set credentials error", nil, err)
+	}
+	adm := &notificationhubs.AdmCredential{ClientID: "id", ClientSecret: "secret"}
+	if _, err := manager.SetPnsCredentials(ctx, server.HubPath(), &notificationhubs.PnsCredentials{Adm: adm}); err != nil {
+		t.Fatalf(errfmt, "set credentials error", nil, err)
+	}
+
+	credentials, err := manager.PnsCredentials(ctx, server.HubPath())
+	if err != nil {
+		t.Fatalf(errfmt, "credentials error", nil, err)
+	}
+	if !reflect.DeepEqual(credentials, &notificationhubs.PnsCredentials{Wns: wns, Adm: adm}) {
+		t.Errorf(errfmt, "credentials", notificationhubs.PnsCredentials{Wns: wns, Adm: adm}, credentials)
+	}
+	if _, err := manager.SetPnsCredentials(ctx, server.HubPath(), &notificationhubs.PnsCredentials{Baidu: &notificationhubs.BaiduCredential{}}); err == nil {
+		t.Errorf(errfmt, "error", "validation error", err)
+	}
+
+	hub, _ := server.NewHub()
+	if _, _, err := hub.Registrations(ctx); err != nil {
+		t.Errorf(errfmt, "registrations after a credential update", nil, err)
+	}
+}
+
+func Test_HubUpdatesAreConditional(t *testing.T) {
+	var (
+		server = notificationhubstest.NewServer(nil)
+		ctx    = context.Background()
+		hubErr *notificationhubs.NotificationHubError
+	)
+	t.Cleanup(server.Close)
+	manager, _ := notificationhubs.NewNamespaceManager(server.ConnectionString())
+	other, _ := notificationhubs.NewNamespaceManager(server.ConnectionString())
+
+	stale, err := manager.Hub(ctx, server.HubPath())
+	if err != nil || stale.ETag == "" {
+		t.Fatalf(errfmt, "hub with an ETag", nil, err)
+	}
+	first, _ := notificationhubs.NewAuthorizationRule("first", notificationhubs.AccessRightListen)
+	if _, err := other.SetAuthorizationRule(ctx, server.HubPath(), first); err != nil {
+		t.Fatalf(errfmt, "set rule error", nil, err)
+	}
+	stale.RegistrationTTL = time.Hour
+	if _, err := manager.UpdateHub(ctx, stale); !errors.As(err, &hubErr) || !hubErr.IsPreconditionFailed() {
+		t.Errorf(errfmt, "update of a stale description", notificationhubs.ErrorCodePreconditionFailed, err)
+	}
+
+	// a concurrent edit between the read and the write of a rule change is retried, not lost
+	second, _ := notificationhubs.NewAuthorizationRule("second", notificationhubs.AccessRightListen)
+	third, _ := notificationhubs.NewAuthorizationRule("third", notificationhubs.AccessRightListen)
+	interfered := false
+	manager.Use(func(next notificationhubs.RequestHandler) notificationhubs.RequestHandler {
+		return func(operation notificationhubs.Operation, req *http.Request) ([]byte, *http.Response, error) {
+			if operation == notificationhubs.OperationUpdateHub && !interfered {
+				interfered = true
+				if _, err := other.SetAuthorizationRule(ctx, server.HubPath(), third); err != nil {
+					t.Errorf(errfmt, "concurrent set rule error", nil, err)
+				}
+			}
+			return next(operation, req)
+		}
+	})
+	hub, err := manager.SetAuthorizationRule(ctx, server.HubPath(), second)
+	if err != nil {
+		t.Fatalf(errfmt, "set rule error", nil, err)
+	}
+	var names []string
+	for _, rule := range hub.AuthorizationRules {
+		names = append(names, rule.KeyName)
+	}
+	expected := []string{notificationhubstest.DefaultKeyName, "first", "third", "second"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf(errfmt, "rules", expected, names)
+	}
+}
@@ -0,0 +1,169 @@
+package notificationhubstest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tagExpression is a parsed tag expression,
+// see https://docs.microsoft.com/en-us/azure/notification-hubs/notification-hubs-tags-segment-push-message
+type tagExpression interface {
+	eval(tags map[string]bool) bool
+}
+
+type (
+	tagLiteral string
+	tagNot     struct{ operand tagExpression }
+	tagAnd     struct{ left, right tagExpression }
+	tagOr      struct{ left, right tagExpression }
+
+	// tagParser is a recursive descent parser for tag expressions
+	tagParser struct {
+		tokens []string
+		pos    int
+	}
+)
+
+func (t tagLiteral) eval(tags map[string]bool) bool { return tags[string(t)] }
+func (n tagNot) eval(tags map[string]bool) bool     { return !n.operand.eval(tags) }
+func (a tagAnd) eval(tags map[string]bool) bool     { return a.left.eval(tags) && a.right.eval(tags) }
+func (o tagOr) eval(tags map[string]bool) bool      { return o.left.eval(tags) || o.right.eval(tags) }
+
+// MatchTags evaluates a tag expression, such as "(follows_RedSox || follows_Cardinals) && location_Boston",
+// against a set of tags. An empty expression matches everything.
+func MatchTags(expression string, tags []string) (bool, error) {
+	expr, err := parseTagExpression(expression)
+	if err != nil {
+		return false, err
+	}
+	return matches(expr, tags), nil
+}
+
+// matches evaluates a parsed expression, nil matches everything
+func matches(expr tagExpression, tags []string) bool {
+	if expr == nil {
+		return true
+	}
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return expr.eval(set)
+}
+
+// parseTagExpression parses a tag expression, returning nil for an empty expression
+func parseTagExpression(expression string) (tagExpression, error) {
+	tokens, err := tokenizeTags(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &tagParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' in tag expression", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+// tokenizeTags splits a tag expression into operators, parentheses and tags
+func tokenizeTags(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(expression[i:], "||"), strings.HasPrefix(expression[i:], "&&"):
+			tokens = append(tokens, expression[i:i+2])
+			i += 2
+		case c == '!' || c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '|' || c == '&':
+			return nil, fmt.Errorf("invalid operator at position %d in tag expression", i)
+		default:
+			start := i
+			for i < len(expression) && !strings.ContainsRune(" \t\r\n()!|&", rune(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, expression[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) parseOr() (tagExpression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = tagOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagParser) parseAnd() (tagExpression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = tagAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagParser) parseUnary() (tagExpression, error) {
+	switch token := p.peek(); token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of tag expression")
+	case "!":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{operand}, nil
+	case "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ')' in tag expression")
+		}
+		p.pos++
+		return expr, nil
+	case ")", "||", "&&":
+		return nil, fmt.Errorf("unexpected '%s' in tag expression", token)
+	default:
+		p.pos++
+		return tagLiteral(token), nil
+	}
+}
@@ -0,0 +1,37 @@
+package notificationhubstest
+
+import "testing"
+
+func Test_MatchTags(t *testing.T) {
+	tags := []string{"follows_RedSox", "location_Boston", "$InstallationId:{abc}"}
+	tests := []struct {
+		expression  string
+		expected    bool
+		expectError bool
+	}{
+		{expression: "", expected: true},
+		{expression: "follows_RedSox", expected: true},
+		{expression: "follows_Cardinals", expected: false},
+		{expression: "(follows_RedSox || follows_Cardinals) && location_Boston", expected: true},
+		{expression: "follows_RedSox && !location_Boston", expected: false},
+		{expression: "!!follows_RedSox", expected: true},
+		{expression: "$InstallationId:{abc}", expected: true},
+		{expression: "a || b && location_Boston", expected: false},
+		{expression: "(follows_RedSox", expectError: true},
+		{expression: "follows_RedSox &&", expectError: true},
+		{expression: "follows_RedSox | location_Boston", expectError: true},
+		{expression: "follows_RedSox)", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := MatchTags(tt.expression, tags)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("MatchTags(%q) error = %v, expectError %v", tt.expression, err, tt.expectError)
+			}
+			if got != tt.expected {
+				t.Errorf("MatchTags(%q) = %v, want %v", tt.expression, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package notificationhubs
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// attemptKey is the context key holding the attempt number of a request
+type attemptKey struct{}
+
+// operationFor identifies the hub operation of a request from its method and URL
+func (h *NotificationHub) operationFor(method string, u *url.URL) Operation {
+	var (
+		resource   = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(u.Path, "/"), strings.Trim(h.HubURL.Path, "/")), "/")
+		segments   = strings.Split(resource, "/")
+		hasID      = len(segments) > 1 && segments[1] != ""
+		_, direct  = u.Query()[directParam]
+		collection = segments[0]
+	)
+
+	switch {
+	case collection == "messages" && method == http.MethodPost && hasID:
+		return OperationSendDirectBatch
+	case collection == "messages" && method == http.MethodPost && direct:
+		return OperationSendDirect
+	case collection == "messages" && method == http.MethodPost:
+		return OperationSend
+	case collection == "messages" && method == http.MethodGet:
+		return OperationNotificationDetails
+	case collection == "schedulednotifications" && method == http.MethodPost:
+		return OperationSchedule
+	case collection == "schedulednotifications" && method == http.MethodDelete:
+		return OperationCancelScheduled
+	case collection == "registrations" && method == http.MethodGet && hasID:
+		return OperationRegistration
+	case collection == "registrations" && method == http.MethodGet, collection == "tags" && method == http.MethodGet:
+		return OperationRegistrations
+	case collection == "registrations" && (method == http.MethodPost || method == http.MethodPut):
+		return OperationRegister
+	case collection == "registrations" && method == http.MethodDelete:
+		return OperationUnregister
+	case collection == "installations" && method == http.MethodGet:
+		return OperationInstallation
+	case collection == "installations" && method == http.MethodPut:
+		return OperationInstall
+	case collection == "installations" && method == http.MethodPatch:
+		return OperationUpdateInstallation
+	case collection == "installations" && method == http.MethodDelete:
+		return OperationUninstall
+	case collection == "jobs" && method == http.MethodPost:
+		return OperationSubmitJob
+	case collection == "jobs" && method == http.MethodGet && hasID:
+		return OperationJob
+	case collection == "jobs" && method == http.MethodGet:
+		return OperationJobs
+	}
+	return OperationUnknown
+}
+
+// Class returns the rate limiting class of the operation, empty for unknown operations
+func (o Operation) Class() OperationClass {
+	switch o {
+	case OperationSend, OperationSendDirect, OperationSendDirectBatch, OperationSchedule, OperationCancelScheduled:
+		return OperationClassSend
+	case OperationNotificationDetails:
+		return OperationClassTelemetry
+	case OperationUnknown:
+		return ""
+	}
+	return OperationClassRegistration
+}
+
+// withAttempt records the attempt number of the requests made with ctx, starting at 1
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// RequestAttempt returns the attempt number of the request made with ctx, starting at 1.
+// Middlewares can read it from the request context.
+func RequestAttempt(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
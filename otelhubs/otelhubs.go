@@ -0,0 +1,221 @@
+// Package otelhubs instruments notification hub requests with OpenTelemetry.
+//
+// Middleware produces a span and metrics for every request of a hub it is added to with NotificationHub.Use.
+// Spans are children of the span in the request context and carry the operation, hub path, notification format,
+// tag expression length, status code and request ID. The metrics are the request count, the request latency,
+// the errors by ErrorCode and the notifications sent by format. Instrument adds the middleware to a hub
+// and also reports the statistics of its rate limits.
+package otelhubs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+)
+
+// instrumentationName identifies the tracer and meter of the package
+const instrumentationName = "github.com/koreset/azure-notifications-sdk-go/otelhubs"
+
+// unknownErrorCode is the error code of the request errors that aren't notification hub errors, in the error metrics
+const unknownErrorCode notificationhubs.ErrorCode = "UNKNOWN"
+
+type (
+	// Options configures the instrumentation.
+	// Nil providers fall back to the global OpenTelemetry providers.
+	Options struct {
+		TracerProvider trace.TracerProvider
+		MeterProvider  metric.MeterProvider
+	}
+
+	// instruments holds the tracer and metric instruments of the middleware
+	instruments struct {
+		meter         metric.Meter
+		tracer        trace.Tracer
+		requests      metric.Int64Counter
+		latency       metric.Float64Histogram
+		errors        metric.Int64Counter
+		notifications metric.Int64Counter
+	}
+)
+
+// Middleware returns a middleware producing an OpenTelemetry span and metrics for every hub request.
+// Added with NotificationHub.Use, it sees every retry attempt and the time spent waiting for rate limits.
+func Middleware(opts *Options) (notificationhubs.Middleware, error) {
+	inst, err := newInstruments(opts)
+	if err != nil {
+		return nil, fmt.Errorf("otelhubs.Middleware: %w", err)
+	}
+	return inst.middleware, nil
+}
+
+// Instrument adds the middleware to hub and reports the statistics of its rate limits,
+// see NotificationHub.RateLimitStats, as observable metrics by operation class
+func Instrument(hub *notificationhubs.NotificationHub, opts *Options) error {
+	inst, err := newInstruments(opts)
+	if err != nil {
+		return fmt.Errorf("otelhubs.Instrument: %w", err)
+	}
+	if err = inst.observeRateLimits(hub); err != nil {
+		return fmt.Errorf("otelhubs.Instrument: %w", err)
+	}
+	hub.Use(inst.middleware)
+	return nil
+}
+
+// newInstruments creates the tracer and the request metric instruments
+func newInstruments(opts *Options) (*instruments, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.TracerProvider == nil {
+		o.TracerProvider = otel.GetTracerProvider()
+	}
+	if o.MeterProvider == nil {
+		o.MeterProvider = otel.GetMeterProvider()
+	}
+
+	var (
+		meter = o.MeterProvider.Meter(instrumentationName)
+		inst  = &instruments{meter: meter, tracer: o.TracerProvider.Tracer(instrumentationName)}
+		err   error
+	)
+	if inst.requests, err = meter.Int64Counter("notificationhubs.requests",
+		metric.WithDescription("Notification hub requests"), metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+	if inst.latency, err = meter.Float64Histogram("notificationhubs.request.duration",
+		metric.WithDescription("Notification hub request latency"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if inst.errors, err = meter.Int64Counter("notificationhubs.errors",
+		metric.WithDescription("Failed notification hub requests, by error code"), metric.WithUnit("{error}")); err != nil {
+		return nil, err
+	}
+	if inst.notifications, err = meter.Int64Counter("notificationhubs.notifications.sent",
+		metric.WithDescription("Notifications accepted by the hub, by format"), metric.WithUnit("{notification}")); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+// observeRateLimits reports the rate limit statistics of hub when the metrics are collected
+func (inst *instruments) observeRateLimits(hub *notificationhubs.NotificationHub) error {
+	waits, err := inst.meter.Int64ObservableCounter("notificationhubs.ratelimit.waits",
+		metric.WithDescription("Requests that waited for a rate limit token"), metric.WithUnit("{request}"))
+	if err != nil {
+		return err
+	}
+	waitTime, err := inst.meter.Float64ObservableCounter("notificationhubs.ratelimit.wait",
+		metric.WithDescription("Time requests waited for a rate limit token"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	throttled, err := inst.meter.Int64ObservableCounter("notificationhubs.ratelimit.throttled",
+		metric.WithDescription("Requests the hub rejected as rate limited"), metric.WithUnit("{request}"))
+	if err != nil {
+		return err
+	}
+	rate, err := inst.meter.Float64ObservableGauge("notificationhubs.ratelimit.rate",
+		metric.WithDescription("Current rate limit, lower than the configured one after throttling"), metric.WithUnit("{request}/s"))
+	if err != nil {
+		return err
+	}
+
+	_, err = inst.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for class, stats := range hub.RateLimitStats() {
+			set := metric.WithAttributes(attribute.String("notificationhubs.operation_class", string(class)))
+			o.ObserveInt64(waits, stats.Waits, set)
+			o.ObserveFloat64(waitTime, stats.TotalWait.Seconds(), set)
+			o.ObserveInt64(throttled, stats.Throttled, set)
+			o.ObserveFloat64(rate, stats.Rate, set)
+		}
+		return nil
+	}, waits, waitTime, throttled, rate)
+	return err
+}
+
+// middleware records the span and the metrics of a request
+func (inst *instruments) middleware(next notificationhubs.RequestHandler) notificationhubs.RequestHandler {
+	return func(operation notificationhubs.Operation, req *http.Request) ([]byte, *http.Response, error) {
+		ctx, span := inst.startSpan(req.Context(), operation, req)
+		start := time.Now()
+		raw, resp, err := next(operation, req.WithContext(ctx))
+		inst.recordRequest(ctx, span, operation, req, resp, err, time.Since(start))
+		return raw, resp, err
+	}
+}
+
+// startSpan starts the span of a request
+func (inst *instruments) startSpan(ctx context.Context, operation notificationhubs.Operation, req *http.Request) (context.Context, trace.Span) {
+	hubPath, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	attrs := []attribute.KeyValue{
+		attribute.String("notificationhubs.operation", string(operation)),
+		attribute.String("notificationhubs.hub_path", hubPath),
+		attribute.String("http.request.method", req.Method),
+		attribute.Int("notificationhubs.attempt", notificationhubs.RequestAttempt(ctx)),
+	}
+	if format := req.Header.Get("ServiceBusNotification-Format"); format != "" {
+		attrs = append(attrs, attribute.String("notificationhubs.format", format))
+	}
+	if tags := req.Header.Values("ServiceBusNotification-Tags"); len(tags) > 0 {
+		attrs = append(attrs, attribute.Int("notificationhubs.tag_expression.length", len(tags[0])))
+	}
+	return inst.tracer.Start(ctx, "notificationhubs."+string(operation),
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// recordRequest ends the span of a request and records its metrics
+func (inst *instruments) recordRequest(ctx context.Context, span trace.Span, operation notificationhubs.Operation, req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	attrs := []attribute.KeyValue{attribute.String("notificationhubs.operation", string(operation))}
+	if resp != nil {
+		attrs = append(attrs, attribute.Int("http.response.status_code", resp.StatusCode))
+		if requestID := resp.Header.Get("x-ms-request-id"); requestID != "" {
+			span.SetAttributes(attribute.String("notificationhubs.request_id", requestID))
+		}
+	}
+	span.SetAttributes(attrs[1:]...)
+
+	set := metric.WithAttributes(attrs...)
+	inst.requests.Add(ctx, 1, set)
+	inst.latency.Record(ctx, latency.Seconds(), set)
+
+	if err != nil {
+		code := errorCodeOf(err)
+		inst.errors.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("notificationhubs.operation", string(operation)),
+			attribute.String("notificationhubs.error_code", string(code)),
+		))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, string(code))
+	} else if format := req.Header.Get("ServiceBusNotification-Format"); format != "" {
+		inst.notifications.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("notificationhubs.operation", string(operation)),
+			attribute.String("notificationhubs.format", format),
+		))
+	}
+	span.End()
+}
+
+// errorCodeOf classifies a request error for the error metrics
+func errorCodeOf(err error) notificationhubs.ErrorCode {
+	var hubErr *notificationhubs.NotificationHubError
+	switch {
+	case errors.As(err, &hubErr):
+		return hubErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return notificationhubs.ErrorCodeTimeout
+	}
+	return unknownErrorCode
+}
@@ -0,0 +1,202 @@
+package otelhubs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+	"github.com/koreset/azure-notifications-sdk-go/otelhubs"
+)
+
+const (
+	connectionString = "Endpoint=sb://testhub-ns.servicebus.windows.net/;SharedAccessKeyName=testAccessKeyName;SharedAccessKey=testAccessKey"
+	hubPath          = "testhub"
+	errfmt           = "Expected %s: \n%v\ngot:\n%v"
+)
+
+type mockHubHTTPClient struct {
+	execFunc func(*http.Request) ([]byte, *http.Response, error)
+}
+
+func (mc *mockHubHTTPClient) Exec(req *http.Request) ([]byte, *http.Response, error) {
+	return mc.execFunc(req)
+}
+
+func initTestItems(t *testing.T) (*notificationhubs.NotificationHub, *mockHubHTTPClient) {
+	t.Helper()
+	nhub, err := notificationhubs.NewNotificationHub(connectionString, hubPath)
+	if err != nil {
+		t.Fatalf(errfmt, "hub error", nil, err)
+	}
+	mockClient := &mockHubHTTPClient{}
+	nhub.SetHTTPClient(mockClient)
+	return nhub, mockClient
+}
+
+func Test_Middleware(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems(t)
+		spans            = tracetest.NewSpanRecorder()
+		tracerProvider   = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+		reader           = sdkmetric.NewManualReader()
+		meterProvider    = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		notification, _  = notificationhubs.NewNotification(notificationhubs.Template, []byte(`{"message":"hello"}`))
+		tags             = "sports && news"
+	)
+	middleware, err := otelhubs.Middleware(&otelhubs.Options{TracerProvider: tracerProvider, MeterProvider: meterProvider})
+	if err != nil {
+		t.Fatalf(errfmt, "Middleware error", nil, err)
+	}
+	nhub.Use(middleware)
+
+	parentCtx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{
+			StatusCode: http.StatusCreated,
+			Header:     http.Header{"X-Ms-Request-Id": []string{"request-1"}},
+		}, nil
+	}
+	if _, _, err := nhub.Send(parentCtx, notification, &tags); err != nil {
+		t.Fatalf(errfmt, "Send error", nil, err)
+	}
+	parent.End()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusTooManyRequests}, errors.New("Got unexpected response status code: 429")
+	}
+	_ = nhub.Uninstall(context.Background(), "installation-1")
+
+	ended := spans.Ended()
+	if len(ended) != 3 {
+		t.Fatalf(errfmt, "spans", 3, len(ended))
+	}
+	sent, failed := ended[0], ended[2]
+	if sent.Name() != "notificationhubs.Send" {
+		t.Errorf(errfmt, "span name", "notificationhubs.Send", sent.Name())
+	}
+	if sent.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf(errfmt, "span parent", parent.SpanContext().SpanID(), sent.Parent().SpanID())
+	}
+	wantAttrs := map[attribute.Key]attribute.Value{
+		"notificationhubs.operation":             attribute.StringValue(string(notificationhubs.OperationSend)),
+		"notificationhubs.hub_path":              attribute.StringValue(hubPath),
+		"notificationhubs.format":                attribute.StringValue(string(notificationhubs.Template)),
+		"notificationhubs.tag_expression.length": attribute.IntValue(len(tags)),
+		"http.response.status_code":              attribute.IntValue(http.StatusCreated),
+		"notificationhubs.request_id":            attribute.StringValue("request-1"),
+	}
+	gotAttrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range sent.Attributes() {
+		gotAttrs[kv.Key] = kv.Value
+	}
+	for key, want := range wantAttrs {
+		if gotAttrs[key] != want {
+			t.Errorf(errfmt, key, want.Emit(), gotAttrs[key].Emit())
+		}
+	}
+	if failed.Status().Code != codes.Error || failed.Status().Description != string(notificationhubs.ErrorCodeRateLimited) {
+		t.Errorf(errfmt, "span status", notificationhubs.ErrorCodeRateLimited, failed.Status())
+	}
+
+	sums, _ := collect(t, reader)
+	if total := sumOf(sums["notificationhubs.requests"]); total != 2 {
+		t.Errorf(errfmt, "requests", 2, total)
+	}
+	errorCounts := sums["notificationhubs.errors"]
+	if len(errorCounts.DataPoints) != 1 || errorCounts.DataPoints[0].Value != 1 {
+		t.Fatalf(errfmt, "errors", 1, errorCounts.DataPoints)
+	}
+	if code, _ := errorCounts.DataPoints[0].Attributes.Value("notificationhubs.error_code"); code.AsString() != string(notificationhubs.ErrorCodeRateLimited) {
+		t.Errorf(errfmt, "error code", notificationhubs.ErrorCodeRateLimited, code.AsString())
+	}
+	notifications := sums["notificationhubs.notifications.sent"]
+	if len(notifications.DataPoints) != 1 || notifications.DataPoints[0].Value != 1 {
+		t.Fatalf(errfmt, "notifications sent", 1, notifications.DataPoints)
+	}
+	if format, _ := notifications.DataPoints[0].Attributes.Value("notificationhubs.format"); format.AsString() != string(notificationhubs.Template) {
+		t.Errorf(errfmt, "notification format", notificationhubs.Template, format.AsString())
+	}
+}
+
+func Test_Instrument(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems(t)
+		spans            = tracetest.NewSpanRecorder()
+		reader           = sdkmetric.NewManualReader()
+		notification, _  = notificationhubs.NewNotification(notificationhubs.Template, []byte(`{"message":"hello"}`))
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+	_ = nhub.SetRateLimits(&notificationhubs.RateLimitOptions{Limits: map[notificationhubs.OperationClass]notificationhubs.RateLimit{
+		notificationhubs.OperationClassSend: {Rate: 1000, Burst: 1},
+	}})
+	err := otelhubs.Instrument(nhub, &otelhubs.Options{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "Instrument error", nil, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := nhub.Send(context.Background(), notification, nil); err != nil {
+			t.Fatalf(errfmt, "Send error", nil, err)
+		}
+	}
+	if len(spans.Ended()) != 3 {
+		t.Errorf(errfmt, "spans", 3, len(spans.Ended()))
+	}
+
+	sums, gauges := collect(t, reader)
+	waits := sums["notificationhubs.ratelimit.waits"]
+	if len(waits.DataPoints) != 1 || waits.DataPoints[0].Value != 2 {
+		t.Fatalf(errfmt, "rate limit waits", 2, waits.DataPoints)
+	}
+	if class, _ := waits.DataPoints[0].Attributes.Value("notificationhubs.operation_class"); class.AsString() != string(notificationhubs.OperationClassSend) {
+		t.Errorf(errfmt, "operation class", notificationhubs.OperationClassSend, class.AsString())
+	}
+	if rate := gauges["notificationhubs.ratelimit.rate"]; len(rate.DataPoints) != 1 || rate.DataPoints[0].Value != 1000 {
+		t.Errorf(errfmt, "rate", 1000, rate.DataPoints)
+	}
+}
+
+// collect reads the integer sums and the float gauges of the reader by name
+func collect(t *testing.T, reader sdkmetric.Reader) (map[string]metricdata.Sum[int64], map[string]metricdata.Gauge[float64]) {
+	t.Helper()
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatalf(errfmt, "Collect error", nil, err)
+	}
+	var (
+		sums   = make(map[string]metricdata.Sum[int64])
+		gauges = make(map[string]metricdata.Gauge[float64])
+	)
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				sums[m.Name] = data
+			case metricdata.Gauge[float64]:
+				gauges[m.Name] = data
+			}
+		}
+	}
+	return sums, gauges
+}
+
+func sumOf(sum metricdata.Sum[int64]) (total int64) {
+	for _, point := range sum.DataPoints {
+		total += point.Value
+	}
+	return
+}
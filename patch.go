@@ -0,0 +1,294 @@
+package notificationhubs
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type (
+	// changeRule describes an installation change path accepted by the service
+	changeRule struct {
+		pattern []string
+		ops     []InstallationChangeOp
+		apply   func(inst *Installation, params []string, c InstallationChange) error
+	}
+)
+
+// changeRules lists the installation changes the service accepts, "*" matches any path segment.
+// Replacing all templates or secondary tiles, and adding or removing whole secondary tiles, isn't supported.
+var changeRules = []changeRule{
+	{[]string{"pushChannel"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, _ []string, c InstallationChange) error {
+		return decodeChangeValue(c, &inst.PushChannel)
+	}},
+	{[]string{"userId"}, []InstallationChangeOp{InstallationChangeAdd, InstallationChangeReplace, InstallationChangeRemove}, func(inst *Installation, _ []string, c InstallationChange) error {
+		if c.Op != InstallationChangeAdd && inst.UserID == "" {
+			return fmt.Errorf("user ID doesn't exist")
+		}
+		if c.Op == InstallationChangeRemove {
+			inst.UserID = ""
+			return nil
+		}
+		if err := decodeChangeValue(c, &inst.UserID); err != nil {
+			return err
+		}
+		return checkTagValue("userId", inst.UserID)
+	}},
+	{[]string{"tags"}, []InstallationChangeOp{InstallationChangeAdd, InstallationChangeReplace}, func(inst *Installation, _ []string, c InstallationChange) error {
+		return applyTags(&inst.Tags, c)
+	}},
+	{[]string{"tags", "*"}, []InstallationChangeOp{InstallationChangeRemove}, func(inst *Installation, p []string, _ InstallationChange) error {
+		inst.Tags = removeTag(inst.Tags, p[0])
+		return nil
+	}},
+	{[]string{"templates", "*"}, []InstallationChangeOp{InstallationChangeAdd, InstallationChangeReplace, InstallationChangeRemove}, func(inst *Installation, p []string, c InstallationChange) error {
+		return applyTemplate(&inst.Templates, p[0], c)
+	}},
+	{[]string{"templates", "*", "body"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTemplate(inst.Templates, p[0], func(t *InstallationTemplate) error { return decodeChangeValue(c, &t.Body) })
+	}},
+	{[]string{"templates", "*", "headers"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTemplate(inst.Templates, p[0], func(t *InstallationTemplate) error { return decodeChangeValue(c, &t.Headers) })
+	}},
+	{[]string{"templates", "*", "tags"}, []InstallationChangeOp{InstallationChangeAdd, InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTemplate(inst.Templates, p[0], func(t *InstallationTemplate) error { return applyTags(&t.Tags, c) })
+	}},
+	{[]string{"templates", "*", "tags", "*"}, []InstallationChangeOp{InstallationChangeRemove}, func(inst *Installation, p []string, _ InstallationChange) error {
+		return updateTemplate(inst.Templates, p[0], func(t *InstallationTemplate) error { t.Tags = removeTag(t.Tags, p[1]); return nil })
+	}},
+	{[]string{"secondaryTiles", "*", "pushChannel"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error { return decodeChangeValue(c, &t.PushChannel) })
+	}},
+	{[]string{"secondaryTiles", "*", "tags"}, []InstallationChangeOp{InstallationChangeAdd, InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error { return applyTags(&t.Tags, c) })
+	}},
+	{[]string{"secondaryTiles", "*", "tags", "*"}, []InstallationChangeOp{InstallationChangeRemove}, func(inst *Installation, p []string, _ InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error { t.Tags = removeTag(t.Tags, p[1]); return nil })
+	}},
+	{[]string{"secondaryTiles", "*", "templates"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error { return decodeChangeValue(c, &t.Templates) })
+	}},
+	{[]string{"secondaryTiles", "*", "templates", "*"}, []InstallationChangeOp{InstallationChangeAdd, InstallationChangeRemove}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error { return applyTemplate(&t.Templates, p[1], c) })
+	}},
+	{[]string{"secondaryTiles", "*", "templates", "*", "body"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error {
+			return updateTemplate(t.Templates, p[1], func(t *InstallationTemplate) error { return decodeChangeValue(c, &t.Body) })
+		})
+	}},
+	{[]string{"secondaryTiles", "*", "templates", "*", "headers"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error {
+			return updateTemplate(t.Templates, p[1], func(t *InstallationTemplate) error { return decodeChangeValue(c, &t.Headers) })
+		})
+	}},
+	{[]string{"secondaryTiles", "*", "templates", "*", "tags"}, []InstallationChangeOp{InstallationChangeReplace}, func(inst *Installation, p []string, c InstallationChange) error {
+		return updateTile(inst.SecondaryTiles, p[0], func(t *InstallationSecondaryTile) error {
+			return updateTemplate(t.Templates, p[1], func(t *InstallationTemplate) error { return applyTags(&t.Tags, c) })
+		})
+	}},
+}
+
+// ValidateChanges checks that every change targets a path and operation the service accepts,
+// and that values have the expected type. All problems are returned as ValidationErrors in a MultiError.
+func ValidateChanges(changes ...InstallationChange) error {
+	errs := NewMultiError()
+	for i, c := range changes {
+		if _, _, err := c.rule(); err != nil {
+			errs.Add(NewValidationError(fmt.Sprintf("changes[%d]", i), err.Error(), c.Path))
+			continue
+		}
+		if err := c.checkValue(); err != nil {
+			errs.Add(NewValidationError(fmt.Sprintf("changes[%d].value", i), err.Error(), c.Value))
+		}
+	}
+	return errs.ToError()
+}
+
+// ApplyChanges applies changes to a copy of installation the way the service would,
+// making it possible to predict the result of Update. The installation itself isn't modified.
+func ApplyChanges(installation Installation, changes ...InstallationChange) (Installation, error) {
+	if err := ValidateChanges(changes...); err != nil {
+		return installation, err
+	}
+	result, err := copyInstallation(installation)
+	if err != nil {
+		return installation, err
+	}
+	result.ETag = installation.ETag
+	for i, c := range changes {
+		rule, params, _ := c.rule()
+		if err := rule.apply(&result, params, c); err != nil {
+			return installation, NewValidationError(fmt.Sprintf("changes[%d]", i), err.Error(), c.Path)
+		}
+	}
+	return result, nil
+}
+
+// rule finds the rule matching the change, returning the wildcard path segments
+func (c InstallationChange) rule() (*changeRule, []string, error) {
+	if !strings.HasPrefix(c.Path, "/") {
+		return nil, nil, fmt.Errorf("path must start with '/'")
+	}
+	segments := strings.Split(c.Path[1:], "/")
+	for i := range segments {
+		segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segments[i])
+	}
+
+	pathMatched := false
+	for i := range changeRules {
+		params, ok := matchPattern(changeRules[i].pattern, segments)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if slices.Contains(changeRules[i].ops, c.Op) {
+			return &changeRules[i], params, nil
+		}
+	}
+	if pathMatched {
+		return nil, nil, fmt.Errorf("operation '%s' isn't supported on this path", c.Op)
+	}
+	return nil, nil, fmt.Errorf("path isn't supported by the service")
+}
+
+// checkValue verifies the value has the type expected by the path
+func (c InstallationChange) checkValue() error {
+	if c.Op == InstallationChangeRemove {
+		if c.Value != nil {
+			return fmt.Errorf("remove operations don't take a value")
+		}
+		return nil
+	}
+	if c.Value == nil {
+		return fmt.Errorf("%s operations require a value", c.Op)
+	}
+
+	rule, params, _ := c.rule()
+	scratch := Installation{
+		UserID:         "user",
+		Templates:      map[string]InstallationTemplate{},
+		SecondaryTiles: map[string]InstallationSecondaryTile{},
+	}
+	if len(params) > 0 {
+		scratch.Templates[params[0]] = InstallationTemplate{}
+		scratch.SecondaryTiles[params[0]] = InstallationSecondaryTile{}
+		if len(params) > 1 {
+			scratch.SecondaryTiles[params[0]] = InstallationSecondaryTile{Templates: map[string]InstallationTemplate{params[1]: {}}}
+		}
+	}
+	return rule.apply(&scratch, params, c)
+}
+
+// matchPattern matches path segments against a rule pattern
+func matchPattern(pattern, segments []string) ([]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	var params []string
+	for i, p := range pattern {
+		switch {
+		case p == "*" && segments[i] != "":
+			params = append(params, segments[i])
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// decodeChangeValue converts the change value to the type of target.
+// Values encoded as JSON strings, as produced by older versions of the helpers, are accepted too.
+func decodeChangeValue(c InstallationChange, target interface{}) error {
+	if s, ok := c.Value.(string); ok {
+		if str, ok := target.(*string); ok {
+			*str = s
+			return nil
+		}
+		return json.Unmarshal([]byte(s), target)
+	}
+	raw, err := json.Marshal(c.Value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// applyTags adds a single tag or replaces all tags
+func applyTags(tags *[]string, c InstallationChange) error {
+	if c.Op == InstallationChangeReplace {
+		var replaced []string
+		if err := decodeChangeValue(c, &replaced); err != nil {
+			return err
+		}
+		*tags = replaced
+		return nil
+	}
+	var tag string
+	if err := decodeChangeValue(c, &tag); err != nil {
+		return err
+	}
+	if !slices.Contains(*tags, tag) {
+		*tags = append(*tags, tag)
+	}
+	return nil
+}
+
+// removeTag removes a tag from a list
+func removeTag(tags []string, tag string) []string {
+	return slices.DeleteFunc(tags, func(t string) bool { return t == tag })
+}
+
+// applyTemplate adds, replaces or removes a named template
+func applyTemplate(templates *map[string]InstallationTemplate, name string, c InstallationChange) error {
+	if c.Op == InstallationChangeRemove {
+		delete(*templates, name)
+		return nil
+	}
+	var template InstallationTemplate
+	if err := decodeChangeValue(c, &template); err != nil {
+		return err
+	}
+	if *templates == nil {
+		*templates = make(map[string]InstallationTemplate)
+	}
+	(*templates)[name] = template
+	return nil
+}
+
+// updateTemplate modifies an existing named template
+func updateTemplate(templates map[string]InstallationTemplate, name string, update func(*InstallationTemplate) error) error {
+	template, ok := templates[name]
+	if !ok {
+		return fmt.Errorf("template '%s' doesn't exist", name)
+	}
+	if err := update(&template); err != nil {
+		return err
+	}
+	templates[name] = template
+	return nil
+}
+
+// updateTile modifies an existing secondary tile
+func updateTile(tiles map[string]InstallationSecondaryTile, name string, update func(*InstallationSecondaryTile) error) error {
+	tile, ok := tiles[name]
+	if !ok {
+		return fmt.Errorf("secondary tile '%s' doesn't exist", name)
+	}
+	if err := update(&tile); err != nil {
+		return err
+	}
+	tiles[name] = tile
+	return nil
+}
+
+// copyInstallation deep copies an installation
+func copyInstallation(installation Installation) (Installation, error) {
+	var copied Installation
+	raw, err := json.Marshal(installation)
+	if err != nil {
+		return copied, err
+	}
+	err = json.Unmarshal(raw, &copied)
+	return copied, err
+}
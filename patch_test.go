@@ -0,0 +1,139 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_ValidateChanges(t *testing.T) {
+	valid := []InstallationChange{
+		SetPushChannel("pushChannel"),
+		AddUserID("alice@example.com"),
+		SetUserID("alice@example.com"),
+		RemoveUserID(),
+		SetTags("tag1", "tag2"),
+		AddTag("tag"),
+		RemoveTag("tag"),
+		AddTemplate("name", InstallationTemplate{Body: "body"}),
+		SetTemplateBody("name", "body"),
+		SetTemplateHeaders("name", map[string]string{"k": "v"}),
+		RemoveTemplateTag("name", "tag"),
+		SetSecondaryTileTemplates("tile", map[string]InstallationTemplate{}),
+		AddSecondaryTileTemplate("tile", "template", InstallationTemplate{}),
+		RemoveSecondaryTileTemplate("tile", "template"),
+	}
+	if err := ValidateChanges(valid...); err != nil {
+		t.Errorf(errfmt, "ValidateChanges error", nil, err)
+	}
+
+	tests := []struct {
+		name   string
+		change InstallationChange
+	}{
+		{"SetTemplates", SetTemplates(map[string]InstallationTemplate{})},
+		{"SetSecondaryTiles", SetSecondaryTiles(map[string]InstallationSecondaryTile{})},
+		{"AddSecondaryTile", AddSecondaryTile("tile", InstallationSecondaryTile{})},
+		{"RemoveSecondaryTile", RemoveSecondaryTile("tile")},
+		{"unknown path", InstallationChange{Op: InstallationChangeReplace, Path: "/platform", Value: "apns"}},
+		{"relative path", InstallationChange{Op: InstallationChangeReplace, Path: "pushChannel", Value: "channel"}},
+		{"missing value", InstallationChange{Op: InstallationChangeReplace, Path: "/pushChannel"}},
+		{"remove with value", InstallationChange{Op: InstallationChangeRemove, Path: "/tags/tag", Value: "tag"}},
+		{"wrong value type", InstallationChange{Op: InstallationChangeReplace, Path: "/tags", Value: 42}},
+		{"empty user ID", SetUserID("")},
+		{"user ID with illegal characters", SetUserID("alice) || (all")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateChanges(test.change)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf(errfmt, "ValidateChanges error", "*ValidationError", err)
+			}
+		})
+	}
+}
+
+func Test_ApplyChanges(t *testing.T) {
+	var (
+		installation = Installation{
+			InstallationID: "installationID",
+			Platform:       FCMV1Platform,
+			PushChannel:    "old",
+			Tags:           []string{"tag1", "tag2"},
+			Templates: map[string]InstallationTemplate{
+				"existing": {Body: "old", Tags: []string{"a"}},
+			},
+			SecondaryTiles: map[string]InstallationSecondaryTile{
+				"tile": {PushChannel: "tileChannel"},
+			},
+		}
+		expected = Installation{
+			InstallationID: "installationID",
+			Platform:       FCMV1Platform,
+			PushChannel:    "new",
+			Tags:           []string{"tag2", "tag3"},
+			Templates: map[string]InstallationTemplate{
+				"existing": {Body: "new", Headers: map[string]string{"k": "v"}, Tags: []string{"a", "b"}},
+				"added":    {Body: "added"},
+			},
+			SecondaryTiles: map[string]InstallationSecondaryTile{
+				"tile": {PushChannel: "tileChannel", Tags: []string{"x"}, Templates: map[string]InstallationTemplate{"t": {Body: "tile body"}}},
+			},
+		}
+	)
+
+	result, err := ApplyChanges(installation,
+		SetPushChannel("new"),
+		RemoveTag("tag1"),
+		AddTag("tag3"),
+		AddTag("tag3"),
+		SetTemplateBody("existing", "new"),
+		SetTemplateHeaders("existing", map[string]string{"k": "v"}),
+		AddTemplateTag("existing", "b"),
+		AddTemplate("added", InstallationTemplate{Body: "added"}),
+		SetSecondaryTileTags("tile", "x"),
+		AddSecondaryTileTemplate("tile", "t", InstallationTemplate{Body: "body"}),
+		SetSecondaryTileTemplateBody("tile", "t", "tile body"),
+	)
+	if err != nil {
+		t.Fatalf(errfmt, "ApplyChanges error", nil, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errfmt, "ApplyChanges result", expected, result)
+	}
+	if installation.PushChannel != "old" || installation.Templates["existing"].Body != "old" {
+		t.Errorf(errfmt, "original installation", "unchanged", installation)
+	}
+
+	// Values from older helpers were JSON strings
+	result, err = ApplyChanges(installation, InstallationChange{Op: InstallationChangeReplace, Path: "/tags", Value: `["json"]`})
+	if err != nil {
+		t.Fatalf(errfmt, "ApplyChanges error", nil, err)
+	}
+	if !reflect.DeepEqual(result.Tags, []string{"json"}) {
+		t.Errorf(errfmt, "ApplyChanges tags", []string{"json"}, result.Tags)
+	}
+
+	if _, err = ApplyChanges(installation, SetTemplateBody("missing", "body")); err == nil {
+		t.Errorf(errfmt, "ApplyChanges error", "missing template", nil)
+	}
+}
+
+func Test_UpdateRejectsUnsupportedChanges(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		t.Errorf(errfmt, "request", nil, req.URL)
+		return nil, nil, nil
+	}
+
+	err := nhub.Update(context.Background(), "installationID", SetTemplates(map[string]InstallationTemplate{}))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf(errfmt, "Update error", "*ValidationError", err)
+	}
+}
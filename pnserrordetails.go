@@ -0,0 +1,90 @@
+package notificationhubs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"unicode"
+)
+
+// PnsErrorDetails fetches the PNS error details blob referenced by the notification details
+// and iterates over its records. Iteration stops at the first error.
+// The details are only available with API version 2016-07 and up, after the notification completed.
+func (h *NotificationHub) PnsErrorDetails(ctx context.Context, details *NotificationDetails) iter.Seq2[*PnsErrorDetail, error] {
+	return func(yield func(*PnsErrorDetail, error) bool) {
+		if details == nil || details.PnsErrorDetailsURI == "" {
+			return
+		}
+
+		blob, err := h.blobReader.ReadBlob(ctx, details.PnsErrorDetailsURI)
+		if err != nil {
+			yield(nil, fmt.Errorf("notificationhubs.PnsErrorDetails: %w", err))
+			return
+		}
+		defer blob.Close()
+
+		for detail, err := range ParsePnsErrorDetails(blob) {
+			if !yield(detail, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ParsePnsErrorDetails iterates over the records of a PNS error details blob.
+// Both newline delimited JSON records and a JSON array of records are accepted.
+// Iteration stops at the first error.
+func ParsePnsErrorDetails(r io.Reader) iter.Seq2[*PnsErrorDetail, error] {
+	return func(yield func(*PnsErrorDetail, error) bool) {
+		var (
+			br      = bufio.NewReader(r)
+			isArray = false
+		)
+
+		for {
+			c, _, err := br.ReadRune()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !unicode.IsSpace(c) && c != '\uFEFF' {
+				isArray = c == '['
+				if err = br.UnreadRune(); err != nil {
+					yield(nil, err)
+					return
+				}
+				break
+			}
+		}
+
+		dec := json.NewDecoder(br)
+		if isArray {
+			if _, err := dec.Token(); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+
+		for !isArray || dec.More() {
+			var detail PnsErrorDetail
+			err := dec.Decode(&detail)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, fmt.Errorf("could not parse PNS error details: %w", err))
+				return
+			}
+			if !yield(&detail, nil) {
+				return
+			}
+		}
+	}
+}
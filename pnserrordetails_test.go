@@ -0,0 +1,135 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+	"github.com/koreset/azure-notifications-sdk-go/utils"
+)
+
+const pnsErrorDetailsURI = "https://testhubstorage.blob.core.windows.net/container/details.txt?sig=testsig"
+
+func Test_PnsErrorDetails(t *testing.T) {
+	var (
+		nhub, _ = initTestItems()
+		details = &NotificationDetails{ID: notificationID, PnsErrorDetailsURI: pnsErrorDetailsURI}
+		gotURI  string
+	)
+
+	nhub.SetBlobReader(utils.BlobReaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		gotURI = uri
+		return os.Open("./fixtures/pnsErrorDetails.txt")
+	}))
+
+	var got []PnsErrorDetail
+	for detail, err := range nhub.PnsErrorDetails(context.Background(), details) {
+		if err != nil {
+			t.Fatalf(errfmt, "error", nil, err)
+		}
+		got = append(got, *detail)
+	}
+
+	if gotURI != pnsErrorDetailsURI {
+		t.Errorf(errfmt, "blob URI", pnsErrorDetailsURI, gotURI)
+	}
+	expected := []PnsErrorDetail{
+		{RegistrationID: "1025983137635915219-3562718380525399392-4", PnsHandle: "apns_token_1", Outcome: WrongToken, ErrorDescription: "BadDeviceToken"},
+		{InstallationID: "fcmv1-installation-sample-id", PnsHandle: "fcmv1_token_sample_here", Outcome: ExpiredChannel, ErrorDescription: "UNREGISTERED"},
+		{RegistrationID: "2025983137635915219-3562718380525399392-4", PnsHandle: "wns_channel_1", Outcome: BadChannel, ErrorDescription: "The channel URI is not valid"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf(errfmt, "details", expected, got)
+	}
+}
+
+func Test_PnsErrorDetailsWithoutURI(t *testing.T) {
+	nhub, _ := initTestItems()
+	nhub.SetBlobReader(utils.BlobReaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		t.Errorf("Expected the blob not to be read")
+		return nil, nil
+	}))
+
+	for detail, err := range nhub.PnsErrorDetails(context.Background(), &NotificationDetails{}) {
+		t.Errorf(errfmt, "record", nil, []interface{}{detail, err})
+	}
+}
+
+func Test_PnsErrorDetailsReadError(t *testing.T) {
+	var (
+		nhub, _       = initTestItems()
+		expectedError = errors.New("blob not found")
+	)
+	nhub.SetBlobReader(utils.BlobReaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		return nil, expectedError
+	}))
+
+	count := 0
+	for _, err := range nhub.PnsErrorDetails(context.Background(), &NotificationDetails{PnsErrorDetailsURI: pnsErrorDetailsURI}) {
+		count++
+		if !errors.Is(err, expectedError) {
+			t.Errorf(errfmt, "error", expectedError, err)
+		}
+	}
+	if count != 1 {
+		t.Errorf(errfmt, "records", 1, count)
+	}
+}
+
+func Test_ParsePnsErrorDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []NotificationOutcomeName
+		expectError bool
+	}{
+		{
+			name:     "Empty blob",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:     "JSON array",
+			input:    ` [{"Outcome":"WrongToken"},{"Outcome":"BadChannel"}]`,
+			expected: []NotificationOutcomeName{WrongToken, BadChannel},
+		},
+		{
+			name:     "Byte order mark",
+			input:    "\uFEFF{\"outcome\":\"ExpiredChannel\"}\n",
+			expected: []NotificationOutcomeName{ExpiredChannel},
+		},
+		{
+			name:        "Malformed record",
+			input:       "{\"Outcome\":\"WrongToken\"}\n{\"Outcome\":",
+			expected:    []NotificationOutcomeName{WrongToken},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got      []NotificationOutcomeName
+				gotError error
+			)
+			for detail, err := range ParsePnsErrorDetails(strings.NewReader(tt.input)) {
+				if err != nil {
+					gotError = err
+					continue
+				}
+				got = append(got, detail.Outcome)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParsePnsErrorDetails() = %v, want %v", got, tt.expected)
+			}
+			if (gotError != nil) != tt.expectError {
+				t.Errorf("ParsePnsErrorDetails() error = %v, expectError %v", gotError, tt.expectError)
+			}
+		})
+	}
+}
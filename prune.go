@@ -0,0 +1,212 @@
+package notificationhubs
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// defaultPruneOutcomes are the outcomes identifying dead tokens by default
+var defaultPruneOutcomes = []NotificationOutcomeName{WrongToken, ExpiredChannel, BadChannel}
+
+// PruneDeadTokens collects the handles the push services reported as dead for a completed notification
+// and deletes, or marks with opts.MarkTag, the matching registrations and installations.
+// Records with only a handle are resolved by listing the registrations of the hub once and matching their handles,
+// records whose handle no registration has anymore are skipped.
+// The report lists every action, failed actions are also returned as a MultiError.
+func (h *NotificationHub) PruneDeadTokens(ctx context.Context, notificationID string, opts *PruneOptions) (*PruneReport, error) {
+	var o PruneOptions
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.Outcomes) == 0 {
+		o.Outcomes = defaultPruneOutcomes
+	}
+
+	details, _, err := h.NotificationDetails(ctx, notificationID)
+	if err != nil {
+		return nil, fmt.Errorf("notificationhubs.PruneDeadTokens: %w", err)
+	}
+	if !details.State.IsTerminal() {
+		return nil, fmt.Errorf("notificationhubs.PruneDeadTokens: notification %s is %s, it must be completed", notificationID, details.State)
+	}
+
+	var (
+		report   = &PruneReport{NotificationID: notificationID, DryRun: o.DryRun}
+		errs     = NewMultiError()
+		seen     = make(map[string]bool)
+		byHandle map[string][]registrationFeedEntry
+		lastCall time.Time
+	)
+
+records:
+	for detail, err := range h.PnsErrorDetails(ctx, details) {
+		if err != nil {
+			errs.Add(err)
+			break
+		}
+		if !slices.Contains(o.Outcomes, detail.Outcome) {
+			continue
+		}
+
+		actions := []PruneAction{{
+			RegistrationID: detail.RegistrationID,
+			InstallationID: detail.InstallationID,
+			PnsHandle:      detail.PnsHandle,
+			Outcome:        detail.Outcome,
+		}}
+		if detail.RegistrationID == "" && detail.InstallationID == "" && detail.PnsHandle != "" {
+			if byHandle == nil {
+				if byHandle, err = h.registrationsByHandle(ctx); err != nil {
+					errs.Add(err)
+					break
+				}
+			}
+			var resolved []PruneAction
+			for _, entry := range byHandle[detail.PnsHandle] {
+				action := actions[0]
+				action.RegistrationID, action.InstallationID = entry.Description.RegistrationID, entry.installationID()
+				resolved = append(resolved, action)
+			}
+			if len(resolved) > 0 {
+				actions = resolved
+			}
+		}
+
+		for _, action := range actions {
+			action.Kind = pruneActionKind(action, o.MarkTag)
+
+			key := "r:" + action.RegistrationID
+			if action.InstallationID != "" {
+				key = "i:" + action.InstallationID
+			}
+			if action.Kind != PruneSkip && seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if !o.DryRun && action.Kind != PruneSkip {
+				if err := waitInterval(ctx, lastCall, o.Interval); err != nil {
+					errs.Add(err)
+					break records
+				}
+				lastCall = time.Now()
+				if action.Err = h.applyPruneAction(ctx, action, o.MarkTag); action.Err != nil {
+					errs.Add(action.Err)
+				}
+			}
+			report.Actions = append(report.Actions, action)
+		}
+	}
+
+	return report, errs.ToError()
+}
+
+// pruneActionKind chooses the action for a registration or installation with a dead token
+func pruneActionKind(action PruneAction, markTag string) PruneActionKind {
+	switch {
+	case action.InstallationID != "" && markTag != "":
+		return PruneMarkInstallation
+	case action.InstallationID != "":
+		return PruneDeleteInstallation
+	case action.RegistrationID != "" && markTag != "":
+		return PruneMarkRegistration
+	case action.RegistrationID != "":
+		return PruneDeleteRegistration
+	}
+	return PruneSkip
+}
+
+// registrationsByHandle lists all registrations of the hub, following continuation tokens, indexed by PNS handle
+func (h *NotificationHub) registrationsByHandle(ctx context.Context) (map[string][]registrationFeedEntry, error) {
+	var (
+		byHandle = make(map[string][]registrationFeedEntry)
+		token    string
+	)
+	for {
+		regURL := h.generateAPIURL("registrations")
+		if token != "" {
+			params := regURL.Query()
+			params.Set("ContinuationToken", token)
+			regURL.RawQuery = params.Encode()
+		}
+		raw, resp, err := h.exec(ctx, getMethod, regURL, Headers{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		var feed registrationFeed
+		if err = xml.Unmarshal(raw, &feed); err != nil {
+			return nil, err
+		}
+		for _, entry := range feed.Entries {
+			if handle := entry.Content.handle(); handle != "" {
+				byHandle[handle] = append(byHandle[handle], entry.Content)
+			}
+		}
+		if resp == nil || resp.Header.Get("X-MS-ContinuationToken") == "" {
+			return byHandle, nil
+		}
+		token = resp.Header.Get("X-MS-ContinuationToken")
+	}
+}
+
+// applyPruneAction executes a single pruner action against the hub
+func (h *NotificationHub) applyPruneAction(ctx context.Context, action PruneAction, markTag string) (err error) {
+	switch action.Kind {
+	case PruneDeleteInstallation:
+		err = h.Uninstall(ctx, action.InstallationID)
+	case PruneMarkInstallation:
+		err = h.Update(ctx, action.InstallationID, AddTag(markTag))
+	case PruneDeleteRegistration:
+		err = h.Unregister(ctx, RegisteredDevice{RegistrationID: action.RegistrationID, ETag: "*"})
+	case PruneMarkRegistration:
+		err = h.markRegistration(ctx, action.RegistrationID, markTag)
+	}
+	if isNotFound(err) { // already gone
+		return nil
+	}
+	return err
+}
+
+// markRegistration adds a tag to an existing registration
+func (h *NotificationHub) markRegistration(ctx context.Context, registrationID, tag string) (err error) {
+	_, _, err = h.ModifyRegistration(ctx, registrationID, func(device *RegisteredDevice) error {
+		if !slices.Contains(device.Tags, tag) {
+			device.Tags = append(device.Tags, tag)
+		}
+		return nil
+	})
+	return
+}
+
+// waitInterval blocks until interval has passed since last
+func waitInterval(ctx context.Context, last time.Time, interval time.Duration) error {
+	if interval <= 0 || last.IsZero() {
+		return ctx.Err()
+	}
+	wait := time.Until(last.Add(interval))
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Count returns the number of actions of a specific kind
+func (r *PruneReport) Count(kind PruneActionKind) int {
+	count := 0
+	for _, action := range r.Actions {
+		if action.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,210 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+	"github.com/koreset/azure-notifications-sdk-go/utils"
+)
+
+const completedDetailsWithErrors = `<NotificationDetails xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><NotificationId>%s</NotificationId><State>%s</State><PnsErrorDetailsUri>` + pnsErrorDetailsURI + `</PnsErrorDetailsUri></NotificationDetails>`
+
+// initPruneTestItems returns a hub reading the PNS error details fixture
+// and recording every request other than the notification details
+func initPruneTestItems(state NotificationState) (*NotificationHub, *mockHubHTTPClient, *[]string) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            = &[]string{}
+	)
+	nhub.SetBlobReader(utils.BlobReaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		return os.Open("./fixtures/pnsErrorDetails.txt")
+	}))
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if strings.Contains(req.URL.Path, "/messages/") {
+			return []byte(fmt.Sprintf(completedDetailsWithErrors, notificationID, state)), nil, nil
+		}
+		*calls = append(*calls, req.Method+" "+strings.TrimPrefix(req.URL.Path, "/testhub/"))
+		return nil, nil, nil
+	}
+	return nhub, mockClient, calls
+}
+
+func Test_PruneDeadTokens(t *testing.T) {
+	nhub, _, calls := initPruneTestItems(Completed)
+
+	report, err := nhub.PruneDeadTokens(context.Background(), notificationID, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+
+	expectedCalls := []string{
+		"DELETE registrations/1025983137635915219-3562718380525399392-4",
+		"DELETE installations/fcmv1-installation-sample-id",
+		"DELETE registrations/2025983137635915219-3562718380525399392-4",
+	}
+	if !reflect.DeepEqual(*calls, expectedCalls) {
+		t.Errorf(errfmt, "calls", expectedCalls, *calls)
+	}
+	if got := report.Count(PruneDeleteRegistration); got != 2 {
+		t.Errorf(errfmt, "deleted registrations", 2, got)
+	}
+	if got := report.Count(PruneDeleteInstallation); got != 1 {
+		t.Errorf(errfmt, "deleted installations", 1, got)
+	}
+}
+
+func Test_PruneDeadTokensDryRun(t *testing.T) {
+	nhub, _, calls := initPruneTestItems(Completed)
+
+	report, err := nhub.PruneDeadTokens(context.Background(), notificationID, &PruneOptions{
+		DryRun:   true,
+		Outcomes: []NotificationOutcomeName{WrongToken},
+	})
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf(errfmt, "calls", nil, *calls)
+	}
+	if !report.DryRun || len(report.Actions) != 1 || report.Actions[0].Kind != PruneDeleteRegistration {
+		t.Errorf(errfmt, "report", "a single registration deletion", report)
+	}
+}
+
+func Test_PruneDeadTokensMark(t *testing.T) {
+	var (
+		nhub, mockClient, _ = initPruneTestItems(Completed)
+		calls               []string
+		registrationBodies  []string
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if strings.Contains(req.URL.Path, "/messages/") {
+			return []byte(fmt.Sprintf(completedDetailsWithErrors, notificationID, Completed)), nil, nil
+		}
+		calls = append(calls, req.Method+" "+strings.TrimPrefix(req.URL.Path, "/testhub/"))
+		if strings.Contains(req.URL.Path, "/registrations/") {
+			if req.Method == putMethod {
+				body, _ := ioutil.ReadAll(req.Body)
+				registrationBodies = append(registrationBodies, string(body))
+			}
+			data, e := ioutil.ReadFile("./fixtures/appleRegistrationResult.xml")
+			return data, nil, e
+		}
+		return nil, nil, nil
+	}
+
+	_, err := nhub.PruneDeadTokens(context.Background(), notificationID, &PruneOptions{MarkTag: "dead"})
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+
+	expectedCalls := []string{
+		"GET registrations/1025983137635915219-3562718380525399392-4",
+		"PUT registrations/1025983137635915219-3562718380525399392-4",
+		"PATCH installations/fcmv1-installation-sample-id",
+		"GET registrations/2025983137635915219-3562718380525399392-4",
+		"PUT registrations/2025983137635915219-3562718380525399392-4",
+	}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf(errfmt, "calls", expectedCalls, calls)
+	}
+	for _, body := range registrationBodies {
+		if !strings.Contains(body, "<Tags>tag1,tag2,tag3,dead</Tags>") {
+			t.Errorf(errfmt, "registration tags", "tag1,tag2,tag3,dead", body)
+		}
+	}
+}
+
+func Test_PruneDeadTokensErrors(t *testing.T) {
+	var (
+		nhub, mockClient, _ = initPruneTestItems(Completed)
+		expectedError       = errors.New("test error")
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		switch {
+		case strings.Contains(req.URL.Path, "/messages/"):
+			return []byte(fmt.Sprintf(completedDetailsWithErrors, notificationID, Completed)), nil, nil
+		case strings.Contains(req.URL.Path, "/installations/"):
+			return nil, &http.Response{StatusCode: http.StatusNotFound}, errors.New("Got unexpected response status code: 404")
+		}
+		return nil, nil, expectedError
+	}
+
+	report, err := nhub.PruneDeadTokens(context.Background(), notificationID, nil)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf(errfmt, "error", "2 errors", err)
+	}
+	for _, action := range report.Actions {
+		if (action.Err != nil) != (action.Kind == PruneDeleteRegistration) {
+			t.Errorf(errfmt, "action error", action.Kind, action.Err)
+		}
+	}
+}
+
+func Test_PruneDeadTokensNotCompleted(t *testing.T) {
+	nhub, _, calls := initPruneTestItems(Processing)
+
+	if _, err := nhub.PruneDeadTokens(context.Background(), notificationID, nil); err == nil {
+		t.Errorf(errfmt, "error", "notification not completed", err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf(errfmt, "calls", nil, *calls)
+	}
+}
+
+func Test_PruneDeadTokensByHandle(t *testing.T) {
+	var (
+		nhub, mockClient, _ = initPruneTestItems(Completed)
+		calls               []string
+		errorDetails        = `{"PnsHandle":"apns_token_2","Outcome":"WrongToken"}` + "\n" +
+			`{"PnsHandle":"fcmv1_token_2","Outcome":"ExpiredChannel"}` + "\n" +
+			`{"PnsHandle":"unknown_token","Outcome":"WrongToken"}` + "\n"
+		registrationsFeed = `<feed xmlns="http://www.w3.org/2005/Atom">` +
+			`<entry><content type="application/xml"><AppleRegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>apple-1</RegistrationId><DeviceToken>apns_token_2</DeviceToken></AppleRegistrationDescription></content></entry>` +
+			`<entry><content type="application/xml"><AppleRegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>apple-2</RegistrationId><DeviceToken>apns_token_2</DeviceToken></AppleRegistrationDescription></content></entry>` +
+			`<entry><content type="application/xml"><FcmV1RegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>fcm-1</RegistrationId><Tags>$InstallationId:{installation-1}</Tags><FcmV1RegistrationId>fcmv1_token_2</FcmV1RegistrationId></FcmV1RegistrationDescription></content></entry>` +
+			`<entry><content type="application/xml"><AppleRegistrationDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><RegistrationId>apple-3</RegistrationId><DeviceToken>live_token</DeviceToken></AppleRegistrationDescription></content></entry>` +
+			`</feed>`
+	)
+	nhub.SetBlobReader(utils.BlobReaderFunc(func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(errorDetails)), nil
+	}))
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if strings.Contains(req.URL.Path, "/messages/") {
+			return []byte(fmt.Sprintf(completedDetailsWithErrors, notificationID, Completed)), nil, nil
+		}
+		calls = append(calls, req.Method+" "+strings.TrimPrefix(req.URL.Path, "/testhub/"))
+		if req.Method == getMethod {
+			return []byte(registrationsFeed), nil, nil
+		}
+		return nil, nil, nil
+	}
+
+	report, err := nhub.PruneDeadTokens(context.Background(), notificationID, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+
+	expectedCalls := []string{
+		"GET registrations",
+		"DELETE registrations/apple-1",
+		"DELETE registrations/apple-2",
+		"DELETE installations/installation-1",
+	}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf(errfmt, "calls", expectedCalls, calls)
+	}
+	if got := report.Count(PruneSkip); got != 1 || report.Actions[len(report.Actions)-1].PnsHandle != "unknown_token" {
+		t.Errorf(errfmt, "skipped", "unknown_token", report.Actions)
+	}
+}
@@ -0,0 +1,215 @@
+package notificationhubs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate limiting defaults
+const (
+	defaultSlowdownFactor   = 0.5
+	defaultMinRateFactor    = 0.1
+	defaultRecoveryInterval = 10 * time.Second
+)
+
+// ConservativeRateLimits returns an example preset for small hubs and shared test namespaces.
+// The presets aren't service quotas, tune the limits to the throttling observed, see RateLimitStats.
+func ConservativeRateLimits() *RateLimitOptions {
+	return &RateLimitOptions{Limits: map[OperationClass]RateLimit{
+		OperationClassSend:         {Rate: 10, Burst: 10},
+		OperationClassRegistration: {Rate: 5, Burst: 5},
+		OperationClassTelemetry:    {Rate: 1, Burst: 1},
+	}}
+}
+
+// ModerateRateLimits returns an example preset for hubs serving a steady production load
+func ModerateRateLimits() *RateLimitOptions {
+	return &RateLimitOptions{Limits: map[OperationClass]RateLimit{
+		OperationClassSend:         {Rate: 100, Burst: 100},
+		OperationClassRegistration: {Rate: 20, Burst: 20},
+		OperationClassTelemetry:    {Rate: 5, Burst: 5},
+	}}
+}
+
+// HighRateLimits returns an example preset for hubs sending large campaigns
+func HighRateLimits() *RateLimitOptions {
+	return &RateLimitOptions{Limits: map[OperationClass]RateLimit{
+		OperationClassSend:         {Rate: 500, Burst: 500},
+		OperationClassRegistration: {Rate: 50, Burst: 50},
+		OperationClassTelemetry:    {Rate: 10, Burst: 10},
+	}}
+}
+
+// tokenBucket is the rate limiter of an operation class
+type tokenBucket struct {
+	mu        sync.Mutex
+	limit     RateLimit
+	opts      RateLimitOptions
+	rate      float64
+	tokens    float64
+	last      time.Time
+	throttled time.Time
+	stats     RateLimitStats
+}
+
+// rateLimiter holds the token buckets of a hub
+type rateLimiter struct {
+	buckets map[OperationClass]*tokenBucket
+}
+
+// SetRateLimits limits the request rate of the hub, by operation class, nil disables rate limiting.
+// Requests wait for a token of their class before being sent, see ConservativeRateLimits, ModerateRateLimits
+// and HighRateLimits for example presets.
+func (h *NotificationHub) SetRateLimits(opts *RateLimitOptions) error {
+	if opts == nil {
+		h.rateLimiter = nil
+		return nil
+	}
+
+	o := *opts
+	if o.SlowdownFactor == 0 {
+		o.SlowdownFactor = defaultSlowdownFactor
+	}
+	if o.MinRateFactor == 0 {
+		o.MinRateFactor = defaultMinRateFactor
+	}
+	if o.RecoveryInterval == 0 {
+		o.RecoveryInterval = defaultRecoveryInterval
+	}
+	if o.SlowdownFactor <= 0 || o.SlowdownFactor > 1 {
+		return NewValidationError("SlowdownFactor", "must be in (0, 1]", o.SlowdownFactor)
+	}
+	if o.MinRateFactor <= 0 || o.MinRateFactor > 1 {
+		return NewValidationError("MinRateFactor", "must be in (0, 1]", o.MinRateFactor)
+	}
+
+	limiter := &rateLimiter{buckets: make(map[OperationClass]*tokenBucket, len(o.Limits))}
+	for class, limit := range o.Limits {
+		if limit.Rate <= 0 || limit.Burst < 1 {
+			return NewValidationError("Limits["+string(class)+"]", "rate and burst must be positive", limit)
+		}
+		limiter.buckets[class] = &tokenBucket{
+			limit:  limit,
+			opts:   o,
+			rate:   limit.Rate,
+			tokens: float64(limit.Burst),
+			stats:  RateLimitStats{Rate: limit.Rate},
+		}
+	}
+	h.rateLimiter = limiter
+	return nil
+}
+
+// RateLimitStats returns the statistics of the rate limited operation classes, nil without rate limiting
+func (h *NotificationHub) RateLimitStats() map[OperationClass]RateLimitStats {
+	if h.rateLimiter == nil {
+		return nil
+	}
+	stats := make(map[OperationClass]RateLimitStats, len(h.rateLimiter.buckets))
+	for class, bucket := range h.rateLimiter.buckets {
+		bucket.mu.Lock()
+		stats[class] = bucket.stats
+		bucket.mu.Unlock()
+	}
+	return stats
+}
+
+// waitRateLimit blocks until the rate limit of the operation allows a request
+func (h *NotificationHub) waitRateLimit(ctx context.Context, operation Operation) error {
+	if h.rateLimiter == nil {
+		return nil
+	}
+	bucket, ok := h.rateLimiter.buckets[operation.Class()]
+	if !ok {
+		return nil
+	}
+
+	wait := bucket.reserve(time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		bucket.cancel(wait)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observeRateLimit adapts the rate of the operation class to the outcome of a request
+func (h *NotificationHub) observeRateLimit(operation Operation, err error) {
+	if h.rateLimiter == nil {
+		return
+	}
+	bucket, ok := h.rateLimiter.buckets[operation.Class()]
+	if !ok {
+		return
+	}
+	var hubErr *NotificationHubError
+	bucket.observe(time.Now(), errors.As(err, &hubErr) && hubErr.Code == ErrorCodeRateLimited)
+}
+
+// reserve takes a token, returning how long to wait before it is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.recover(now)
+	b.tokens--
+	b.stats.Requests++
+	if b.tokens >= 0 {
+		return 0
+	}
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.stats.Waits++
+	b.stats.TotalWait += wait
+	return wait
+}
+
+// cancel returns a token reserved with a wait and removes the reservation from the statistics
+func (b *tokenBucket) cancel(wait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.tokens+1, float64(b.limit.Burst))
+	b.stats.Requests--
+	b.stats.Waits--
+	b.stats.TotalWait -= wait
+}
+
+// observe slows the bucket down when the hub throttled a request
+func (b *tokenBucket) observe(now time.Time, throttled bool) {
+	if !throttled {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.rate = math.Max(b.rate*b.opts.SlowdownFactor, b.limit.Rate*b.opts.MinRateFactor)
+	b.throttled = now
+	b.stats.Throttled++
+	b.stats.Rate = b.rate
+}
+
+// recover doubles the rate, up to the configured one, after a recovery interval without throttling
+func (b *tokenBucket) recover(now time.Time) {
+	for b.rate < b.limit.Rate && now.Sub(b.throttled) >= b.opts.RecoveryInterval {
+		b.rate = math.Min(b.rate*2, b.limit.Rate)
+		b.throttled = b.throttled.Add(b.opts.RecoveryInterval)
+	}
+	b.stats.Rate = b.rate
+}
+
+// refill adds the tokens accumulated since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*b.rate, float64(b.limit.Burst))
+	}
+	b.last = now
+}
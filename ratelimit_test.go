@@ -0,0 +1,116 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_RateLimits(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	err := nhub.SetRateLimits(&RateLimitOptions{Limits: map[OperationClass]RateLimit{
+		OperationClassRegistration: {Rate: 50, Burst: 2},
+	}})
+	if err != nil {
+		t.Fatalf(errfmt, "SetRateLimits error", nil, err)
+	}
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusNoContent}, nil
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := nhub.Uninstall(context.Background(), "installation-1"); err != nil {
+			t.Fatalf(errfmt, "Uninstall error", nil, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf(errfmt, "elapsed", ">= 30ms", elapsed)
+	}
+
+	stats := nhub.RateLimitStats()[OperationClassRegistration]
+	if stats.Requests != 4 || stats.Waits != 2 || stats.TotalWait <= 0 || stats.Rate != 50 {
+		t.Errorf(errfmt, "stats", "4 requests, 2 waits at 50/s", stats)
+	}
+	if _, ok := nhub.RateLimitStats()[OperationClassSend]; ok {
+		t.Errorf(errfmt, "send stats", "none", nhub.RateLimitStats()[OperationClassSend])
+	}
+}
+
+func Test_RateLimitsSlowdown(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	_ = nhub.SetRateLimits(&RateLimitOptions{
+		Limits:           map[OperationClass]RateLimit{OperationClassRegistration: {Rate: 100, Burst: 10}},
+		SlowdownFactor:   0.5,
+		MinRateFactor:    0.2,
+		RecoveryInterval: time.Hour,
+	})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusTooManyRequests}, errors.New("Got unexpected response status code: 429")
+	}
+
+	wantRates := []float64{50, 25, 20, 20}
+	for _, want := range wantRates {
+		_ = nhub.Uninstall(context.Background(), "installation-1")
+		stats := nhub.RateLimitStats()[OperationClassRegistration]
+		if stats.Rate != want {
+			t.Errorf(errfmt, "rate", want, stats.Rate)
+		}
+	}
+	if throttled := nhub.RateLimitStats()[OperationClassRegistration].Throttled; throttled != 4 {
+		t.Errorf(errfmt, "throttled", 4, throttled)
+	}
+}
+
+func Test_RateLimitsCanceled(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            int
+	)
+	_ = nhub.SetRateLimits(&RateLimitOptions{Limits: map[OperationClass]RateLimit{
+		OperationClassRegistration: {Rate: 0.001, Burst: 1},
+	}})
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		return nil, &http.Response{StatusCode: http.StatusNoContent}, nil
+	}
+
+	_ = nhub.Uninstall(context.Background(), "installation-1")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := nhub.Uninstall(ctx, "installation-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(errfmt, "error", context.DeadlineExceeded, err)
+	}
+	if calls != 1 {
+		t.Errorf(errfmt, "calls", 1, calls)
+	}
+	if stats := nhub.RateLimitStats()[OperationClassRegistration]; stats.Requests != 1 || stats.Waits != 0 || stats.TotalWait != 0 {
+		t.Errorf(errfmt, "stats", "the canceled request undone", stats)
+	}
+}
+
+func Test_RateLimitPresets(t *testing.T) {
+	nhub, _ := initTestItems()
+	for _, preset := range []*RateLimitOptions{ConservativeRateLimits(), ModerateRateLimits(), HighRateLimits()} {
+		if err := nhub.SetRateLimits(preset); err != nil {
+			t.Errorf(errfmt, "SetRateLimits error", nil, err)
+		}
+		if classes := len(nhub.RateLimitStats()); classes != 3 {
+			t.Errorf(errfmt, "classes", 3, classes)
+		}
+	}
+
+	err := nhub.SetRateLimits(&RateLimitOptions{Limits: map[OperationClass]RateLimit{OperationClassSend: {Rate: 10}}})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf(errfmt, "error", "ValidationError", err)
+	}
+	_ = nhub.SetRateLimits(nil)
+	if stats := nhub.RateLimitStats(); stats != nil {
+		t.Errorf(errfmt, "stats", nil, stats)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"path"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 )
@@ -14,11 +17,11 @@ import (
 func newRegistration(deviceID string, expirationTime *time.Time, notificationFormat NotificationFormat,
 	registrationID string, tags string) *Registration {
 	return &Registration{
-		deviceID,
-		expirationTime,
-		notificationFormat,
-		registrationID,
-		tags,
+		DeviceID:           deviceID,
+		ExpirationTime:     expirationTime,
+		NotificationFormat: notificationFormat,
+		RegistrationID:     registrationID,
+		Tags:               tags,
 	}
 }
 
@@ -26,12 +29,12 @@ func newRegistration(deviceID string, expirationTime *time.Time, notificationFor
 func newTemplateRegistration(deviceID string, expirationTime *time.Time, registrationID string, tags string,
 	platform TargetPlatform, template string) *TemplateRegistration {
 	return &TemplateRegistration{
-		deviceID,
-		expirationTime,
-		registrationID,
-		tags,
-		platform,
-		template,
+		DeviceID:       deviceID,
+		ExpirationTime: expirationTime,
+		RegistrationID: registrationID,
+		Tags:           tags,
+		Platform:       platform,
+		Template:       template,
 	}
 }
 
@@ -83,11 +86,13 @@ func (r *RegistrationContent) normalize() {
 		r.FcmV1TemplateRegistrationDescription = nil
 	}
 	if r.RegisteredDevice != nil {
-		expirationTime, err := time.Parse("2006-01-02T15:04:05.000Z", *r.RegisteredDevice.ExpirationTimeString)
-		if err != nil { // The API just forwards the date string used by Apple, Google etc unfortunately. So format varies.
-			expirationTime, _ = time.Parse("2006-01-02T15:04:05.000", *r.RegisteredDevice.ExpirationTimeString)
+		if r.RegisteredDevice.ExpirationTimeString != nil {
+			expirationTime, err := time.Parse("2006-01-02T15:04:05.000Z", *r.RegisteredDevice.ExpirationTimeString)
+			if err != nil { // The API just forwards the date string used by Apple, Google etc unfortunately. So format varies.
+				expirationTime, _ = time.Parse("2006-01-02T15:04:05.000", *r.RegisteredDevice.ExpirationTimeString)
+			}
+			r.RegisteredDevice.ExpirationTime = &expirationTime
 		}
-		r.RegisteredDevice.ExpirationTime = &expirationTime
 		r.RegisteredDevice.ExpirationTimeString = nil
 		if r.RegisteredDevice.TagsString != nil {
 			r.RegisteredDevice.Tags = strings.Split(*r.RegisteredDevice.TagsString, ",")
@@ -125,7 +130,8 @@ func (h *NotificationHub) Registrations(ctx context.Context) (raw []byte, regist
 	return
 }
 
-// Register sends a device registration to the Azure hub
+// Register sends a device registration to the Azure hub.
+// Updates with an ETag fail with ErrorCodePreconditionFailed when the registration changed since it was read.
 func (h *NotificationHub) Register(ctx context.Context, r Registration) (raw []byte, registrationResult *RegistrationResult, err error) {
 	var (
 		regURL  = h.generateAPIURL("registrations")
@@ -149,6 +155,9 @@ func (h *NotificationHub) Register(ctx context.Context, r Registration) (raw []b
 	if r.RegistrationID != "" {
 		method = putMethod
 		regURL.Path = path.Join(regURL.Path, r.RegistrationID)
+		if r.ETag != "" {
+			headers["If-Match"] = r.ETag
+		}
 	}
 
 	raw, _, err = h.exec(ctx, method, regURL, headers, bytes.NewBufferString(payload))
@@ -164,7 +173,8 @@ func (h *NotificationHub) Register(ctx context.Context, r Registration) (raw []b
 	return
 }
 
-// RegisterWithTemplate sends a device registration with template to the Azure hub
+// RegisterWithTemplate sends a device registration with template to the Azure hub.
+// Updates with an ETag fail with ErrorCodePreconditionFailed when the registration changed since it was read.
 func (h *NotificationHub) RegisterWithTemplate(ctx context.Context, r TemplateRegistration) (raw []byte, registrationResult *RegistrationResult, err error) {
 	var (
 		regURL  = h.generateAPIURL("registrations")
@@ -189,6 +199,9 @@ func (h *NotificationHub) RegisterWithTemplate(ctx context.Context, r TemplateRe
 	if r.RegistrationID != "" {
 		method = putMethod
 		regURL.Path = path.Join(regURL.Path, r.RegistrationID)
+		if r.ETag != "" {
+			headers["If-Match"] = r.ETag
+		}
 	}
 
 	raw, _, err = h.exec(ctx, method, regURL, headers, bytes.NewBufferString(payload))
@@ -204,6 +217,80 @@ func (h *NotificationHub) RegisterWithTemplate(ctx context.Context, r TemplateRe
 	return
 }
 
+// ModifyRegistration reads a registration, lets modify change the device and writes it back, conditional on its ETag.
+// When the registration changed in the meantime the read-modify-write is retried, up to maxModifyAttempts times.
+// Nothing is written when modify leaves the device unchanged. Only Apple and FCM v1 registrations are supported.
+// modify can change DeviceID, Tags, ETag and, of template registrations, Template;
+// changes to any other field are rejected with an error, as they can't be written back.
+func (h *NotificationHub) ModifyRegistration(ctx context.Context, registrationID string, modify func(*RegisteredDevice) error) (raw []byte, registrationResult *RegistrationResult, err error) {
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		raw, registrationResult, err = h.Registration(ctx, registrationID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("notificationhubs.ModifyRegistration: %w", err)
+		}
+		content := registrationResult.RegistrationContent
+		if content == nil || content.RegisteredDevice == nil {
+			return nil, nil, fmt.Errorf("notificationhubs.ModifyRegistration: registration %s has an unsupported format", registrationID)
+		}
+
+		device := *content.RegisteredDevice
+		device.Tags = slices.Clone(device.Tags)
+		if err = modify(&device); err != nil {
+			return nil, nil, err
+		}
+		if reflect.DeepEqual(&device, content.RegisteredDevice) {
+			return raw, registrationResult, nil
+		}
+		if err = checkModifiedDevice(content, device); err != nil {
+			return nil, nil, fmt.Errorf("notificationhubs.ModifyRegistration: %w", err)
+		}
+
+		raw, registrationResult, err = h.writeRegistration(ctx, registrationID, content, device)
+		if !isPreconditionFailed(err) {
+			if err != nil {
+				err = fmt.Errorf("notificationhubs.ModifyRegistration: %w", err)
+			}
+			return
+		}
+	}
+	return nil, nil, fmt.Errorf("notificationhubs.ModifyRegistration: giving up after %d conflicts: %w", maxModifyAttempts, err)
+}
+
+// checkModifiedDevice verifies the device only differs from the registration in the fields writeRegistration writes back
+func checkModifiedDevice(content *RegistrationContent, device RegisteredDevice) error {
+	written := *content.RegisteredDevice
+	written.DeviceID, written.Tags, written.ETag = device.DeviceID, device.Tags, device.ETag
+	if content.Format == Template {
+		written.Template = device.Template
+	}
+	if !reflect.DeepEqual(written, device) {
+		return errors.New("only DeviceID, Tags, ETag and the Template of template registrations can be modified")
+	}
+	return nil
+}
+
+// writeRegistration updates a registration with the device, conditional on the device ETag
+func (h *NotificationHub) writeRegistration(ctx context.Context, registrationID string, content *RegistrationContent, device RegisteredDevice) ([]byte, *RegistrationResult, error) {
+	tags := strings.Join(device.Tags, ",")
+	if content.Format == Template {
+		return h.RegisterWithTemplate(ctx, TemplateRegistration{
+			DeviceID:       device.DeviceID,
+			RegistrationID: registrationID,
+			Tags:           tags,
+			Platform:       TargetPlatform(strings.TrimSuffix(string(content.Target), "template")),
+			Template:       device.Template,
+			ETag:           device.ETag,
+		})
+	}
+	return h.Register(ctx, Registration{
+		DeviceID:           device.DeviceID,
+		NotificationFormat: content.Format,
+		RegistrationID:     registrationID,
+		Tags:               tags,
+		ETag:               device.ETag,
+	})
+}
+
 // Unregister sends a device registration delete to the Azure hub
 func (h *NotificationHub) Unregister(ctx context.Context, registration RegisteredDevice) (err error) {
 	var (
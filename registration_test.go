@@ -361,3 +361,50 @@ func Test_RegisterWebClientError(t *testing.T) {
 		t.Errorf(errfmt, "error", "fail", nil)
 	}
 }
+
+func Test_RegistrationHubError(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	clientErr := errors.New("Got unexpected response status code: 404. response: <Error><Code>404</Code></Error>")
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{"X-Ms-Request-Id": []string{"request-1"}}}, clientErr
+	}
+
+	_, result, err := nhub.Registration(context.Background(), "registration-1")
+	if result != nil {
+		t.Errorf(errfmt, "result", nil, result)
+	}
+	var hubErr *NotificationHubError
+	if !errors.As(err, &hubErr) {
+		t.Fatalf(errfmt, "error", "NotificationHubError", err)
+	}
+	if hubErr.Code != ErrorCodeRegistrationNotFound || hubErr.StatusCode != http.StatusNotFound || hubErr.RequestID != "request-1" {
+		t.Errorf(errfmt, "hub error", "REGISTRATION_NOT_FOUND, 404, request-1", hubErr)
+	}
+	if !errors.Is(err, clientErr) {
+		t.Errorf(errfmt, "cause", clientErr, hubErr.Cause)
+	}
+	wantMsg := "notification hub error [" + string(ErrorCodeRegistrationNotFound) + "]: Resource not found - " + clientErr.Error()
+	if err.Error() != wantMsg {
+		t.Errorf(errfmt, "message", wantMsg, err.Error())
+	}
+}
+
+func Test_RegisterWithETag(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	var ifMatch []string
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		ifMatch = append(ifMatch, req.Header.Get("If-Match"))
+		data, e := ioutil.ReadFile("./fixtures/appleRegistrationResult.xml")
+		return data, nil, e
+	}
+
+	ctx := context.Background()
+	nhub.Register(ctx, Registration{DeviceID: "ABCDEF", NotificationFormat: AppleFormat, RegistrationID: "1", ETag: "4"})
+	nhub.RegisterWithTemplate(ctx, TemplateRegistration{DeviceID: "ABCDEF", Platform: ApplePlatform, RegistrationID: "1", ETag: "5", Template: "{}"})
+	nhub.Register(ctx, Registration{DeviceID: "ABCDEF", NotificationFormat: AppleFormat, RegistrationID: "1"})
+
+	if expected := []string{"4", "5", ""}; !reflect.DeepEqual(ifMatch, expected) {
+		t.Errorf(errfmt, "If-Match headers", expected, ifMatch)
+	}
+}
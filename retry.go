@@ -0,0 +1,86 @@
+package notificationhubs
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Retry defaults
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// SetRetry makes the hub retry requests failing with retryable errors, timeouts or network errors, nil disables retries.
+// POST requests, such as sends, aren't idempotent and are only retried when the hub throttled them
+// or was unavailable, as it rejects those requests before processing them.
+func (h *NotificationHub) SetRetry(opts *RetryOptions) {
+	if opts == nil {
+		h.retryOptions = nil
+		return
+	}
+	o := *opts
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultMaxAttempts
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = defaultInitialBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultMaxBackoff
+	}
+	h.retryOptions = &o
+}
+
+// retryMiddleware retries failed requests, recording the attempt number in the request context
+func (h *NotificationHub) retryMiddleware(next RequestHandler) RequestHandler {
+	opts := *h.retryOptions
+	return func(operation Operation, req *http.Request) (raw []byte, resp *http.Response, err error) {
+		for attempt := 1; ; attempt++ {
+			attemptReq := req.WithContext(withAttempt(req.Context(), attempt))
+			if attempt > 1 && req.GetBody != nil {
+				if attemptReq.Body, err = req.GetBody(); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			raw, resp, err = next(operation, attemptReq)
+			if attempt >= opts.MaxAttempts || !shouldRetry(req, err) {
+				return raw, resp, err
+			}
+			if waitErr := waitInterval(req.Context(), time.Now(), backoff(opts, attempt, err)); waitErr != nil {
+				return raw, resp, err
+			}
+		}
+	}
+}
+
+// shouldRetry returns true if a failed request can be sent again
+func shouldRetry(req *http.Request, err error) bool {
+	if !isFailure(err) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return false
+	}
+	if req.Method != http.MethodPost {
+		return true
+	}
+	var hubErr *NotificationHubError
+	return errors.As(err, &hubErr) && (hubErr.Code == ErrorCodeRateLimited || hubErr.Code == ErrorCodeServiceUnavailable)
+}
+
+// backoff returns the delay before the attempt following attempt, err being the error of attempt
+func backoff(opts RetryOptions, attempt int, err error) time.Duration {
+	delay := opts.InitialBackoff << (attempt - 1)
+	if delay <= 0 || delay > opts.MaxBackoff {
+		delay = opts.MaxBackoff
+	}
+	delay = delay/2 + rand.N(delay/2+1)
+
+	var hubErr *NotificationHubError
+	if errors.As(err, &hubErr) && hubErr.RetryAfter > 0 {
+		delay = max(delay, min(hubErr.RetryAfter, opts.MaxBackoff))
+	}
+	return delay
+}
@@ -20,27 +20,63 @@ import (
 // ex. "(follows_RedSox || follows_Cardinals) && location_Boston"
 // or nil if no tags should be used
 func (h *NotificationHub) Send(ctx context.Context, n *Notification, tags *string) (raw []byte, telemetry *NotificationTelemetry, err error) {
-	raw, telemetry, err = h.send(ctx, n, tags, nil)
+	raw, telemetry, err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+		return h.send(ctx, n, tags, nil)
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("notificationhubs.SendDirect: %s", err)
+		return nil, nil, fmt.Errorf("notificationhubs.Send: %w", err)
 	}
 	return
 }
 
 // SendDirect publishes notification to a specific device
 func (h *NotificationHub) SendDirect(ctx context.Context, n *Notification, deviceHandle string) (raw []byte, telemetry *NotificationTelemetry, err error) {
-	raw, telemetry, err = h.sendDirect(ctx, n, deviceHandle)
+	raw, telemetry, err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+		return h.sendDirect(ctx, n, deviceHandle)
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("notificationhubs.SendDirect: %s", err)
+		return nil, nil, fmt.Errorf("notificationhubs.SendDirect: %w", err)
 	}
 	return
 }
 
 // SendDirectBatch publishes notification to a collection of devices
 func (h *NotificationHub) SendDirectBatch(ctx context.Context, n *Notification, deviceHandles ...string) (raw []byte, telemetry *NotificationTelemetry, err error) {
-	raw, telemetry, err = h.sendDirectBatch(ctx, n, deviceHandles)
+	raw, telemetry, err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+		return h.sendDirectBatch(ctx, n, deviceHandles)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("notificationhubs.SendDirectBatch: %w", err)
+	}
+	return
+}
+
+// SendToInstallation publishes notification to the devices of a single installation
+func (h *NotificationHub) SendToInstallation(ctx context.Context, n *Notification, installationID string) (raw []byte, telemetry *NotificationTelemetry, err error) {
+	if err = checkTagValue("installationID", installationID); err != nil {
+		return nil, nil, fmt.Errorf("notificationhubs.SendToInstallation: %w", err)
+	}
+	tag := InstallationIDTag(installationID)
+	raw, telemetry, err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+		return h.send(ctx, n, &tag, nil)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("notificationhubs.SendToInstallation: %w", err)
+	}
+	return
+}
+
+// SendToUser publishes notification to all installations with the user ID
+func (h *NotificationHub) SendToUser(ctx context.Context, n *Notification, userID string) (raw []byte, telemetry *NotificationTelemetry, err error) {
+	if err = checkTagValue("userID", userID); err != nil {
+		return nil, nil, fmt.Errorf("notificationhubs.SendToUser: %w", err)
+	}
+	tag := UserIDTag(userID)
+	raw, telemetry, err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+		return h.send(ctx, n, &tag, nil)
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("notificationhubs.SendDirectBatch: %s", err)
+		return nil, nil, fmt.Errorf("notificationhubs.SendToUser: %w", err)
 	}
 	return
 }
@@ -49,9 +85,11 @@ func (h *NotificationHub) SendDirectBatch(ctx context.Context, n *Notification,
 // Format tags according to https://docs.microsoft.com/en-us/azure/notification-hubs/notification-hubs-tags-segment-push-message
 // or nil if no tags should be used
 func (h *NotificationHub) Schedule(ctx context.Context, n *Notification, tags *string, deliverTime time.Time) (raw []byte, telemetry *NotificationTelemetry, err error) {
-	raw, telemetry, err = h.send(ctx, n, tags, &deliverTime)
+	raw, telemetry, err = h.sendNotification(ctx, n, func() ([]byte, *NotificationTelemetry, error) {
+		return h.send(ctx, n, tags, &deliverTime)
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("notificationhubs.Schedule: %s", err)
+		return nil, nil, fmt.Errorf("notificationhubs.Schedule: %w", err)
 	}
 	return
 }
@@ -82,6 +120,11 @@ func (h *NotificationHub) send(ctx context.Context, n *Notification, tags *strin
 		}
 	}
 
+	// WNS requires the notification type, the hub doesn't infer it from the payload
+	if n.Format == WindowsFormat {
+		headers["X-WNS-Type"] = wnsType(n.Payload)
+	}
+
 	if deliverTime != nil {
 		if deliverTime.After(time.Now()) {
 			_url.Path = path.Join(_url.Path, "schedulednotifications")
@@ -162,6 +205,9 @@ func (h *NotificationHub) sendDirectBatch(ctx context.Context, n *Notification,
 	if _, err = part.Write(handles); err != nil {
 		return
 	}
+	if err = multi.Close(); err != nil {
+		return
+	}
 
 	var (
 		headers = Headers{
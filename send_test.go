@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -264,6 +268,46 @@ func Test_NotificationSendDirectBatchError(t *testing.T) {
 	}
 }
 
+func Test_NotificationSendDirectBatchBody(t *testing.T) {
+	nhub, notification, mockClient := initNotificationTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/form-data" {
+			t.Fatalf(errfmt, "content type", "multipart/form-data", req.Header.Get("Content-Type"))
+		}
+
+		var (
+			reader = multipart.NewReader(req.Body, params["boundary"])
+			parts  = make(map[string][]byte)
+		)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf(errfmt, "NextPart error", nil, err)
+			}
+			_, disposition, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+			parts[disposition["name"]], _ = ioutil.ReadAll(part)
+		}
+
+		if got := string(parts["notification"]); got != "test payload" {
+			t.Errorf(errfmt, "notification part", "test payload", got)
+		}
+		var handles []string
+		if err := json.Unmarshal(parts["devices"], &handles); err != nil || !reflect.DeepEqual(handles, []string{"foo", "bar"}) {
+			t.Errorf(errfmt, "devices part", []string{"foo", "bar"}, string(parts["devices"]))
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+
+	if _, _, err := nhub.SendDirectBatch(context.Background(), notification, "foo", "bar"); err != nil {
+		t.Errorf(errfmt, "SendDirectBatch error", nil, err)
+	}
+}
+
 func Test_NotificationHubSendIosBackgroundNotification(t *testing.T) {
 	var (
 		nhub, mockClient = initTestItems()
@@ -351,3 +395,76 @@ func Test_NotificationHubSendAppleAlertNotification(t *testing.T) {
 		t.Errorf(errfmt, "error", nil, err)
 	}
 }
+
+func Test_NotificationHubSendWnsType(t *testing.T) {
+	nhub, mockClient := initTestItems()
+	for payload, expected := range map[string]string{
+		`<toast><visual/></toast>`:   "wns/toast",
+		`<tile><visual/></tile>`:     "wns/tile",
+		`<badge value="1"/>`:         "wns/badge",
+		`{"raw":"application data"}`: "wns/raw",
+	} {
+		mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+			if got := req.Header.Get("X-WNS-Type"); got != expected {
+				t.Errorf(errfmt, "X-WNS-Type of "+payload, expected, got)
+			}
+			return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+		}
+		notification, _ := NewNotification(WindowsFormat, []byte(payload))
+		if _, _, err := nhub.Send(context.Background(), notification, nil); err != nil {
+			t.Fatalf(errfmt, "send error", nil, err)
+		}
+	}
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		if got := req.Header.Get("X-WNS-Type"); got != "" {
+			t.Errorf(errfmt, "X-WNS-Type of an apple notification", "", got)
+		}
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+	notification, _ := NewNotification(AppleFormat, []byte(`{"aps":{"alert":"hello"}}`))
+	if _, _, err := nhub.Send(context.Background(), notification, nil); err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+}
+
+func Test_NotificationHubSendToInstallationAndUser(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		tags             []string
+		notification, _  = NewNotification(Template, []byte(`{"message":"hello"}`))
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		tags = append(tags, req.Header.Get("ServiceBusNotification-Tags"))
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+
+	if _, _, err := nhub.SendToInstallation(context.Background(), notification, "0a1b-2c3d"); err != nil {
+		t.Fatalf(errfmt, "SendToInstallation error", nil, err)
+	}
+	if _, _, err := nhub.SendToUser(context.Background(), notification, "alice@example.com"); err != nil {
+		t.Fatalf(errfmt, "SendToUser error", nil, err)
+	}
+	expected := []string{"$InstallationId:{0a1b-2c3d}", "$UserId:{alice@example.com}"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf(errfmt, "tags", expected, tags)
+	}
+
+	var validationErr *ValidationError
+	for _, userID := range []string{"", "alice} || {bob", "alice smith"} {
+		if _, _, err := nhub.SendToUser(context.Background(), notification, userID); !errors.As(err, &validationErr) {
+			t.Errorf(errfmt, "SendToUser error for "+userID, "*ValidationError", err)
+		}
+	}
+	if _, _, err := nhub.SendToInstallation(context.Background(), notification, "a && b"); !errors.As(err, &validationErr) {
+		t.Errorf(errfmt, "SendToInstallation error", "*ValidationError", err)
+	}
+	for _, err := range nhub.InstallationsByUserID(context.Background(), "(alice)") {
+		if !errors.As(err, &validationErr) {
+			t.Errorf(errfmt, "InstallationsByUserID error", "*ValidationError", err)
+		}
+	}
+	if len(tags) != 2 {
+		t.Errorf(errfmt, "requests", 2, len(tags))
+	}
+}
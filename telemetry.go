@@ -4,13 +4,15 @@ import (
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"path"
 	"regexp"
+	"time"
 )
 
-// NotificationDetails reads one specific registration
+// NotificationDetails reads the telemetry of one specific notification
 func (h *NotificationHub) NotificationDetails(ctx context.Context, notificationID string) (details *NotificationDetails, raw []byte, err error) {
 	var (
 		_url = h.generateAPIURL(path.Join("messages", notificationID))
@@ -20,12 +22,166 @@ func (h *NotificationHub) NotificationDetails(ctx context.Context, notificationI
 	if err != nil {
 		return
 	}
-	if err = xml.Unmarshal(raw, &details); err != nil {
-		return
-	}
+	err = xml.Unmarshal(raw, &details)
 	return
 }
 
+// UnmarshalXML decodes notification details, parsing the timestamps of the hub
+func (d *NotificationDetails) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type details NotificationDetails // without the UnmarshalXML method
+	var node struct {
+		details
+		EnqueueTime *string `xml:"EnqueueTime"`
+		StartTime   *string `xml:"StartTime"`
+		EndTime     *string `xml:"EndTime"`
+	}
+	if err := dec.DecodeElement(&node, &start); err != nil {
+		return err
+	}
+	*d = NotificationDetails(node.details)
+
+	var err error
+	if d.EnqueueTime, err = parseHubTime(node.EnqueueTime); err != nil {
+		return fmt.Errorf("EnqueueTime: %w", err)
+	}
+	if d.StartTime, err = parseHubTime(node.StartTime); err != nil {
+		return fmt.Errorf("StartTime: %w", err)
+	}
+	if d.EndTime, err = parseHubTime(node.EndTime); err != nil {
+		return fmt.Errorf("EndTime: %w", err)
+	}
+	return nil
+}
+
+// parseHubTime parses a timestamp returned by the hub, nil when absent.
+// The service doesn't always include the time zone, in which case UTC is assumed.
+func parseHubTime(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, *value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("unrecognized timestamp '%s'", *value)
+}
+
+// PlatformOutcomeCounts returns the outcome counts of every platform
+// present in the details, keyed by the name used by the hub
+func (d *NotificationDetails) PlatformOutcomeCounts() map[string]*NotificationOutcomes {
+	counts := make(map[string]*NotificationOutcomes)
+	for name, outcomes := range map[string]*NotificationOutcomes{
+		"Apns":    d.ApnsOutcomeCounts,
+		"Wns":     d.WnsOutcomeCounts,
+		"Mpns":    d.MpnsOutcomeCounts,
+		"Adm":     d.AdmOutcomeCounts,
+		"Baidu":   d.BaiduOutcomeCounts,
+		"Browser": d.BrowserOutcomeCounts,
+		"FcmV1":   d.FcmV1OutcomeCounts,
+		"Xiaomi":  d.XiaomiOutcomeCounts,
+	} {
+		if outcomes != nil {
+			counts[name] = outcomes
+		}
+	}
+	return counts
+}
+
+// TotalOutcomes returns the outcomes of all platforms added together
+func (d *NotificationDetails) TotalOutcomes() *NotificationOutcomes {
+	var (
+		totals = make(map[NotificationOutcomeName]int)
+		names  []NotificationOutcomeName
+	)
+	for _, outcomes := range []*NotificationOutcomes{
+		d.ApnsOutcomeCounts,
+		d.WnsOutcomeCounts,
+		d.MpnsOutcomeCounts,
+		d.AdmOutcomeCounts,
+		d.BaiduOutcomeCounts,
+		d.BrowserOutcomeCounts,
+		d.FcmV1OutcomeCounts,
+		d.XiaomiOutcomeCounts,
+	} {
+		if outcomes == nil {
+			continue
+		}
+		for _, o := range outcomes.Outcomes {
+			if _, ok := totals[o.Name]; !ok {
+				names = append(names, o.Name)
+			}
+			totals[o.Name] += o.Count
+		}
+	}
+
+	result := &NotificationOutcomes{}
+	for _, name := range names {
+		result.Outcomes = append(result.Outcomes, NotificationOutcome{Name: name, Count: totals[name]})
+	}
+	return result
+}
+
+// TotalByOutcome returns the count for a specific outcome
+func (o *NotificationOutcomes) TotalByOutcome(name NotificationOutcomeName) int {
+	if o == nil {
+		return 0
+	}
+	total := 0
+	for _, outcome := range o.Outcomes {
+		if outcome.Name == name {
+			total += outcome.Count
+		}
+	}
+	return total
+}
+
+// Total returns the sum of all outcome counts
+func (o *NotificationOutcomes) Total() int {
+	if o == nil {
+		return 0
+	}
+	total := 0
+	for _, outcome := range o.Outcomes {
+		total += outcome.Count
+	}
+	return total
+}
+
+// Failures returns the sum of all outcome counts that are failures
+func (o *NotificationOutcomes) Failures() int {
+	if o == nil {
+		return 0
+	}
+	failures := 0
+	for _, outcome := range o.Outcomes {
+		if outcome.Name.IsFailure() {
+			failures += outcome.Count
+		}
+	}
+	return failures
+}
+
+// FailureRate returns the fraction of outcomes that are failures,
+// or 0 if there are no outcomes
+func (o *NotificationOutcomes) FailureRate() float64 {
+	total := o.Total()
+	if total == 0 {
+		return 0
+	}
+	return float64(o.Failures()) / float64(total)
+}
+
+// IsFailure identifies whether the outcome means the notification wasn't delivered to the push service.
+// Success, Skipped (duplicate registrations) and NoTargets are not considered failures.
+func (n NotificationOutcomeName) IsFailure() bool {
+	switch n {
+	case Success, Skipped, NoTargets:
+		return false
+	}
+	return true
+}
+
 // NewNotificationTelemetryFromLocationURL create Telemetry from Location URL
 func NewNotificationTelemetryFromLocationURL(url string) *NotificationTelemetry {
 	var re = regexp.MustCompile(`/messages/(?P<id>.*)\?api-version=`)
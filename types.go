@@ -1,6 +1,9 @@
 package notificationhubs
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
 	"time"
 )
 
@@ -15,6 +18,7 @@ type (
 		NotificationFormat NotificationFormat `json:"service,omitempty"`
 		RegistrationID     string             `json:"registrationID,omitempty"`
 		Tags               string             `json:"tags,omitempty"`
+		ETag               string             `json:"eTag,omitempty"` // when set, an update only succeeds if the registration is unchanged
 	}
 
 	// TemplateRegistration is a device registration to the hub supporting a template
@@ -25,6 +29,7 @@ type (
 		Tags           string         `json:"tags,omitempty"`
 		Platform       TargetPlatform `json:"platform,omitempty"`
 		Template       string         `json:"template,omitempty"`
+		ETag           string         `json:"eTag,omitempty"` // when set, an update only succeeds if the registration is unchanged
 	}
 
 	// Registrations is a list of RegistrationResults
@@ -79,10 +84,264 @@ type (
 		LastUpdate         *time.Time                           `json:"lastUpdate,omitempty"`
 		Platform           InstallationPlatform                 `json:"platform,omitempty"`
 		PushChannel        string                               `json:"pushChannel,omitempty"`
+		UserID             string                               `json:"userId,omitempty"` // the service tags the installation with $UserId:{UserID}
 		ExpiredPushChannel bool                                 `json:"expiredPushChannel,omitempty"`
 		Tags               []string                             `json:"tags,omitempty"`
 		Templates          map[string]InstallationTemplate      `json:"templates,omitempty"`
 		SecondaryTiles     map[string]InstallationSecondaryTile `json:"secondaryTiles,omitempty"`
+		ETag               string                               `json:"-"` // set by Installation, for the conditional variants
+	}
+
+	// MultiPlatformMessage is a single logical message, rendered natively for each platform by SendMultiPlatform
+	MultiPlatformMessage struct {
+		Title     string
+		Body      string
+		Badge     *int
+		Data      map[string]string    // custom data, delivered next to the notification
+		DeepLink  string               // added to the custom data as "deepLink", the launch argument on Windows
+		Platforms []NotificationFormat // platforms to render, Apple, FCM v1 and Windows when empty
+		Overrides map[NotificationFormat]PlatformOverride
+
+		// IdempotencyKey identifies the message, each platform notification uses the key suffixed with
+		// ":" and its format, so resending the message only retries the platforms that failed
+		IdempotencyKey string
+	}
+
+	// PlatformOverride customizes a MultiPlatformMessage for one platform.
+	// Non-empty fields replace those of the message, data is merged, Payload replaces the rendered payload.
+	PlatformOverride struct {
+		Title   string
+		Body    string
+		Badge   *int
+		Data    map[string]string
+		Payload []byte
+	}
+
+	// MultiPlatformResult is the outcome of SendMultiPlatform, by platform
+	MultiPlatformResult struct {
+		Results map[NotificationFormat]*PlatformSendResult
+	}
+
+	// PlatformSendResult is the outcome of a multi platform send for one platform
+	PlatformSendResult struct {
+		Format       NotificationFormat
+		Notification *Notification
+		Telemetry    *NotificationTelemetry
+		Err          error
+	}
+
+	// LocalizedMessage is a message catalog keyed by locale, sent by SendLocalized.
+	// Each locale has either a native multi platform message, or properties for the registered templates.
+	LocalizedMessage struct {
+		Messages   map[string]*MultiPlatformMessage
+		Properties map[string]map[string]string
+		LocaleTag  func(locale string) string // tag of the devices using a locale, "locale:{locale}" when nil
+
+		// IdempotencyKey identifies the template notifications, each locale uses the key suffixed with ":" and
+		// the locale, so resending the catalog only retries the locales that failed. Native messages use their own key.
+		IdempotencyKey string
+	}
+
+	// LocalizedResult is the outcome of SendLocalized, by locale
+	LocalizedResult struct {
+		Results map[string]*LocaleSendResult
+	}
+
+	// LocaleSendResult is the outcome of a localized send for one locale.
+	// Platforms is set for native messages, Telemetry for template properties.
+	LocaleSendResult struct {
+		Locale        string
+		TagExpression string
+		Platforms     *MultiPlatformResult
+		Telemetry     *NotificationTelemetry
+		Err           error
+	}
+
+	// LogOptions configures request logging.
+	// SuccessLevel applies to successful requests, FailureLevel to failed ones.
+	LogOptions struct {
+		SuccessLevel slog.Level
+		FailureLevel slog.Level
+	}
+
+	// RateLimit is a token bucket, refilled with Rate tokens per second and holding up to Burst tokens
+	RateLimit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// RateLimitOptions configures client-side rate limiting, one token bucket per operation class.
+	// Classes without a limit aren't limited. When the hub throttles a request the rate of its class is multiplied
+	// by SlowdownFactor, down to MinRateFactor times the configured rate, and recovers after RecoveryInterval
+	// without throttling.
+	RateLimitOptions struct {
+		Limits           map[OperationClass]RateLimit
+		SlowdownFactor   float64       // 0.5 when zero
+		MinRateFactor    float64       // 0.1 when zero
+		RecoveryInterval time.Duration // 10 seconds when zero
+	}
+
+	// RateLimitStats are the statistics of the token bucket of an operation class
+	RateLimitStats struct {
+		Rate      float64       // current rate, lower than the configured one after throttling
+		Requests  int64         // requests that went through the bucket
+		Waits     int64         // requests that had to wait for a token
+		TotalWait time.Duration // time spent waiting for tokens
+		Throttled int64         // requests the hub rejected as rate limited
+	}
+
+	// CircuitBreakerOptions configures the circuit breaker.
+	// The circuit opens after FailureThreshold consecutive retryable failures, requests then fail fast
+	// with ErrorCodeCircuitOpen. After OpenTimeout up to HalfOpenProbes requests probe the hub,
+	// the circuit closes when all of them succeed and opens again when one fails.
+	CircuitBreakerOptions struct {
+		FailureThreshold int                         // 5 when zero
+		OpenTimeout      time.Duration               // 30 seconds when zero
+		HalfOpenProbes   int                         // 1 when zero
+		OnStateChange    func(from, to CircuitState) // called outside of any lock
+	}
+
+	// RequestHandler sends the request of a hub operation, the request context is the caller's
+	RequestHandler func(operation Operation, req *http.Request) ([]byte, *http.Response, error)
+
+	// Middleware intercepts hub requests. It can change the request, answer without calling next,
+	// or call next several times, see NotificationHub.Use.
+	Middleware func(next RequestHandler) RequestHandler
+
+	// RetryOptions configures the retries of failed requests, with exponential backoff and jitter.
+	// A Retry-After response header extends the backoff, up to MaxBackoff.
+	RetryOptions struct {
+		MaxAttempts    int           // attempts including the first one, 3 when zero
+		InitialBackoff time.Duration // 200 milliseconds when zero
+		MaxBackoff     time.Duration // 10 seconds when zero
+	}
+
+	// QueuedNotification is a notification accepted by a Dispatcher and persisted until it is delivered or given up.
+	// It is sent to Tags, or directly to DeviceHandle when set.
+	QueuedNotification struct {
+		ID           string       `json:"id"`
+		Notification Notification `json:"notification"`
+		Tags         *string      `json:"tags,omitempty"`
+		DeviceHandle string       `json:"deviceHandle,omitempty"`
+		EnqueuedAt   time.Time    `json:"enqueuedAt"`
+		Attempts     int          `json:"attempts"`
+		NextAttempt  time.Time    `json:"nextAttempt,omitempty"`
+		LastError    string       `json:"lastError,omitempty"`
+	}
+
+	// DispatchStore persists the notifications of a Dispatcher, it must be safe for concurrent use
+	DispatchStore interface {
+		Save(n QueuedNotification) error // creates or replaces the notification with the same ID
+		Delete(id string) error          // deleting a missing notification isn't an error
+		Load() ([]QueuedNotification, error)
+	}
+
+	// DispatcherOptions configures a Dispatcher.
+	// Retry applies to failures with retryable errors, timeouts and network errors, across restarts.
+	// Like for request retries, a Retry-After of the hub extends the backoff, up to MaxBackoff.
+	DispatcherOptions struct {
+		Store       DispatchStore // in-memory when nil, see FileDispatchStore to survive restarts
+		QueueSize   int           // notifications pending at once, 1000 when zero
+		Workers     int           // concurrent deliveries, 4 when zero
+		Retry       *RetryOptions // 5 attempts, backoff from 1 second to 1 minute when nil
+		OnDelivered func(n QueuedNotification, telemetry *NotificationTelemetry)
+		OnFailed    func(n QueuedNotification, err error) // called once the notification is given up
+	}
+
+	// DedupStore remembers the telemetry of the notifications sent with an idempotency key,
+	// it must be safe for concurrent use
+	DedupStore interface {
+		Load(ctx context.Context, key string) (telemetry *NotificationTelemetry, found bool, err error)
+		Store(ctx context.Context, key string, telemetry *NotificationTelemetry, ttl time.Duration) error
+	}
+
+	// DedupOptions configures the deduplication of notifications with an idempotency key
+	DedupOptions struct {
+		Store DedupStore    // in-memory when nil
+		TTL   time.Duration // deduplication window, 24 hours when zero
+	}
+
+	// NotificationHubDescription describes a notification hub of a namespace, see NamespaceManager
+	NotificationHubDescription struct {
+		Name               string
+		RegistrationTTL    time.Duration // lifetime of the registrations, the service default when zero
+		AuthorizationRules []AuthorizationRule
+		PnsCredentials
+		ETag string // set by the service, sent as If-Match by UpdateHub
+	}
+
+	// PnsCredentials are the credentials a hub uses to reach the push services, nil when not configured
+	PnsCredentials struct {
+		Apns  *ApnsCredential
+		FcmV1 *FcmV1Credential
+		Wns   *WnsCredential
+		Adm   *AdmCredential
+		Baidu *BaiduCredential
+	}
+
+	// ApnsCredential authenticates the hub with APNS, either with a .p8 token key (KeyID, AppID, AppName and Token)
+	// or with a certificate (Certificate and CertificateKey)
+	ApnsCredential struct {
+		Endpoint       string // ApnsProductionEndpoint or ApnsSandboxEndpoint
+		KeyID          string
+		AppID          string // the team identifier
+		AppName        string // the bundle identifier
+		Token          string // the .p8 key, with or without its PEM armor
+		Certificate    string // the base64 encoded .p12 certificate
+		CertificateKey string
+		Thumbprint     string // set by the service
+	}
+
+	// FcmV1Credential authenticates the hub with FCM v1 using a service account, see NewFcmV1Credential
+	FcmV1Credential struct {
+		ClientEmail string
+		PrivateKey  string // the PEM encoded PKCS #8 key
+		ProjectID   string
+	}
+
+	// WnsCredential authenticates the hub with WNS
+	WnsCredential struct {
+		PackageSID          string
+		SecretKey           string
+		WindowsLiveEndpoint string // optional
+	}
+
+	// AdmCredential authenticates the hub with ADM
+	AdmCredential struct {
+		ClientID     string
+		ClientSecret string
+		AuthTokenURL string // optional
+	}
+
+	// BaiduCredential authenticates the hub with Baidu
+	BaiduCredential struct {
+		APIKey    string
+		SecretKey string
+		Endpoint  string // optional
+	}
+
+	// AuthorizationRule is a shared access key of a notification hub, see NewAuthorizationRule
+	AuthorizationRule struct {
+		KeyName      string
+		PrimaryKey   string
+		SecondaryKey string
+		Rights       []AccessRight
+		CreatedTime  *time.Time // set by the service
+		ModifiedTime *time.Time // set by the service
+	}
+
+	// InstallationQuery filters and pages installation listings
+	InstallationQuery struct {
+		Tag               string // only installations with this tag
+		PushChannel       string // only installations with this push channel
+		Top               int    // number of registrations read per page, the service default when zero
+		ContinuationToken string // page to read, from a previous InstallationPage
+	}
+
+	// InstallationPage is one page of an installation listing
+	InstallationPage struct {
+		Installations     []*Installation
+		ContinuationToken string // empty on the last page
 	}
 
 	// InstallationTemplate is a device installation template
@@ -100,26 +359,130 @@ type (
 		Templates   map[string]InstallationTemplate `json:"templates,omitempty"`
 	}
 
-	// InstallationChange is a device installation change
+	// InstallationChange is a device installation change, a JSON Patch operation.
+	// Value holds any JSON serializable value, such as a string, a tag list or an InstallationTemplate.
 	InstallationChange struct {
 		Op    InstallationChangeOp `json:"op,omitempty"`
 		Path  string               `json:"path,omitempty"`
-		Value string               `json:"value,omitempty"`
+		Value interface{}          `json:"value,omitempty"`
 	}
 
 	// NotificationDetails is the detailed information about a sent or scheduled message
 	NotificationDetails struct {
-		ID                 string                `xml:"NotificationId"`
-		State              NotificationState     `xml:"State"`
-		EnqueueTime        string                `xml:"EnqueueTime"`
-		StartTime          string                `xml:"StartTime"`
-		EndTime            string                `xml:"EndTime"`
-		Body               string                `xml:"NotificationBody"`
-		TargetPlatforms    string                `xml:"TargetPlatforms"`
-		ApnsOutcomeCounts  *NotificationOutcomes `xml:"ApnsOutcomeCounts"`
-		FcmV1OutcomeCounts *NotificationOutcomes `xml:"FcmV1OutcomeCounts"`
+		ID                   string                `xml:"NotificationId"`
+		Location             string                `xml:"Location"` // URL of these details
+		State                NotificationState     `xml:"State"`
+		EnqueueTime          *time.Time            `xml:"-"`
+		StartTime            *time.Time            `xml:"-"`
+		EndTime              *time.Time            `xml:"-"`
+		Body                 string                `xml:"NotificationBody"`
+		TargetPlatforms      string                `xml:"TargetPlatforms"`
+		PnsErrorDetailsURI   string                `xml:"PnsErrorDetailsUri"`
+		ApnsOutcomeCounts    *NotificationOutcomes `xml:"ApnsOutcomeCounts"`
+		WnsOutcomeCounts     *NotificationOutcomes `xml:"WnsOutcomeCounts"`
+		MpnsOutcomeCounts    *NotificationOutcomes `xml:"MpnsOutcomeCounts"`
+		AdmOutcomeCounts     *NotificationOutcomes `xml:"AdmOutcomeCounts"`
+		BaiduOutcomeCounts   *NotificationOutcomes `xml:"BaiduOutcomeCounts"`
+		BrowserOutcomeCounts *NotificationOutcomes `xml:"BrowserOutcomeCounts"`
+		FcmV1OutcomeCounts   *NotificationOutcomes `xml:"FcmV1OutcomeCounts"`
+		XiaomiOutcomeCounts  *NotificationOutcomes `xml:"XiaomiOutcomeCounts"`
 	}
 
+	// WaitOptions configures how WaitForNotification polls the notification telemetry
+	WaitOptions struct {
+		// InitialInterval is the delay before the first retry. Defaults to 1 second.
+		InitialInterval time.Duration
+		// MaxInterval caps the delay between polls. Defaults to 30 seconds.
+		MaxInterval time.Duration
+		// Multiplier grows the delay after every poll. Defaults to 2.
+		Multiplier float64
+		// NotFoundTimeout is how long a missing notification is tolerated,
+		// telemetry is only available a while after enqueueing. Defaults to 2 minutes.
+		NotFoundTimeout time.Duration
+		// OnStateChange is called whenever a poll observes a new state
+		OnStateChange func(details *NotificationDetails)
+	}
+
+	// JobWaitOptions configures how WaitForJob polls a job
+	JobWaitOptions struct {
+		// InitialInterval is the delay before the first retry. Defaults to 1 second.
+		InitialInterval time.Duration
+		// MaxInterval caps the delay between polls. Defaults to 30 seconds.
+		MaxInterval time.Duration
+		// Multiplier grows the delay after every poll. Defaults to 2.
+		Multiplier float64
+		// OnProgress is called whenever a poll observes a new status or progress
+		OnProgress func(job *NotificationHubJob)
+	}
+
+	// PnsErrorDetail is a per-device failure record from the PNS error details blob
+	PnsErrorDetail struct {
+		RegistrationID   string                  `json:"registrationId,omitempty"`
+		InstallationID   string                  `json:"installationId,omitempty"`
+		PnsHandle        string                  `json:"pnsHandle,omitempty"`
+		Outcome          NotificationOutcomeName `json:"outcome,omitempty"`
+		ErrorDescription string                  `json:"errorDescription,omitempty"`
+	}
+
+	// PruneOptions configures PruneDeadTokens
+	PruneOptions struct {
+		// DryRun reports the actions that would be taken without changing the hub
+		DryRun bool
+		// Outcomes are the outcomes identifying dead tokens.
+		// Defaults to WrongToken, ExpiredChannel and BadChannel.
+		Outcomes []NotificationOutcomeName
+		// MarkTag marks dead registrations and installations with the tag instead of deleting them
+		MarkTag string
+		// Interval is the minimum delay between two changes to the hub
+		Interval time.Duration
+	}
+
+	// PruneReport lists the actions taken by PruneDeadTokens
+	PruneReport struct {
+		NotificationID string
+		DryRun         bool
+		Actions        []PruneAction
+	}
+
+	// PruneAction is an action taken on a registration or installation with a dead token
+	PruneAction struct {
+		Kind           PruneActionKind
+		RegistrationID string
+		InstallationID string
+		PnsHandle      string
+		Outcome        NotificationOutcomeName
+		Err            error
+	}
+
+	// PruneActionKind is the kind of action taken by the pruner
+	PruneActionKind string
+
+	// NotificationHubJob is an import or export job of registrations
+	NotificationHubJob struct {
+		ID                 string                   `xml:"JobId,omitempty"                         json:"jobId,omitempty"`
+		Type               NotificationHubJobType   `xml:"Type"                                    json:"type,omitempty"`
+		Status             NotificationHubJobStatus `xml:"Status,omitempty"                        json:"status,omitempty"`
+		Progress           float64                  `xml:"Progress,omitempty"                      json:"progress,omitempty"`
+		OutputContainerURI string                   `xml:"OutputContainerUri"                      json:"outputContainerUri,omitempty"`
+		ImportFileURI      string                   `xml:"ImportFileUri,omitempty"                 json:"importFileUri,omitempty"`
+		Failure            string                   `xml:"Failure,omitempty"                       json:"failure,omitempty"`
+		OutputProperties   []NotificationHubJobProp `xml:"OutputProperties>KeyValueOfstringstring" json:"outputProperties,omitempty"`
+		CreatedAt          *time.Time               `xml:"-"                                       json:"createdAt,omitempty"`
+		UpdatedAt          *time.Time               `xml:"-"                                       json:"updatedAt,omitempty"`
+	}
+
+	// NotificationHubJobProp is a key value pair describing the job output
+	NotificationHubJobProp struct {
+		Key   string `xml:"Key"   json:"key"`
+		Value string `xml:"Value" json:"value"`
+	}
+
+	// NotificationHubJobType is the type of a job
+	NotificationHubJobType string
+
+	// NotificationHubJobStatus is the status of a job
+	NotificationHubJobStatus string
+
 	// NotificationTelemetry is the id of a sent or scheduled message
 	NotificationTelemetry struct {
 		NotificationMessageID string `json:"notificationMessageID,omitempty"`
@@ -142,6 +505,18 @@ type (
 	// NotificationFormat is the format of a notification
 	NotificationFormat string
 
+	// Operation names a hub operation, such as Send or Install
+	Operation string
+
+	// AccessRight is a right granted by an authorization rule
+	AccessRight string
+
+	// CircuitState is the state of a circuit breaker
+	CircuitState string
+
+	// OperationClass groups the operations sharing a rate limit
+	OperationClass string
+
 	// NotificationOutcomeName is a possible outcome of a notification
 	NotificationOutcomeName string
 
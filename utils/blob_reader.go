@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type (
+	// BlobReader reads a blob, such as the PNS error details, from a SAS protected URI
+	BlobReader interface {
+		ReadBlob(ctx context.Context, uri string) (io.ReadCloser, error)
+	}
+
+	// BlobReaderFunc is a function reading blobs
+	BlobReaderFunc func(ctx context.Context, uri string) (io.ReadCloser, error)
+
+	// HTTPBlobReader is the internal BlobReader
+	HTTPBlobReader struct {
+		httpClient *http.Client
+	}
+)
+
+// NewHTTPBlobReader creates the default blob reader
+func NewHTTPBlobReader() BlobReader {
+	return HTTPBlobReader{
+		httpClient: &http.Client{},
+	}
+}
+
+// ReadBlob calls f(ctx, uri)
+func (f BlobReaderFunc) ReadBlob(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return f(ctx, uri)
+}
+
+// ReadBlob streams the blob with a plain GET request, the URI is expected to carry its own SAS token.
+// The caller must close the returned reader.
+func (r HTTPBlobReader) ReadBlob(ctx context.Context, uri string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !isOKResponseCode(resp.StatusCode) {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("Got unexpected response status code: %d. response: %s", resp.StatusCode, string(b))
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+type (
+	// Interaction is a recorded request and the matching response, one line of a JSONL cassette
+	Interaction struct {
+		Request  RecordedRequest   `json:"request"`
+		Response *RecordedResponse `json:"response,omitempty"`
+		Error    string            `json:"error,omitempty"`
+	}
+
+	// RecordedRequest is a recorded HTTP request
+	RecordedRequest struct {
+		Method  string       `json:"method"`
+		URL     string       `json:"url"`
+		Headers http.Header  `json:"headers,omitempty"`
+		Body    RecordedBody `json:"body,omitempty"`
+	}
+
+	// RecordedResponse is a recorded HTTP response
+	RecordedResponse struct {
+		StatusCode int          `json:"statusCode"`
+		Headers    http.Header  `json:"headers,omitempty"`
+		Body       RecordedBody `json:"body,omitempty"`
+	}
+
+	// RecordedBody is a body stored as text, or base64 when it isn't valid UTF-8
+	RecordedBody []byte
+
+	// RecordingClient is an HTTPClient recording every interaction of another HTTPClient to a cassette.
+	// Credentials are redacted and device handles replaced by their HashHandle before they are written.
+	RecordingClient struct {
+		client HTTPClient
+
+		mu sync.Mutex
+		w  io.Writer
+	}
+
+	// ReplayClient is an HTTPClient serving responses from a cassette
+	ReplayClient struct {
+		// MatchBody requires request bodies to match the recorded ones
+		MatchBody bool
+
+		mu           sync.Mutex
+		interactions []Interaction
+		used         []bool
+	}
+)
+
+// NewRecordingClient creates a client executing requests with client and writing interactions to w
+func NewRecordingClient(client HTTPClient, w io.Writer) *RecordingClient {
+	return &RecordingClient{client: client, w: w}
+}
+
+// Exec executes the request with the wrapped client and records the interaction
+func (c *RecordingClient) Exec(req *http.Request) ([]byte, *http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	b, resp, err := c.client.Exec(req)
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     RedactURL(req.URL.String()),
+			Headers: RedactHeaders(req.Header),
+			Body:    redactBody(reqBody),
+		},
+	}
+	if resp != nil {
+		interaction.Response = &RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    RedactHeaders(resp.Header),
+			Body:       redactBody(b),
+		}
+	}
+	if err != nil {
+		interaction.Error = RedactHandles(RedactString(err.Error()))
+	}
+
+	line, merr := json.Marshal(interaction)
+	if merr != nil {
+		return b, resp, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, werr := c.w.Write(append(line, '\n')); werr != nil && err == nil {
+		err = fmt.Errorf("could not record interaction: %w", werr)
+	}
+	return b, resp, err
+}
+
+// NewReplayClient reads a JSONL cassette and creates a client replaying it
+func NewReplayClient(r io.Reader) (*ReplayClient, error) {
+	c := &ReplayClient{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("cassette line %d: %w", line, err)
+		}
+		c.interactions = append(c.interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// LoadReplayClient reads the cassette at path and creates a client replaying it
+func LoadReplayClient(path string) (*ReplayClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayClient(f)
+}
+
+// Exec serves the first unused interaction matching the request method, URL and, optionally, body
+func (c *ReplayClient) Exec(req *http.Request) ([]byte, *http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, nil, err
+		}
+		req.Body.Close()
+	}
+	reqURL := RedactURL(req.URL.String())
+	reqBody = redactBody(reqBody)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, interaction := range c.interactions {
+		if c.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != reqURL {
+			continue
+		}
+		if c.MatchBody && !bytes.Equal(interaction.Request.Body, reqBody) {
+			continue
+		}
+		c.used[i] = true
+		return interaction.replay(req)
+	}
+	return nil, nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, reqURL)
+}
+
+// Remaining returns the number of interactions that haven't been replayed
+func (c *ReplayClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	remaining := 0
+	for _, used := range c.used {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// replay rebuilds the HTTPClient results of the interaction
+func (i Interaction) replay(req *http.Request) ([]byte, *http.Response, error) {
+	var (
+		resp *http.Response
+		body []byte
+		err  error
+	)
+	if i.Response != nil {
+		body = i.Response.Body
+		resp = &http.Response{
+			Status:     fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode: i.Response.StatusCode,
+			Header:     i.Response.Headers,
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}
+		if resp.Header == nil {
+			resp.Header = http.Header{}
+		}
+	}
+	if i.Error != "" {
+		err = errors.New(i.Error)
+	}
+	return body, resp, err
+}
+
+// MarshalJSON stores the body as text when possible
+func (b RecordedBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON reads a text or base64 body
+func (b *RecordedBody) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = RecordedBody(text)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = raw
+	return err
+}
+
+// redactBody replaces secrets and device handles in a body
+func redactBody(b []byte) RecordedBody {
+	if len(b) == 0 {
+		return nil
+	}
+	if !utf8.Valid(b) {
+		return b
+	}
+	return RecordedBody(RedactHandles(RedactString(string(b))))
+}
@@ -0,0 +1,156 @@
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	notificationhubs "github.com/koreset/azure-notifications-sdk-go"
+	"github.com/koreset/azure-notifications-sdk-go/notificationhubstest"
+	"github.com/koreset/azure-notifications-sdk-go/utils"
+)
+
+const errfmt = "Expected %s: \n%v\ngot:\n%v"
+
+func Test_RecordAndReplay(t *testing.T) {
+	var (
+		server   = notificationhubstest.NewServer(nil)
+		cassette = &bytes.Buffer{}
+		ctx      = context.Background()
+	)
+	defer server.Close()
+
+	hub, _ := server.NewHub()
+	hub.SetHTTPClient(utils.NewRecordingClient(utils.NewHubHTTPClient(), cassette))
+
+	notification, _ := notificationhubs.NewNotification(notificationhubs.AppleFormat, []byte(`{"aps":{"alert":"hi"}}`))
+	_, sent, err := hub.Send(ctx, notification, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "send error", nil, err)
+	}
+	_, _, notFoundErr := hub.Installation(ctx, "missing")
+	if notFoundErr == nil {
+		t.Fatalf(errfmt, "installation error", "not found", nil)
+	}
+
+	recorded := cassette.String()
+	if lines := strings.Count(recorded, "\n"); lines != 2 {
+		t.Errorf(errfmt, "recorded interactions", 2, lines)
+	}
+	if strings.Contains(recorded, "SharedAccessSignature") || strings.Contains(recorded, notificationhubstest.DefaultKey) {
+		t.Errorf(errfmt, "redacted cassette", "no credentials", recorded)
+	}
+
+	replay, err := utils.NewReplayClient(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatalf(errfmt, "cassette error", nil, err)
+	}
+	server.Close()
+	hub.SetHTTPClient(replay)
+
+	_, replayed, err := hub.Send(ctx, notification, nil)
+	if err != nil {
+		t.Fatalf(errfmt, "replayed send error", nil, err)
+	}
+	if replayed.NotificationMessageID != sent.NotificationMessageID {
+		t.Errorf(errfmt, "notification ID", sent.NotificationMessageID, replayed.NotificationMessageID)
+	}
+
+	_, _, err = hub.Installation(ctx, "missing")
+	var hubErr *notificationhubs.NotificationHubError
+	if !errors.As(err, &hubErr) || !hubErr.IsNotFound() {
+		t.Errorf(errfmt, "replayed error", notFoundErr, err)
+	}
+
+	if remaining := replay.Remaining(); remaining != 0 {
+		t.Errorf(errfmt, "remaining interactions", 0, remaining)
+	}
+	if _, _, err = hub.Installation(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf(errfmt, "error", "no recorded interaction", err)
+	}
+}
+
+func Test_RecordingRedactsSecrets(t *testing.T) {
+	var (
+		cassette = &bytes.Buffer{}
+		inner    = execFunc(func(req *http.Request) ([]byte, *http.Response, error) {
+			return []byte("Endpoint=sb://ns/;SharedAccessKeyName=name;SharedAccessKey=secretkey"), &http.Response{StatusCode: http.StatusOK}, nil
+		})
+		client = utils.NewRecordingClient(inner, cassette)
+	)
+
+	req, _ := http.NewRequest(http.MethodPost, "https://storage.blob.core.windows.net/c/blob?sv=2015&sig=secretsig", strings.NewReader(`<Uri>https://x/y?sp=r&amp;sig=secretsig2</Uri>`+
+		`<PrimaryKey>secretprimary</PrimaryKey><Property><Name>Token</Name><Value>secrettoken</Value></Property>`))
+	req.Header.Set("Authorization", "SharedAccessSignature sr=x&sig=secretsig3&se=1&skn=name")
+	if _, _, err := client.Exec(req); err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+
+	recorded := cassette.String()
+	for _, secret := range []string{"secretkey", "secretsig", "secretsig2", "secretsig3", "secretprimary", "secrettoken"} {
+		if strings.Contains(recorded, secret) {
+			t.Errorf(errfmt, "redacted "+secret, utils.Redacted, recorded)
+		}
+	}
+
+	replay, _ := utils.NewReplayClient(strings.NewReader(recorded))
+	req, _ = http.NewRequest(http.MethodPost, "https://storage.blob.core.windows.net/c/blob?sv=2015&sig=othersig", nil)
+	if _, resp, err := replay.Exec(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf(errfmt, "replayed response", http.StatusOK, err)
+	}
+}
+
+func Test_RecordingHashesHandles(t *testing.T) {
+	var (
+		server   = notificationhubstest.NewServer(nil)
+		cassette = &bytes.Buffer{}
+		ctx      = context.Background()
+		handles  = []string{"applesecrettoken", "fcmsecretid", "https://wns/secretchannel", "batchsecret1", "batchsecret2", "directsecret"}
+	)
+	defer server.Close()
+
+	hub, _ := server.NewHub()
+	hub.SetHTTPClient(utils.NewRecordingClient(utils.NewHubHTTPClient(), cassette))
+
+	if _, _, err := hub.Register(ctx, notificationhubs.Registration{DeviceID: handles[0], NotificationFormat: notificationhubs.AppleFormat}); err != nil {
+		t.Fatalf(errfmt, "register error", nil, err)
+	}
+	if _, _, err := hub.Registrations(ctx); err != nil {
+		t.Fatalf(errfmt, "registrations error", nil, err)
+	}
+	installation := notificationhubs.Installation{InstallationID: "installation-1", Platform: notificationhubs.FCMV1Platform, PushChannel: handles[1]}
+	if err := hub.Install(ctx, installation); err != nil {
+		t.Fatalf(errfmt, "install error", nil, err)
+	}
+	if err := hub.Update(ctx, "installation-1", notificationhubs.SetPushChannel(handles[2])); err != nil {
+		t.Fatalf(errfmt, "update error", nil, err)
+	}
+	notification, _ := notificationhubs.NewNotification(notificationhubs.FcmV1Format, []byte(`{"message":{}}`))
+	if _, _, err := hub.SendDirectBatch(ctx, notification, handles[3], handles[4]); err != nil {
+		t.Fatalf(errfmt, "batch send error", nil, err)
+	}
+	if _, _, err := hub.SendDirect(ctx, notification, handles[5]); err != nil {
+		t.Fatalf(errfmt, "direct send error", nil, err)
+	}
+
+	recorded := cassette.String()
+	for _, handle := range handles {
+		if strings.Contains(recorded, handle) {
+			t.Errorf(errfmt, "hashed "+handle, utils.HashHandle(handle), recorded)
+		}
+	}
+	for _, handle := range []string{handles[0], handles[1], handles[3], handles[5]} {
+		if !strings.Contains(recorded, utils.HashHandle(handle)) {
+			t.Errorf(errfmt, "hash of "+handle, utils.HashHandle(handle), recorded)
+		}
+	}
+}
+
+type execFunc func(req *http.Request) ([]byte, *http.Response, error)
+
+func (f execFunc) Exec(req *http.Request) ([]byte, *http.Response, error) {
+	return f(req)
+}
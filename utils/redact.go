@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// Redacted replaces secrets in recorded interactions and logs
+const Redacted = "REDACTED"
+
+var (
+	// secretPatterns match secrets in URLs, headers and bodies
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(SharedAccessKey=)[^;"'\s<&]+`),
+		regexp.MustCompile(`([?&;]sig=)[^&"'\s<;]+`),
+		regexp.MustCompile(`(\bsig%3[dD])[^&"'\s<;%]+`),
+		regexp.MustCompile(`(<(?:PrimaryKey|SecondaryKey)>)[^<]+`),
+		regexp.MustCompile(`(<Name>(?:Token|ApnsCertificate|CertificateKey|PrivateKey|SecretKey|ClientSecret|BaiduApiKey|BaiduSecretKey)</Name><Value>)[^<]+`),
+	}
+
+	// handlePatterns match device handles in registration, installation and batch bodies,
+	// the handle being the second submatch
+	handlePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(<(?:DeviceToken|FcmV1RegistrationId|GcmRegistrationId|ChannelUri|AdmRegistrationId|BaiduChannelId)>)([^<]+)`),
+		regexp.MustCompile(`("pushChannel"\s*:\s*")((?:[^"\\]|\\.)*)`),
+		regexp.MustCompile(`("path"\s*:\s*"(?:/secondaryTiles/[^"]*)?/pushChannel"\s*,\s*"value"\s*:\s*")((?:[^"\\]|\\.)*)`),
+	}
+
+	// batchDevicesPattern matches the JSON array of handles in the devices part of a direct batch send
+	batchDevicesPattern = regexp.MustCompile(`(name=devices\r?\n(?:[^\r\n]+\r?\n)*\r?\n)(\[[^\]]*\])`)
+
+	// redactedHeaders are headers replaced entirely
+	redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+	// handleHeaders are headers holding a device handle, replaced by its hash
+	handleHeaders = []string{"ServiceBusNotification-DeviceHandle"}
+)
+
+// RedactHeaders copies headers, replacing credentials
+func RedactHeaders(headers http.Header) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		for _, value := range values {
+			redacted.Add(name, RedactString(value))
+		}
+	}
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	for _, name := range handleHeaders {
+		if handle := headers.Get(name); handle != "" {
+			redacted.Set(name, HashHandle(handle))
+		}
+	}
+	return redacted
+}
+
+// RedactURL replaces SAS signatures in a URL
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return RedactString(rawURL)
+	}
+	if q := u.Query(); q.Has("sig") {
+		q.Set("sig", Redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// RedactString replaces SAS keys and signatures in s
+func RedactString(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// RedactHandles replaces the device handles of registration and installation bodies,
+// and of the devices of direct batch sends, with their HashHandle
+func RedactHandles(s string) string {
+	for _, pattern := range handlePatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			return groups[1] + HashHandle(groups[2])
+		})
+	}
+	return batchDevicesPattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := batchDevicesPattern.FindStringSubmatch(match)
+		var handles []string
+		if err := json.Unmarshal([]byte(groups[2]), &handles); err != nil {
+			return match
+		}
+		for i, handle := range handles {
+			handles[i] = HashHandle(handle)
+		}
+		hashed, _ := json.Marshal(handles)
+		return groups[1] + string(hashed)
+	})
+}
+
+// HashHandle returns a stable, non reversible identifier for a device handle, safe to log
+func HashHandle(handle string) string {
+	if handle == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(handle))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,124 @@
+package notificationhubs
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// payloadSizeLimits are the payload size limits of the push services, by format
+var payloadSizeLimits = map[NotificationFormat]int{
+	AppleFormat:   maxApplePayloadSize,
+	FcmV1Format:   maxFcmV1PayloadSize,
+	WindowsFormat: maxWindowsPayloadSize,
+	KindleFormat:  maxKindlePayloadSize,
+	BaiduFormat:   maxBaiduPayloadSize,
+}
+
+// payloadRoots are the object required at the root of JSON payloads, by format
+var payloadRoots = map[NotificationFormat]string{
+	AppleFormat:  "aps",
+	FcmV1Format:  "message",
+	KindleFormat: "data",
+}
+
+// Validate checks the notification before it is sent: the format, the payload well-formedness for the
+// format content type, the size limit of the push service and the fields it requires, such as the APNS
+// "aps" or the FCM v1 "message" roots. It returns a MultiError of ValidationErrors.
+// Windows payloads not starting with an XML element are raw notifications and only checked for size.
+func (n *Notification) Validate() error {
+	errs := NewMultiError()
+	if !n.Format.IsValid() {
+		errs.Add(NewValidationError("Format", "unknown format", n.Format))
+		return errs.ToError()
+	}
+	if len(n.Payload) == 0 {
+		errs.Add(NewValidationError("Payload", "cannot be empty", ""))
+		return errs.ToError()
+	}
+	if limit, ok := payloadSizeLimits[n.Format]; ok && len(n.Payload) > limit {
+		errs.Add(NewValidationError("Payload", fmt.Sprintf("%d bytes exceed the %d bytes limit of %s", len(n.Payload), limit, n.Format), len(n.Payload)))
+	}
+
+	switch {
+	case n.Format.GetContentType() == "application/json":
+		validateJSONPayload(errs, n.Format, n.Payload)
+	case n.Format == WindowsFormat && wnsType(n.Payload) == "wns/raw":
+	default:
+		if err := checkXML(n.Payload); err != nil {
+			errs.Add(NewValidationError("Payload", "malformed XML: "+err.Error(), nil))
+		}
+	}
+	return errs.ToError()
+}
+
+// SetPayloadValidation makes every send method of the hub, including SendMultiPlatform, SendLocalized and the
+// dispatcher, validate notifications before sending them, see Notification.Validate
+func (h *NotificationHub) SetPayloadValidation(enabled bool) {
+	h.validatePayloads = enabled
+}
+
+// validateJSONPayload checks a JSON payload is an object with the root fields its format requires
+func validateJSONPayload(errs *MultiError, format NotificationFormat, payload []byte) {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &root); err != nil {
+		errs.Add(NewValidationError("Payload", "must be a JSON object: "+err.Error(), nil))
+		return
+	}
+
+	if field, ok := payloadRoots[format]; ok {
+		var object map[string]json.RawMessage
+		if raw, found := root[field]; !found {
+			errs.Add(NewValidationError("Payload."+field, "is required by "+string(format), nil))
+		} else if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+			errs.Add(NewValidationError("Payload."+field, "must be an object", string(raw)))
+		}
+	}
+
+	if format == Template {
+		for name, raw := range root {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				errs.Add(NewValidationError("Payload."+name, "template properties must be strings", string(raw)))
+			}
+		}
+	}
+}
+
+// checkXML checks a payload is a single well-formed XML document
+func checkXML(payload []byte) error {
+	var (
+		decoder = xml.NewDecoder(bytes.NewReader(payload))
+		depth   int
+		roots   int
+	)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(token)) > 0 {
+				return errors.New("text outside of the root element")
+			}
+		}
+	}
+	if roots != 1 {
+		return fmt.Errorf("expected a single root element, found %d", roots)
+	}
+	return nil
+}
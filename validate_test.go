@@ -0,0 +1,106 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+func Test_NotificationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  NotificationFormat
+		payload string
+		fields  []string
+	}{
+		{"apple", AppleFormat, `{"aps":{"alert":"hello"},"orderId":"42"}`, nil},
+		{"apple without aps", AppleFormat, `{"alert":"hello"}`, []string{"Payload.aps"}},
+		{"apple with scalar aps", AppleFormat, `{"aps":"hello"}`, []string{"Payload.aps"}},
+		{"apple too large", AppleFormat, `{"aps":{"alert":"` + strings.Repeat("a", 4096) + `"}}`, []string{"Payload"}},
+		{"fcm v1", FcmV1Format, `{"message":{"notification":{"title":"hello"}}}`, nil},
+		{"fcm v1 legacy payload", FcmV1Format, `{"data":{"message":"hello"}}`, []string{"Payload.message"}},
+		{"fcm v1 malformed", FcmV1Format, `{"message":`, []string{"Payload"}},
+		{"fcm v1 array", FcmV1Format, `[{"message":{}}]`, []string{"Payload"}},
+		{"windows toast", WindowsFormat, `<toast><visual><binding template="ToastGeneric"><text>hello</text></binding></visual></toast>`, nil},
+		{"windows declaration", WindowsFormat, `<?xml version="1.0" encoding="utf-8"?><badge value="1"/>`, nil},
+		{"windows unclosed", WindowsFormat, `<toast><visual>`, []string{"Payload"}},
+		{"windows raw", WindowsFormat, `raw data`, nil},
+		{"windows too large", WindowsFormat, `<toast>` + strings.Repeat("a", 5120) + `</toast>`, []string{"Payload"}},
+		{"windows phone two roots", WindowsPhoneFormat, `<a/><b/>`, []string{"Payload"}},
+		{"kindle", KindleFormat, `{"data":{"message":"hello"}}`, nil},
+		{"kindle without data", KindleFormat, `{"message":"hello"}`, []string{"Payload.data"}},
+		{"baidu", BaiduFormat, `{"title":"hello","description":"world"}`, nil},
+		{"template", Template, `{"message":"hello","badge":"1"}`, nil},
+		{"template with number", Template, `{"message":"hello","badge":1}`, []string{"Payload.badge"}},
+		{"empty", AppleFormat, ``, []string{"Payload"}},
+		{"unknown format", "gcm", `{}`, []string{"Format"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &Notification{Format: test.format, Payload: []byte(test.payload)}
+			err := n.Validate()
+
+			var fields []string
+			var multi *MultiError
+			if errors.As(err, &multi) {
+				for _, e := range multi.Errors {
+					var validationErr *ValidationError
+					if !errors.As(e, &validationErr) {
+						t.Fatalf(errfmt, "error type", "*ValidationError", e)
+					}
+					fields = append(fields, validationErr.Field)
+				}
+			} else if err != nil {
+				t.Fatalf(errfmt, "error type", "*MultiError", err)
+			}
+			sort.Strings(fields)
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf(errfmt, "invalid fields", test.fields, fields)
+			}
+		})
+	}
+}
+
+func Test_SetPayloadValidation(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            int
+		notification, _  = NewNotification(AppleFormat, []byte(`{"alert":"hello"}`))
+	)
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		return nil, &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}, nil
+	}
+
+	if _, _, err := nhub.Send(context.Background(), notification, nil); err != nil || calls != 1 {
+		t.Fatalf(errfmt, "Send without validation", "sent", err)
+	}
+
+	nhub.SetPayloadValidation(true)
+	_, _, err := nhub.SendDirect(context.Background(), notification, "handle")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "Payload.aps" {
+		t.Errorf(errfmt, "SendDirect error", "Payload.aps validation error", err)
+	}
+	_, _, err = nhub.Send(context.Background(), notification, nil)
+	if !errors.As(err, &validationErr) || !strings.HasPrefix(err.Error(), "notificationhubs.Send: ") {
+		t.Errorf(errfmt, "Send error", "notificationhubs.Send: validation error", err)
+	}
+	_, err = nhub.SendLocalized(context.Background(), &LocalizedMessage{Messages: map[string]*MultiPlatformMessage{"en": {
+		Platforms: []NotificationFormat{AppleFormat},
+		Overrides: map[NotificationFormat]PlatformOverride{AppleFormat: {Payload: notification.Payload}},
+	}}}, nil)
+	if !errors.As(err, &validationErr) {
+		t.Errorf(errfmt, "SendLocalized error", "Payload.aps validation error", err)
+	}
+	if calls != 1 {
+		t.Errorf(errfmt, "calls", 1, calls)
+	}
+}
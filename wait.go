@@ -0,0 +1,114 @@
+package notificationhubs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default polling configuration of WaitForNotification
+const (
+	defaultWaitInitialInterval = time.Second
+	defaultWaitMaxInterval     = 30 * time.Second
+	defaultWaitMultiplier      = 2.0
+	defaultWaitNotFoundTimeout = 2 * time.Minute
+)
+
+// IsTerminal identifies whether the notification won't change state anymore
+func (s NotificationState) IsTerminal() bool {
+	switch s {
+	case Completed, Abandoned, NoTargetFound, Canceled:
+		return true
+	}
+	return false
+}
+
+// WaitForNotification polls the notification telemetry until the notification reaches a terminal state
+// and returns the final details. Use the context to limit the total time spent waiting.
+// Notification Telemetry is only available for Standard tier Notification Hubs.
+func (h *NotificationHub) WaitForNotification(ctx context.Context, notificationID string, opts *WaitOptions) (*NotificationDetails, error) {
+	var (
+		o         = opts.withDefaults()
+		interval  = o.InitialInterval
+		started   = time.Now()
+		lastState NotificationState
+	)
+
+	for {
+		details, _, err := h.NotificationDetails(ctx, notificationID)
+		switch {
+		case err == nil:
+			if details.State != lastState {
+				lastState = details.State
+				if o.OnStateChange != nil {
+					o.OnStateChange(details)
+				}
+			}
+			if details.State.IsTerminal() {
+				return details, nil
+			}
+		case isNotFound(err) && time.Since(started) < o.NotFoundTimeout:
+			// telemetry isn't available right after enqueueing
+		default:
+			return nil, fmt.Errorf("notificationhubs.WaitForNotification: %w", err)
+		}
+
+		if interval, err = o.backoff(ctx, interval); err != nil {
+			return nil, fmt.Errorf("notificationhubs.WaitForNotification: %w", err)
+		}
+	}
+}
+
+// withDefaults returns a copy of the options with unset values defaulted
+func (o *WaitOptions) withDefaults() WaitOptions {
+	var result WaitOptions
+	if o != nil {
+		result = *o
+	}
+	if result.InitialInterval <= 0 {
+		result.InitialInterval = defaultWaitInitialInterval
+	}
+	if result.MaxInterval <= 0 {
+		result.MaxInterval = defaultWaitMaxInterval
+	}
+	if result.MaxInterval < result.InitialInterval {
+		result.MaxInterval = result.InitialInterval
+	}
+	if result.Multiplier < 1 {
+		result.Multiplier = defaultWaitMultiplier
+	}
+	if result.NotFoundTimeout <= 0 {
+		result.NotFoundTimeout = defaultWaitNotFoundTimeout
+	}
+	return result
+}
+
+// backoff sleeps for interval, or until ctx is done, and returns the next interval
+func (o WaitOptions) backoff(ctx context.Context, interval time.Duration) (time.Duration, error) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return interval, ctx.Err()
+	case <-timer.C:
+	}
+
+	interval = time.Duration(float64(interval) * o.Multiplier)
+	if interval > o.MaxInterval {
+		interval = o.MaxInterval
+	}
+	return interval, nil
+}
+
+// isNotFound identifies whether err is a not found response from the hub
+func isNotFound(err error) bool {
+	var hubErr *NotificationHubError
+	return errors.As(err, &hubErr) && hubErr.IsNotFound()
+}
+
+// isPreconditionFailed identifies precondition failed errors of conditional requests
+func isPreconditionFailed(err error) bool {
+	var hubErr *NotificationHubError
+	return errors.As(err, &hubErr) && hubErr.IsPreconditionFailed()
+}
@@ -0,0 +1,123 @@
+package notificationhubs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/koreset/azure-notifications-sdk-go"
+)
+
+const notificationDetailsFmt = `<NotificationDetails xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect"><NotificationId>%s</NotificationId><State>%s</State></NotificationDetails>`
+
+var fastWaitOptions = WaitOptions{
+	InitialInterval: time.Millisecond,
+	MaxInterval:     2 * time.Millisecond,
+}
+
+func Test_WaitForNotification(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		calls            = 0
+		states           []NotificationState
+		opts             = fastWaitOptions
+	)
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, &http.Response{StatusCode: http.StatusNotFound}, errors.New("Got unexpected response status code: 404")
+		case 2, 3:
+			return []byte(fmt.Sprintf(notificationDetailsFmt, notificationID, Processing)), nil, nil
+		default:
+			return []byte(fmt.Sprintf(notificationDetailsFmt, notificationID, Completed)), nil, nil
+		}
+	}
+	opts.OnStateChange = func(details *NotificationDetails) {
+		states = append(states, details.State)
+	}
+
+	details, err := nhub.WaitForNotification(context.Background(), notificationID, &opts)
+	if err != nil {
+		t.Fatalf(errfmt, "error", nil, err)
+	}
+	if details.State != Completed {
+		t.Errorf(errfmt, "state", Completed, details.State)
+	}
+	if calls != 4 {
+		t.Errorf(errfmt, "calls", 4, calls)
+	}
+	if len(states) != 2 || states[0] != Processing || states[1] != Completed {
+		t.Errorf(errfmt, "state changes", []NotificationState{Processing, Completed}, states)
+	}
+}
+
+func Test_WaitForNotificationNotFoundTimeout(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		opts             = fastWaitOptions
+	)
+	opts.NotFoundTimeout = 5 * time.Millisecond
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusNotFound}, errors.New("Got unexpected response status code: 404")
+	}
+
+	_, err := nhub.WaitForNotification(context.Background(), notificationID, &opts)
+	var hubErr *NotificationHubError
+	if !errors.As(err, &hubErr) || !hubErr.IsNotFound() {
+		t.Errorf(errfmt, "error", "not found NotificationHubError", err)
+	}
+}
+
+func Test_WaitForNotificationError(t *testing.T) {
+	nhub, mockClient := initTestItems()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return nil, &http.Response{StatusCode: http.StatusUnauthorized}, errors.New("Got unexpected response status code: 401")
+	}
+
+	_, err := nhub.WaitForNotification(context.Background(), notificationID, &fastWaitOptions)
+	var hubErr *NotificationHubError
+	if !errors.As(err, &hubErr) || hubErr.Code != ErrorCodeUnauthorized {
+		t.Errorf(errfmt, "error", ErrorCodeUnauthorized, err)
+	}
+}
+
+func Test_WaitForNotificationContextCanceled(t *testing.T) {
+	var (
+		nhub, mockClient = initTestItems()
+		ctx, cancel      = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	)
+	defer cancel()
+
+	mockClient.execFunc = func(req *http.Request) ([]byte, *http.Response, error) {
+		return []byte(fmt.Sprintf(notificationDetailsFmt, notificationID, Enqueued)), nil, nil
+	}
+
+	_, err := nhub.WaitForNotification(ctx, notificationID, &fastWaitOptions)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(errfmt, "error", context.DeadlineExceeded, err)
+	}
+}
+
+func Test_NotificationStateIsTerminal(t *testing.T) {
+	for state, expected := range map[NotificationState]bool{
+		Abandoned:     true,
+		Canceled:      true,
+		Completed:     true,
+		NoTargetFound: true,
+		Enqueued:      false,
+		Processing:    false,
+		Scheduled:     false,
+		Unknown:       false,
+	} {
+		if got := state.IsTerminal(); got != expected {
+			t.Errorf("NotificationState(%s).IsTerminal() = %v, want %v", state, got, expected)
+		}
+	}
+}